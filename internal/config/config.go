@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -41,6 +42,44 @@ type DebugConfig struct {
 	Enabled bool `yaml:"enabled"`
 }
 
+// RunDefaults holds the team-wide baseline for agent runs. Runner consumers
+// apply it beneath their per-run options, so any value set on a run wins and
+// any zero value here means "no limit".
+type RunDefaults struct {
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+	IdleTimeout time.Duration `yaml:"idle_timeout,omitempty"`
+	MaxTurns    int           `yaml:"max_turns,omitempty"`
+	MaxCostUSD  float64       `yaml:"max_cost_usd,omitempty"`
+	Retries     int           `yaml:"retries,omitempty"`
+}
+
+// Claude runner transports. The exec transport drives the claude CLI; the api
+// transport calls the Anthropic Messages API directly.
+const (
+	ClaudeTransportExec = "exec"
+	ClaudeTransportAPI  = "api"
+)
+
+// Agent output formats. stream-json emits one event per line as the run
+// progresses; json emits the whole transcript as a single JSON array when the
+// run ends; text, for CLIs that cannot emit JSON, emits only the final
+// response as plain text.
+const (
+	OutputFormatStreamJSON = "stream-json"
+	OutputFormatJSON       = "json"
+	OutputFormatText       = "text"
+)
+
+// ClaudeConfig selects how the claude runner reaches the model.
+type ClaudeConfig struct {
+	Transport    string            `yaml:"transport,omitempty"`     // empty means ClaudeTransportExec
+	APIKey       string            `yaml:"api_key,omitempty"`       // api only; falls back to ANTHROPIC_API_KEY
+	BaseURL      string            `yaml:"base_url,omitempty"`      // api only
+	OutputFormat string            `yaml:"output_format,omitempty"` // exec only; empty means OutputFormatStreamJSON
+	SettingsPath string            `yaml:"settings_path,omitempty"` // exec only; CLI settings file passed with --settings
+	FieldMap     map[string]string `yaml:"field_map,omitempty"`     // renames agent JSON keys to canonical ones
+}
+
 // SpecConfig holds configuration for specification creation. It names a
 // storage provider, the provider-agnostic spec identifier method, and the
 // provider's own settings.
@@ -93,6 +132,8 @@ type Config struct {
 	Spec      SpecConfig      `yaml:"spec"`
 	Plan      PlanConfig      `yaml:"plan"`
 	Knowledge KnowledgeConfig `yaml:"knowledge"`
+	Run       RunDefaults     `yaml:"run,omitempty"`
+	Claude    ClaudeConfig    `yaml:"claude,omitempty"`
 }
 
 // NewDefault returns a Config populated with default values.
@@ -162,6 +203,50 @@ func (c Config) Validate() error {
 	if err := c.Knowledge.Validate(); err != nil {
 		return err
 	}
+	if err := c.Run.Validate(); err != nil {
+		return err
+	}
+	if err := c.Claude.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate checks that the claude transport and output format, if set, are
+// known ones, and that no field_map entry is blank.
+func (c ClaudeConfig) Validate() error {
+	switch c.Transport {
+	case "", ClaudeTransportExec, ClaudeTransportAPI:
+	default:
+		return fmt.Errorf("claude.transport must be %q or %q", ClaudeTransportExec, ClaudeTransportAPI)
+	}
+	switch c.OutputFormat {
+	case "", OutputFormatStreamJSON, OutputFormatJSON, OutputFormatText:
+	default:
+		return fmt.Errorf("claude.output_format must be %q, %q, or %q", OutputFormatStreamJSON, OutputFormatJSON, OutputFormatText)
+	}
+	for from, to := range c.FieldMap {
+		if from == "" || to == "" {
+			return fmt.Errorf("claude.field_map must not map %q to %q: keys cannot be empty", from, to)
+		}
+	}
+	return nil
+}
+
+// Validate checks that no run default is negative.
+func (c RunDefaults) Validate() error {
+	switch {
+	case c.Timeout < 0:
+		return fmt.Errorf("run.timeout must not be negative")
+	case c.IdleTimeout < 0:
+		return fmt.Errorf("run.idle_timeout must not be negative")
+	case c.MaxTurns < 0:
+		return fmt.Errorf("run.max_turns must not be negative")
+	case c.MaxCostUSD < 0:
+		return fmt.Errorf("run.max_cost_usd must not be negative")
+	case c.Retries < 0:
+		return fmt.Errorf("run.retries must not be negative")
+	}
 	return nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -234,3 +235,110 @@ func TestKnowledgeConfig_ValidateRejectsDuplicateScope(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "more than once")
 }
+
+func TestFromYAMLFile_LoadsRunDefaults(t *testing.T) {
+	yaml := `run:
+  timeout: 30m
+  idle_timeout: 90s
+  max_turns: 40
+  max_cost_usd: 2.5
+  retries: 2`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	err := os.WriteFile(path, []byte(yaml), 0644)
+	require.NoError(t, err)
+
+	cfg, err := FromYAMLFile(path)
+	require.NoError(t, err)
+	require.Equal(t, RunDefaults{
+		Timeout:     30 * time.Minute,
+		IdleTimeout: 90 * time.Second,
+		MaxTurns:    40,
+		MaxCostUSD:  2.5,
+		Retries:     2,
+	}, cfg.Run)
+}
+
+func TestFromYAMLFile_NegativeRunDefaultReturnsError(t *testing.T) {
+	yaml := `run:
+  retries: -1`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	err := os.WriteFile(path, []byte(yaml), 0644)
+	require.NoError(t, err)
+
+	_, err = FromYAMLFile(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "run.retries")
+}
+
+func TestToYAMLFile_OmitsUnsetRunDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, NewDefault().ToYAMLFile(path))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "run:")
+	require.NotContains(t, string(raw), "claude:")
+}
+
+func TestFromYAMLFile_LoadsClaudeTransport(t *testing.T) {
+	t.Setenv("TEST_ANTHROPIC_KEY", "sk-test")
+	yaml := `claude:
+  transport: api
+  api_key: ${TEST_ANTHROPIC_KEY}`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	err := os.WriteFile(path, []byte(yaml), 0644)
+	require.NoError(t, err)
+
+	cfg, err := FromYAMLFile(path)
+	require.NoError(t, err)
+	require.Equal(t, ClaudeConfig{Transport: ClaudeTransportAPI, APIKey: "sk-test"}, cfg.Claude)
+}
+
+func TestFromYAMLFile_UnknownClaudeTransportReturnsError(t *testing.T) {
+	yaml := `claude:
+  transport: grpc`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	err := os.WriteFile(path, []byte(yaml), 0644)
+	require.NoError(t, err)
+
+	_, err = FromYAMLFile(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "claude.transport")
+}
+
+func TestFromYAMLFile_UnknownClaudeOutputFormatReturnsError(t *testing.T) {
+	yaml := `claude:
+  output_format: xml`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	err := os.WriteFile(path, []byte(yaml), 0644)
+	require.NoError(t, err)
+
+	_, err = FromYAMLFile(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "claude.output_format")
+}
+
+func TestFromYAMLFile_ClaudeFieldMap(t *testing.T) {
+	yaml := `claude:
+  field_map:
+    msg: message
+    kind: type`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(yaml), 0644))
+
+	cfg, err := FromYAMLFile(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"msg": "message", "kind": "type"}, cfg.Claude.FieldMap)
+}
+
+func TestClaudeConfig_ValidateRejectsEmptyFieldMapKey(t *testing.T) {
+	err := ClaudeConfig{FieldMap: map[string]string{"msg": ""}}.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "claude.field_map")
+}
@@ -0,0 +1,59 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ContextCommits is how many recent commit subjects GitContext lists.
+const ContextCommits = 5
+
+// GitContext summarises the state of the repository at repoDir for an agent
+// prompt: the current branch, the subjects of the last ContextCommits
+// commits, and the uncommitted changes as `git status --porcelain` reports
+// them. Outside a git repository it returns an empty string and no error.
+func GitContext(repoDir string) (string, error) {
+	if _, err := run(repoDir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", err
+		}
+		return "", nil
+	}
+
+	branch, err := run(repoDir, "branch", "--show-current")
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("## Git Context\n\n")
+	if branch = strings.TrimSpace(branch); branch != "" {
+		fmt.Fprintf(&b, "Branch: %s\n", branch)
+	} else {
+		b.WriteString("Branch: (detached HEAD)\n")
+	}
+
+	// A repository with no commits yet has no HEAD to log from.
+	if _, err := run(repoDir, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		log, err := run(repoDir, "log", "-n", fmt.Sprint(ContextCommits), "--format=%s")
+		if err != nil {
+			return "", err
+		}
+		b.WriteString("\nRecent commits:\n")
+		for _, subject := range strings.Split(strings.TrimSpace(log), "\n") {
+			fmt.Fprintf(&b, "- %s\n", subject)
+		}
+	}
+
+	status, err := run(repoDir, "status", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	if status = strings.TrimRight(status, "\n"); status != "" {
+		fmt.Fprintf(&b, "\nUncommitted changes:\n%s\n", status)
+	} else {
+		b.WriteString("\nWorking tree clean.\n")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitContext_BranchCommitsAndStatus(t *testing.T) {
+	dir := initRepo(t)
+	for _, subject := range []string{"second", "third", "fourth", "fifth", "sixth"} {
+		writeFile(t, dir, "log.txt", subject+"\n")
+		mustGit(t, dir, "add", "log.txt")
+		mustGit(t, dir, "commit", "--quiet", "-m", subject)
+	}
+	mustGit(t, dir, "checkout", "--quiet", "-b", "feature/auth")
+	writeFile(t, dir, "README.md", "changed\n")
+	writeFile(t, dir, "notes.txt", "new\n")
+
+	got, err := GitContext(dir)
+	require.NoError(t, err)
+	require.Equal(t, `## Git Context
+
+Branch: feature/auth
+
+Recent commits:
+- sixth
+- fifth
+- fourth
+- third
+- second
+
+Uncommitted changes:
+ M README.md
+?? notes.txt
+`, got)
+}
+
+func TestGitContext_CleanTreeAndNoCommits(t *testing.T) {
+	dir := initRepo(t)
+	got, err := GitContext(dir)
+	require.NoError(t, err)
+	require.Contains(t, got, "- initial\n")
+	require.Contains(t, got, "Working tree clean.")
+
+	empty := t.TempDir()
+	mustGit(t, empty, "init", "--quiet", "--initial-branch=main")
+	got, err = GitContext(empty)
+	require.NoError(t, err)
+	require.Contains(t, got, "Branch: main")
+	require.NotContains(t, got, "Recent commits")
+}
+
+func TestGitContext_OutsideRepository(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+
+	got, err := GitContext(dir)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
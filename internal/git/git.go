@@ -0,0 +1,105 @@
+// Package git wraps the handful of git operations Spektacular performs on the
+// user's repository. It shells out to the git CLI rather than linking a git
+// library, so it honours the user's own git configuration.
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CommitPlan commits file (a path inside repoDir) on branch with message. The
+// branch is checked out first, and created from the current HEAD if it does
+// not exist; an empty branch commits on the current one. Only file is staged
+// and committed. If other changes are already staged CommitPlan refuses to
+// commit them alongside the plan and returns an error. A file with no changes
+// since the last commit is a no-op.
+func CommitPlan(repoDir, file, message, branch string) error {
+	rel, err := repoRelative(repoDir, file)
+	if err != nil {
+		return err
+	}
+
+	// -z keeps paths containing whitespace or quotes intact.
+	staged, err := run(repoDir, "diff", "--cached", "--name-only", "-z")
+	if err != nil {
+		return err
+	}
+	for _, path := range strings.Split(staged, "\x00") {
+		if path != "" && path != rel {
+			return fmt.Errorf("committing plan: %s has unrelated staged changes (%s); commit or unstage them first", repoDir, path)
+		}
+	}
+
+	if branch != "" {
+		if err := checkout(repoDir, branch); err != nil {
+			return err
+		}
+	}
+
+	if _, err := run(repoDir, "add", "--", rel); err != nil {
+		return err
+	}
+	if _, err := run(repoDir, "diff", "--cached", "--quiet", "--", rel); err == nil {
+		return nil // nothing changed since the last commit
+	}
+	if _, err := run(repoDir, "commit", "--quiet", "-m", message, "--", rel); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkout switches repoDir to branch, creating it if needed. It is a no-op
+// when branch is already checked out.
+func checkout(repoDir, branch string) error {
+	current, err := run(repoDir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(current) == branch {
+		return nil
+	}
+	if _, err := run(repoDir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		_, err = run(repoDir, "checkout", "--quiet", branch)
+		return err
+	}
+	_, err = run(repoDir, "checkout", "--quiet", "-b", branch)
+	return err
+}
+
+// repoRelative returns file relative to repoDir using forward slashes, as git
+// reports paths. A relative file is taken to be relative to repoDir already.
+func repoRelative(repoDir, file string) (string, error) {
+	if !filepath.IsAbs(file) {
+		return filepath.ToSlash(filepath.Clean(file)), nil
+	}
+	absRepo, err := filepath.Abs(repoDir)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", repoDir, err)
+	}
+	rel, err := filepath.Rel(absRepo, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("committing plan: %s is outside repository %s", file, repoDir)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// run executes git with args in dir and returns its stdout. A failure carries
+// git's stderr so the caller sees why.
+func run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
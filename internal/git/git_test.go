@@ -0,0 +1,126 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// initRepo creates a temp git repository with one initial commit and an
+// isolated identity, and returns its path.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	mustGit(t, dir, "init", "--quiet", "--initial-branch=main")
+	writeFile(t, dir, "README.md", "hello\n")
+	mustGit(t, dir, "add", "README.md")
+	mustGit(t, dir, "commit", "--quiet", "-m", "initial")
+	return dir
+}
+
+func mustGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := run(dir, args...)
+	require.NoError(t, err)
+	return strings.TrimSpace(out)
+}
+
+func writeFile(t *testing.T, dir, rel, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestCommitPlan_CommitsFileOnNewBranch(t *testing.T) {
+	dir := initRepo(t)
+	plan := writeFile(t, dir, ".spektacular/plans/000001_auth/plan.md", "# Plan\n")
+	writeFile(t, dir, "scratch.txt", "untracked and unrelated\n")
+
+	require.NoError(t, CommitPlan(dir, plan, "Add auth plan", "plans/auth"))
+
+	require.Equal(t, "plans/auth", mustGit(t, dir, "rev-parse", "--abbrev-ref", "HEAD"))
+	require.Equal(t, "Add auth plan", mustGit(t, dir, "log", "-1", "--format=%s"))
+	require.Equal(t, ".spektacular/plans/000001_auth/plan.md", mustGit(t, dir, "show", "--name-only", "--format=", "HEAD"))
+	require.Contains(t, mustGit(t, dir, "status", "--porcelain"), "?? scratch.txt")
+}
+
+func TestCommitPlan_ExistingBranchAndRelativePath(t *testing.T) {
+	dir := initRepo(t)
+	mustGit(t, dir, "branch", "plans")
+	writeFile(t, dir, "plan.md", "# Plan\n")
+
+	require.NoError(t, CommitPlan(dir, "plan.md", "Add plan", "plans"))
+	require.Equal(t, "plans", mustGit(t, dir, "rev-parse", "--abbrev-ref", "HEAD"))
+	require.Equal(t, "Add plan", mustGit(t, dir, "log", "-1", "--format=%s"))
+}
+
+func TestCommitPlan_UnchangedFileIsNoOp(t *testing.T) {
+	dir := initRepo(t)
+	plan := writeFile(t, dir, "plan.md", "# Plan\n")
+	require.NoError(t, CommitPlan(dir, plan, "Add plan", ""))
+	head := mustGit(t, dir, "rev-parse", "HEAD")
+
+	require.NoError(t, CommitPlan(dir, plan, "Add plan again", ""))
+	require.Equal(t, head, mustGit(t, dir, "rev-parse", "HEAD"))
+}
+
+func TestCommitPlan_UnrelatedStagedChangesError(t *testing.T) {
+	dir := initRepo(t)
+	writeFile(t, dir, "README.md", "changed\n")
+	mustGit(t, dir, "add", "README.md")
+	plan := writeFile(t, dir, "plan.md", "# Plan\n")
+
+	err := CommitPlan(dir, plan, "Add plan", "plans")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unrelated staged changes")
+	require.Equal(t, "main", mustGit(t, dir, "rev-parse", "--abbrev-ref", "HEAD"))
+}
+
+func TestCommitPlan_FileOutsideRepoErrors(t *testing.T) {
+	dir := initRepo(t)
+	outside := writeFile(t, t.TempDir(), "plan.md", "# Plan\n")
+
+	err := CommitPlan(dir, outside, "Add plan", "")
+	require.ErrorContains(t, err, "outside repository")
+}
+
+func TestCommitPlan_NotARepoErrors(t *testing.T) {
+	t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+	err := CommitPlan(t.TempDir(), "plan.md", "Add plan", "")
+	require.Error(t, err)
+}
+
+func TestCommitPlan_PathsWithSpaces(t *testing.T) {
+	dir := initRepo(t)
+	plan := writeFile(t, dir, "docs/my plan.md", "# Plan\n")
+	mustGit(t, dir, "add", "docs/my plan.md")
+
+	require.NoError(t, CommitPlan(dir, plan, "Add plan", ""), "the plan's own staged change is not unrelated")
+	require.Equal(t, "docs/my plan.md", mustGit(t, dir, "show", "--name-only", "--format=", "HEAD"))
+
+	writeFile(t, dir, "docs/my spec.md", "# Spec\n")
+	mustGit(t, dir, "add", "docs/my spec.md")
+	writeFile(t, dir, "docs/my plan.md", "# Plan v2\n")
+	err := CommitPlan(dir, plan, "Update plan", "")
+	require.ErrorContains(t, err, "(docs/my spec.md)")
+}
+
+func TestCommitPlan_DotDotPrefixedNameInsideRepo(t *testing.T) {
+	dir := initRepo(t)
+	plan := writeFile(t, dir, "..notes/plan.md", "# Plan\n")
+
+	require.NoError(t, CommitPlan(dir, plan, "Add plan", ""))
+	require.Equal(t, "..notes/plan.md", mustGit(t, dir, "show", "--name-only", "--format=", "HEAD"))
+}
@@ -0,0 +1,222 @@
+package knowledge
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultFetchTimeout bounds a git clone, and an HTTP fetch made without an
+// explicit FetchOptions.Client, so an unresponsive remote cannot hang the
+// caller.
+const DefaultFetchTimeout = 5 * time.Minute
+
+// defaultClient performs HTTP fetches when FetchOptions.Client is nil.
+var defaultClient = &http.Client{Timeout: DefaultFetchTimeout}
+
+// cloneTimeout bounds each git clone.
+var cloneTimeout = DefaultFetchTimeout
+
+// FetchOptions controls FetchWithOptions.
+type FetchOptions struct {
+	// FallbackToCache keeps using a previously fetched dest when the remote
+	// cannot be reached, instead of failing.
+	FallbackToCache bool
+	// OnFallback, if set, is called with the fetch error whenever the cached
+	// copy is used instead, so the caller can warn that it may be out of date.
+	OnFallback func(err error)
+	// Client performs HTTP fetches. Nil uses a client that gives up after
+	// DefaultFetchTimeout.
+	Client *http.Client
+}
+
+// Fetch pulls a remote knowledge source into the local directory dest, which
+// the prompt builder can then point the agent at. See FetchWithOptions.
+func Fetch(source, dest string) error {
+	return FetchWithOptions(source, dest, FetchOptions{})
+}
+
+// FetchWithOptions pulls a remote knowledge source into dest. Supported
+// sources are:
+//
+//   - http(s):// URLs ending in .tar.gz or .tgz, extracted into dest
+//   - other http(s):// URLs, saved as a single file named after the URL path
+//   - git+https:// URLs, shallow-cloned into dest
+//
+// Tarball and git sources replace dest only once the fetch has fully
+// succeeded, so a failed fetch never leaves a half-written cache behind.
+func FetchWithOptions(source, dest string, opts FetchOptions) error {
+	err := fetch(source, dest, opts)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("fetching knowledge from %s: %w", source, err)
+	if opts.FallbackToCache && hasCache(dest) {
+		if opts.OnFallback != nil {
+			opts.OnFallback(err)
+		}
+		return nil
+	}
+	return err
+}
+
+func fetch(source, dest string, opts FetchOptions) error {
+	u, err := url.Parse(source)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if strings.HasSuffix(u.Path, ".tar.gz") || strings.HasSuffix(u.Path, ".tgz") {
+			return replaceDir(dest, func(tmp string) error {
+				return download(opts.Client, source, func(body io.Reader) error { return extractTarGz(body, tmp) })
+			})
+		}
+		name := path.Base(u.Path)
+		if name == "/" || name == "." {
+			return fmt.Errorf("cannot name a single-file source with no path")
+		}
+		return fetchFile(opts.Client, source, filepath.Join(dest, name))
+	case "git+https":
+		repo := strings.TrimPrefix(source, "git+")
+		return replaceDir(dest, func(tmp string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), cloneTimeout)
+			defer cancel()
+			cmd := exec.CommandContext(ctx, "git", "clone", "--quiet", "--depth", "1", repo, tmp)
+			// git's transport helpers can outlive it holding the output pipe.
+			cmd.WaitDelay = time.Second
+			out, err := cmd.CombinedOutput()
+			if ctx.Err() != nil {
+				return fmt.Errorf("git clone: timed out after %s: %w", cloneTimeout, ctx.Err())
+			}
+			if err != nil {
+				return fmt.Errorf("git clone: %w: %s", err, strings.TrimSpace(string(out)))
+			}
+			return os.RemoveAll(filepath.Join(tmp, ".git"))
+		})
+	}
+	return fmt.Errorf("unsupported knowledge source scheme %q (use http, https, or git+https)", u.Scheme)
+}
+
+// download GETs source and passes the response body to read.
+func download(client *http.Client, source string, read func(io.Reader) error) error {
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Get(source)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return read(resp.Body)
+}
+
+// fetchFile downloads source to target, replacing it atomically.
+func fetchFile(client *http.Client, source, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".fetch-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = download(client, source, func(body io.Reader) error {
+		_, err := io.Copy(tmp, body)
+		return err
+	})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// replaceDir runs fill against a fresh temporary sibling of dest and, if it
+// succeeds, swaps the result in for dest.
+func replaceDir(dest string, fill func(tmp string) error) error {
+	parent := filepath.Dir(dest)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(parent, ".fetch-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := fill(tmp); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// extractTarGz unpacks the regular files and directories of a gzipped tarball
+// into dir, rejecting entries that would escape it.
+func extractTarGz(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("reading tarball: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tarball: %w", err)
+		}
+		if !filepath.IsLocal(hdr.Name) {
+			return fmt.Errorf("tarball entry %q escapes the destination", hdr.Name)
+		}
+		target := filepath.Join(dir, hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// hasCache reports whether dest holds a previous fetch.
+func hasCache(dest string) bool {
+	entries, err := os.ReadDir(dest)
+	return err == nil && len(entries) > 0
+}
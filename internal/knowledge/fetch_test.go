@@ -0,0 +1,155 @@
+package knowledge
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tarGz builds a gzipped tarball holding files, keyed by entry name.
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// knowledgeServer serves routes, each a path mapped to a response body.
+func knowledgeServer(t *testing.T, routes map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestFetch_HTTPSingleFile(t *testing.T) {
+	srv := knowledgeServer(t, map[string][]byte{"/kb/conventions.md": []byte("# Conventions")})
+	dest := filepath.Join(t.TempDir(), "team-kb")
+
+	require.NoError(t, Fetch(srv.URL+"/kb/conventions.md", dest))
+	require.Equal(t, "# Conventions", readFile(t, filepath.Join(dest, "conventions.md")))
+}
+
+func TestFetch_HTTPTarballReplacesDest(t *testing.T) {
+	srv := knowledgeServer(t, map[string][]byte{"/kb.tar.gz": tarGz(t, map[string]string{
+		"glossary/terms.md": "terms",
+		"gotchas/pipes.md":  "pipes",
+	})})
+	dest := t.TempDir()
+	writeFile(t, dest, "old/removed.md", "stale")
+
+	require.NoError(t, Fetch(srv.URL+"/kb.tar.gz", dest))
+	require.Equal(t, "terms", readFile(t, filepath.Join(dest, "glossary", "terms.md")))
+	require.Equal(t, "pipes", readFile(t, filepath.Join(dest, "gotchas", "pipes.md")))
+	require.NoFileExists(t, filepath.Join(dest, "old", "removed.md"))
+}
+
+func TestFetch_TarballEntryEscapingDestIsRejected(t *testing.T) {
+	srv := knowledgeServer(t, map[string][]byte{"/kb.tgz": tarGz(t, map[string]string{"../evil.md": "x"})})
+	dest := filepath.Join(t.TempDir(), "kb")
+
+	err := Fetch(srv.URL+"/kb.tgz", dest)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "escapes the destination")
+	require.NoDirExists(t, dest)
+}
+
+func TestFetch_HTTPErrorIsReported(t *testing.T) {
+	srv := knowledgeServer(t, nil)
+
+	err := Fetch(srv.URL+"/missing.tar.gz", filepath.Join(t.TempDir(), "kb"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fetching knowledge from "+srv.URL)
+	require.Contains(t, err.Error(), "404 Not Found")
+}
+
+func TestFetch_DefaultClientTimesOut(t *testing.T) {
+	require.Equal(t, DefaultFetchTimeout, defaultClient.Timeout)
+
+	orig := defaultClient
+	t.Cleanup(func() { defaultClient = orig })
+	defaultClient = &http.Client{Timeout: 50 * time.Millisecond}
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release }))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	err := Fetch(srv.URL+"/kb.tar.gz", filepath.Join(t.TempDir(), "kb"))
+	require.ErrorContains(t, err, "Client.Timeout exceeded")
+}
+
+func TestFetch_GitCloneTimesOut(t *testing.T) {
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "git"), []byte("#!/bin/sh\nexec sleep 10\n"), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	orig := cloneTimeout
+	t.Cleanup(func() { cloneTimeout = orig })
+	cloneTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	err := Fetch("git+https://example.com/team/kb.git", filepath.Join(t.TempDir(), "kb"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "git clone: timed out after 50ms")
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestFetchWithOptions_FallsBackToCache(t *testing.T) {
+	srv := knowledgeServer(t, map[string][]byte{"/kb.tar.gz": tarGz(t, map[string]string{"glossary/terms.md": "v1"})})
+	dest := filepath.Join(t.TempDir(), "kb")
+	require.NoError(t, Fetch(srv.URL+"/kb.tar.gz", dest))
+	srv.Close()
+
+	var warned error
+	err := FetchWithOptions(srv.URL+"/kb.tar.gz", dest, FetchOptions{
+		FallbackToCache: true,
+		OnFallback:      func(err error) { warned = err },
+	})
+	require.NoError(t, err)
+	require.Error(t, warned)
+	require.Equal(t, "v1", readFile(t, filepath.Join(dest, "glossary", "terms.md")))
+
+	require.Error(t, Fetch(srv.URL+"/kb.tar.gz", dest), "without the option the failure is returned")
+}
+
+func TestFetchWithOptions_NoCacheStillFails(t *testing.T) {
+	srv := knowledgeServer(t, nil)
+	err := FetchWithOptions(srv.URL+"/kb.tar.gz", filepath.Join(t.TempDir(), "kb"), FetchOptions{FallbackToCache: true})
+	require.Error(t, err)
+}
+
+func TestFetch_UnsupportedScheme(t *testing.T) {
+	err := Fetch("ftp://example.com/kb.tar.gz", t.TempDir())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `unsupported knowledge source scheme "ftp"`)
+}
@@ -0,0 +1,56 @@
+package knowledge
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StaleKnowledge returns the knowledge files under dir last modified more
+// than maxAge ago, as sorted slash-separated paths relative to dir, so a
+// command can warn that stale knowledge may mislead the agent. The category
+// READMEs written by project init and dotfiles are not knowledge entries and
+// are ignored. A missing dir has no stale files and is not an error.
+func StaleKnowledge(dir string, maxAge time.Duration) ([]string, error) {
+	cutoff := time.Now().Add(-maxAge)
+	var stale []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || d.Name() == "README.md" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(cutoff) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			stale = append(stale, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning knowledge directory %s: %w", dir, err)
+	}
+	slices.Sort(stale)
+	return stale, nil
+}
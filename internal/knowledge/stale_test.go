@@ -0,0 +1,42 @@
+package knowledge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// age sets the modification time of dir/name to d ago.
+func age(t *testing.T, dir, name string, d time.Duration) {
+	t.Helper()
+	when := time.Now().Add(-d)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, filepath.FromSlash(name)), when, when))
+}
+
+func TestStaleKnowledge_MixedAges(t *testing.T) {
+	dir := t.TempDir()
+	day := 24 * time.Hour
+	for _, name := range []string{
+		"architecture/runner.md", "gotchas/pipes.md", "glossary/terms.md",
+		"architecture/README.md", ".cache/index.md",
+	} {
+		writeFile(t, dir, name, "x")
+		age(t, dir, name, 200*day)
+	}
+	writeFile(t, dir, "learnings/fresh.md", "x")
+	age(t, dir, "learnings/fresh.md", 10*day)
+	age(t, dir, "glossary/terms.md", 89*day)
+
+	stale, err := StaleKnowledge(dir, 90*day)
+	require.NoError(t, err)
+	require.Equal(t, []string{"architecture/runner.md", "gotchas/pipes.md"}, stale)
+}
+
+func TestStaleKnowledge_MissingDirectory(t *testing.T) {
+	stale, err := StaleKnowledge(filepath.Join(t.TempDir(), "absent"), time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, stale)
+}
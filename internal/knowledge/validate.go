@@ -0,0 +1,78 @@
+package knowledge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IndexFile is the file every knowledge directory must have at its root.
+const IndexFile = "index.md"
+
+// Violations reported by ValidateKnowledge, each wrapped with the offending
+// path so callers can match them with errors.Is.
+var (
+	ErrMissingIndex = errors.New("missing " + IndexFile)
+	ErrEntryName    = errors.New("name does not match NN-topic.md")
+	ErrEmptyEntry   = errors.New("file is empty")
+)
+
+// entryNamePattern is the naming convention for knowledge entries: a
+// two-digit ordering prefix and a lowercase, hyphenated topic.
+var entryNamePattern = regexp.MustCompile(`^[0-9]{2}-[a-z0-9]+(-[a-z0-9]+)*\.md$`)
+
+// ValidateKnowledge checks the knowledge directory dir against the layout
+// convention, so CI can fail on violations: an index.md at the root, every
+// other entry named NN-topic.md, and no unreadable or empty files. README.md
+// files, as written by project init, and dotfiles are exempt from the naming
+// rule. It returns every violation found, in walk order, or nil when dir is
+// clean. A dir that cannot be scanned at all yields that single error.
+func ValidateKnowledge(dir string) []error {
+	var errs []error
+	if _, err := os.Stat(filepath.Join(dir, IndexFile)); errors.Is(err, os.ErrNotExist) {
+		errs = append(errs, fmt.Errorf("%s: %w", dir, ErrMissingIndex))
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if name := d.Name(); rel != IndexFile && name != "README.md" && !entryNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("%s: %w", rel, ErrEntryName))
+		}
+		data, err := os.ReadFile(path)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", rel, err))
+		case len(bytes.TrimSpace(data)) == 0:
+			errs = append(errs, fmt.Errorf("%s: %w", rel, ErrEmptyEntry))
+		}
+		return nil
+	})
+	if err != nil {
+		return []error{fmt.Errorf("scanning knowledge directory %s: %w", dir, err)}
+	}
+	return errs
+}
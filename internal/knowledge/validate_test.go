@@ -0,0 +1,85 @@
+package knowledge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// validKnowledge writes a knowledge directory that follows every convention.
+func validKnowledge(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "index.md", "# Knowledge\n")
+	writeFile(t, dir, "01-overview.md", "overview")
+	writeFile(t, dir, "architecture/README.md", "about this category")
+	writeFile(t, dir, "architecture/02-runner-events.md", "events")
+	writeFile(t, dir, ".cache/whatever.txt", "")
+	return dir
+}
+
+func TestValidateKnowledge_Clean(t *testing.T) {
+	require.Nil(t, ValidateKnowledge(validKnowledge(t)))
+}
+
+func TestValidateKnowledge_MissingIndex(t *testing.T) {
+	dir := validKnowledge(t)
+	require.NoError(t, os.Remove(filepath.Join(dir, "index.md")))
+
+	errs := ValidateKnowledge(dir)
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], ErrMissingIndex)
+}
+
+func TestValidateKnowledge_NamingConvention(t *testing.T) {
+	dir := validKnowledge(t)
+	writeFile(t, dir, "gotchas/pipes.md", "x")
+	writeFile(t, dir, "gotchas/3-short-prefix.md", "x")
+	writeFile(t, dir, "gotchas/04-Mixed_Case.md", "x")
+	writeFile(t, dir, "gotchas/05-notes.txt", "x")
+	writeFile(t, dir, "gotchas/index.md", "only the root index is exempt")
+
+	errs := ValidateKnowledge(dir)
+	require.Len(t, errs, 5)
+	for _, err := range errs {
+		require.ErrorIs(t, err, ErrEntryName)
+	}
+	require.EqualError(t, errs[0], "gotchas/04-Mixed_Case.md: name does not match NN-topic.md")
+}
+
+func TestValidateKnowledge_EmptyFiles(t *testing.T) {
+	dir := validKnowledge(t)
+	writeFile(t, dir, "index.md", "")
+	writeFile(t, dir, "learnings/03-blank.md", " \n\t\n")
+
+	errs := ValidateKnowledge(dir)
+	require.Len(t, errs, 2)
+	require.EqualError(t, errs[0], "index.md: file is empty")
+	require.EqualError(t, errs[1], "learnings/03-blank.md: file is empty")
+}
+
+func TestValidateKnowledge_UnreadableFile(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root can read files regardless of permissions")
+	}
+	dir := validKnowledge(t)
+	path := filepath.Join(dir, "01-overview.md")
+	require.NoError(t, os.Chmod(path, 0o000))
+
+	errs := ValidateKnowledge(dir)
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], os.ErrPermission)
+}
+
+func TestValidateKnowledge_AggregatesAndReportsMissingDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "notes.md", "")
+
+	require.Len(t, ValidateKnowledge(dir), 3, "missing index, bad name and empty file")
+
+	errs := ValidateKnowledge(filepath.Join(dir, "absent"))
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], os.ErrNotExist)
+}
@@ -0,0 +1,40 @@
+package pricing
+
+// DefaultContextWindow is the context window, in tokens, assumed for models
+// with no registered window. It matches the standard window of the Claude
+// models Spektacular drives by default.
+const DefaultContextWindow = 200_000
+
+var contextWindows = map[string]int{}
+
+// RegisterContextWindow sets the context window, in tokens, for a model name
+// or CLI alias, replacing any existing entry.
+func RegisterContextWindow(model string, tokens int) {
+	mu.Lock()
+	defer mu.Unlock()
+	contextWindows[model] = tokens
+}
+
+// ContextWindow returns the context window registered for model and true,
+// or DefaultContextWindow and false if the model is unknown. A dated snapshot
+// ID falls back to the window of its undated model name.
+func ContextWindow(model string) (int, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if n, ok := contextWindows[model]; ok {
+		return n, true
+	}
+	if n, ok := contextWindows[snapshotSuffix.ReplaceAllString(model, "")]; ok {
+		return n, true
+	}
+	return DefaultContextWindow, false
+}
+
+func init() {
+	for _, model := range []string{
+		"claude-opus-4-5", "claude-opus-4-1", "claude-sonnet-4-5", "claude-haiku-4-5",
+		"opus", "sonnet", "haiku",
+	} {
+		RegisterContextWindow(model, 200_000)
+	}
+}
@@ -0,0 +1,28 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextWindow_PreloadedAndSnapshot(t *testing.T) {
+	n, ok := ContextWindow("claude-sonnet-4-5-20250929")
+	require.True(t, ok)
+	require.Equal(t, 200_000, n)
+}
+
+func TestContextWindow_UnknownModelUsesDefault(t *testing.T) {
+	n, ok := ContextWindow("gpt-x")
+	require.False(t, ok)
+	require.Equal(t, DefaultContextWindow, n)
+}
+
+func TestRegisterContextWindow_Overrides(t *testing.T) {
+	orig, _ := ContextWindow("sonnet")
+	t.Cleanup(func() { RegisterContextWindow("sonnet", orig) })
+
+	RegisterContextWindow("sonnet", 1_000_000)
+	n, _ := ContextWindow("sonnet")
+	require.Equal(t, 1_000_000, n)
+}
@@ -0,0 +1,76 @@
+// Package pricing is the central registry of per-model token prices used by
+// cost estimation. It is preloaded with the published prices of the models
+// Spektacular drives by default; callers may Register overrides, for example
+// to reflect negotiated rates.
+package pricing
+
+import (
+	"regexp"
+	"sync"
+)
+
+// Price holds a model's rates in USD per million tokens.
+type Price struct {
+	Input      float64
+	Output     float64
+	CacheWrite float64 // cache creation input tokens
+	CacheRead  float64 // cache read input tokens
+}
+
+// Cost returns the USD cost of the given token counts at p's rates.
+func (p Price) Cost(input, output, cacheWrite, cacheRead int) float64 {
+	return (float64(input)*p.Input +
+		float64(output)*p.Output +
+		float64(cacheWrite)*p.CacheWrite +
+		float64(cacheRead)*p.CacheRead) / 1_000_000
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Price{}
+)
+
+// Register sets the price for a model name or CLI alias, replacing any
+// existing entry.
+func Register(model string, p Price) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[model] = p
+}
+
+// snapshotSuffix matches the dated snapshot suffix of a model ID, as in
+// "claude-sonnet-4-5-20250929".
+var snapshotSuffix = regexp.MustCompile(`-\d{8}$`)
+
+// Lookup returns the price registered for model and true, or a zero Price and
+// false if the model is unknown. A dated snapshot ID falls back to the price of
+// its undated model name.
+func Lookup(model string) (Price, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if p, ok := registry[model]; ok {
+		return p, true
+	}
+	p, ok := registry[snapshotSuffix.ReplaceAllString(model, "")]
+	return p, ok
+}
+
+// anthropic returns a Price using Anthropic's standard cache multipliers:
+// writes at 1.25x and reads at 0.1x the input rate.
+func anthropic(input, output float64) Price {
+	return Price{Input: input, Output: output, CacheWrite: input * 1.25, CacheRead: input / 10}
+}
+
+func init() {
+	for model, p := range map[string]Price{
+		"claude-opus-4-5":   anthropic(5, 25),
+		"claude-opus-4-1":   anthropic(15, 75),
+		"claude-sonnet-4-5": anthropic(3, 15),
+		"claude-haiku-4-5":  anthropic(1, 5),
+		"opus":              anthropic(5, 25),
+		"sonnet":            anthropic(3, 15),
+		"haiku":             anthropic(1, 5),
+	} {
+		Register(model, p)
+	}
+}
@@ -0,0 +1,46 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup_PreloadedModel(t *testing.T) {
+	p, ok := Lookup("claude-sonnet-4-5")
+	require.True(t, ok)
+	require.Equal(t, Price{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3}, p)
+
+	alias, ok := Lookup("sonnet")
+	require.True(t, ok)
+	require.Equal(t, p, alias)
+}
+
+func TestLookup_SnapshotFallsBackToModelName(t *testing.T) {
+	want, _ := Lookup("claude-sonnet-4-5")
+	p, ok := Lookup("claude-sonnet-4-5-20250929")
+	require.True(t, ok)
+	require.Equal(t, want, p)
+}
+
+func TestRegister_OverridesExistingPrice(t *testing.T) {
+	orig, _ := Lookup("haiku")
+	t.Cleanup(func() { Register("haiku", orig) })
+
+	Register("haiku", Price{Input: 0.5, Output: 2})
+	p, ok := Lookup("haiku")
+	require.True(t, ok)
+	require.Equal(t, Price{Input: 0.5, Output: 2}, p)
+}
+
+func TestLookup_UnknownModel(t *testing.T) {
+	p, ok := Lookup("gpt-x")
+	require.False(t, ok)
+	require.Zero(t, p)
+}
+
+func TestPrice_Cost(t *testing.T) {
+	p := Price{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3}
+	require.InDelta(t, 3+15+3.75+0.3, p.Cost(1_000_000, 1_000_000, 1_000_000, 1_000_000), 1e-9)
+	require.InDelta(t, 0.0045, p.Cost(1000, 100, 0, 0), 1e-9)
+}
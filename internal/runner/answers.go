@@ -0,0 +1,180 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadAnswers reads an answers file mapping question headers to answers — a
+// chosen option label or free text — for reproducible non-interactive runs.
+// The format is chosen by extension: .yaml, .yml, or .json.
+func LoadAnswers(path string) (map[string]string, error) {
+	var unmarshal func([]byte, any) error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	case ".json":
+		unmarshal = json.Unmarshal
+	default:
+		return nil, fmt.Errorf("answers file %s: unsupported extension %q (must be .yaml, .yml, or .json)", path, ext)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading answers file %s: %w", path, err)
+	}
+	answers := map[string]string{}
+	if err := unmarshal(raw, &answers); err != nil {
+		return nil, fmt.Errorf("parsing answers file %s: %w", path, err)
+	}
+	return answers, nil
+}
+
+// SkipAnswer is the answer that dismisses a question: the user has no
+// preference and the agent should carry on with its own judgement. It can be
+// used in answers files and answer maps like any other answer.
+const SkipAnswer = "<skip>"
+
+// Multi-select shortcuts, expanded against a MultiSelect question's options
+// when answers are formatted. An empty answer also selects none.
+const (
+	AnswerAll  = "*"
+	AnswerNone = "-"
+)
+
+// FormatAnswers renders the answers for qs, keyed by question header, as the
+// user message that resumes the agent session. A SkipAnswer is rendered as an
+// explicit "no preference" instruction rather than as a selection, and
+// multi-select answers are expanded with ExpandMultiSelect.
+func FormatAnswers(qs []Question, answers map[string]string) string {
+	var b strings.Builder
+	b.WriteString("Answers to your questions:\n")
+	for _, q := range qs {
+		fmt.Fprintf(&b, "\n- %s (%s): %s", q.Header, q.Question, formatAnswer(q, answers[q.Header]))
+	}
+	return b.String()
+}
+
+// formatAnswer renders one answer, expanding SkipAnswer and multi-select
+// shortcuts.
+func formatAnswer(q Question, answer string) string {
+	if answer != SkipAnswer {
+		if !q.MultiSelect {
+			return answer
+		}
+		selected := ExpandMultiSelect(q, answer)
+		if len(selected) == 0 {
+			return "None of the options."
+		}
+		return strings.Join(selected, ", ")
+	}
+	if q.Default != "" {
+		return fmt.Sprintf("No preference. Proceed with the default (%s).", q.Default)
+	}
+	return "No preference. Proceed with your best judgement and note the choice you made."
+}
+
+// ExpandMultiSelect returns the labels a multi-select answer selects:
+// AnswerAll selects every option, AnswerNone or an empty answer selects none,
+// and anything else is a comma-separated list of labels. Listed labels are
+// returned in option order, followed by any that match no option, each once.
+func ExpandMultiSelect(q Question, answer string) []string {
+	labels := q.OptionLabels()
+	switch answer = strings.TrimSpace(answer); answer {
+	case AnswerAll:
+		return labels
+	case AnswerNone, "":
+		return nil
+	}
+
+	chosen := map[string]bool{}
+	var order []string
+	for _, part := range strings.Split(answer, ",") {
+		if part = strings.TrimSpace(part); part != "" && !chosen[part] {
+			chosen[part] = true
+			order = append(order, part)
+		}
+	}
+	var selected []string
+	for _, label := range labels {
+		if chosen[label] {
+			selected = append(selected, label)
+			delete(chosen, label)
+		}
+	}
+	for _, part := range order {
+		if chosen[part] {
+			selected = append(selected, part)
+		}
+	}
+	return selected
+}
+
+// PredefinedAnswers returns an onQuestion callback for RunSteps that answers
+// from answers, keyed by question header, falling back to each question's
+// Default. If any question is left unanswered, or a stored answer fails
+// CheckAnswer, the whole batch is handed to fallback; with a nil fallback the
+// unanswered questions get an empty answer. Setting RunOptions.Answers has
+// RunStepsWithOptions and Plan answer this way without further wiring.
+func PredefinedAnswers(answers map[string]string, fallback func([]Question) string) func([]Question) string {
+	return func(qs []Question) string {
+		resolved := make(map[string]string, len(qs))
+		for _, q := range qs {
+			if a, ok := resolveAnswer(q, answers); ok {
+				resolved[q.Header] = a
+				continue
+			}
+			if fallback != nil {
+				return fallback(qs)
+			}
+		}
+		return FormatAnswers(qs, resolved)
+	}
+}
+
+// UnansweredQuestions returns the questions in qs that PredefinedAnswers
+// could not answer from answers: those with no stored answer and no Default,
+// or whose stored answer fails CheckAnswer. A lint step can use it to check
+// an answers file covers a spec's questions before a non-interactive run.
+func UnansweredQuestions(qs []Question, answers map[string]string) []Question {
+	var missing []Question
+	for _, q := range qs {
+		if _, ok := resolveAnswer(q, answers); !ok {
+			missing = append(missing, q)
+		}
+	}
+	return missing
+}
+
+// ValidateAnswers checks each stored answer in answers against its question
+// in qs with CheckAnswer and returns all violations joined, or nil.
+func ValidateAnswers(qs []Question, answers map[string]string) error {
+	var errs []error
+	for _, q := range qs {
+		if a, ok := answers[q.Header]; ok {
+			if err := q.CheckAnswer(a); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolveAnswer returns the stored answer for q, keyed by its header, or its
+// Default, reporting false if it has neither. A stored answer that fails
+// CheckAnswer is not used, and neither is the Default in its place.
+func resolveAnswer(q Question, answers map[string]string) (string, bool) {
+	if a, ok := answers[q.Header]; ok {
+		return a, q.CheckAnswer(a) == nil
+	}
+	if q.Default != "" {
+		return q.Default, true
+	}
+	return "", false
+}
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeAnswersFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadAnswers_YAML(t *testing.T) {
+	path := writeAnswersFile(t, "answers.yaml", "Approach: Option A\nBranch: feature/auth\n")
+
+	answers, err := LoadAnswers(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"Approach": "Option A", "Branch": "feature/auth"}, answers)
+}
+
+func TestLoadAnswers_JSON(t *testing.T) {
+	path := writeAnswersFile(t, "answers.json", `{"Approach":"Option B"}`)
+
+	answers, err := LoadAnswers(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"Approach": "Option B"}, answers)
+}
+
+func TestLoadAnswers_BadExtension(t *testing.T) {
+	path := writeAnswersFile(t, "answers.toml", `Approach = "A"`)
+
+	_, err := LoadAnswers(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `unsupported extension ".toml"`)
+}
+
+func TestLoadAnswers_MalformedFile(t *testing.T) {
+	path := writeAnswersFile(t, "answers.json", `{"Approach":`)
+
+	_, err := LoadAnswers(path)
+	require.ErrorContains(t, err, "parsing answers file")
+}
+
+func TestPredefinedAnswers_AnswersFromMapAndDefaults(t *testing.T) {
+	qs := []Question{
+		{Question: "Which approach?", Header: "Approach"},
+		{Question: "Which database?", Header: "Database", Default: "Postgres"},
+	}
+	called := false
+	onQuestion := PredefinedAnswers(map[string]string{"Approach": "Option A"}, func([]Question) string {
+		called = true
+		return ""
+	})
+
+	answer := onQuestion(qs)
+	require.False(t, called)
+	require.Contains(t, answer, "- Approach (Which approach?): Option A")
+	require.Contains(t, answer, "- Database (Which database?): Postgres")
+}
+
+func TestPredefinedAnswers_DelegatesUnansweredToFallback(t *testing.T) {
+	qs := []Question{{Question: "Which approach?", Header: "Approach"}, {Question: "Name?", Header: "Name"}}
+	onQuestion := PredefinedAnswers(map[string]string{"Approach": "A"}, func(got []Question) string {
+		require.Equal(t, qs, got)
+		return "asked a human"
+	})
+
+	require.Equal(t, "asked a human", onQuestion(qs))
+}
+
+func TestUnansweredQuestions(t *testing.T) {
+	qs := []Question{
+		{Question: "Which approach?", Header: "Approach"},
+		{Question: "Which database?", Header: "Database", Default: "Postgres"},
+		{Question: "Which cache?", Header: "Cache"},
+	}
+
+	require.Empty(t, UnansweredQuestions(qs, map[string]string{"Approach": "A", "Database": "MySQL", "Cache": SkipAnswer}), "fully covered")
+	require.Equal(t, []Question{qs[2]}, UnansweredQuestions(qs, map[string]string{"Approach": "A"}), "partially covered")
+	require.Equal(t, []Question{qs[0], qs[2]}, UnansweredQuestions(qs, nil), "only the default covers Database")
+	require.Empty(t, UnansweredQuestions(nil, nil))
+}
+
+func TestAnswers_PatternMismatchIsUnanswered(t *testing.T) {
+	q := branchQuestion()
+	q.Default = "feature/default"
+	qs := []Question{q}
+
+	require.Empty(t, UnansweredQuestions(qs, map[string]string{"Branch": "feature/auth"}))
+	require.Equal(t, qs, UnansweredQuestions(qs, map[string]string{"Branch": "main"}), "a bad answer is not replaced by the default")
+	require.NoError(t, ValidateAnswers(qs, map[string]string{"Branch": "feature/auth"}))
+	require.ErrorContains(t, ValidateAnswers(qs, map[string]string{"Branch": "main"}), `answer "main" does not match`)
+
+	onQuestion := PredefinedAnswers(map[string]string{"Branch": "main"}, func([]Question) string { return "asked a human" })
+	require.Equal(t, "asked a human", onQuestion(qs))
+}
+
+func TestFormatAnswers_SkipAnswer(t *testing.T) {
+	qs := []Question{
+		{Question: "Which approach?", Header: "Approach"},
+		{Question: "Which database?", Header: "Database", Default: "Postgres"},
+		{Question: "Which cache?", Header: "Cache"},
+	}
+	answer := FormatAnswers(qs, map[string]string{"Approach": SkipAnswer, "Database": SkipAnswer, "Cache": "Redis"})
+
+	require.Contains(t, answer, "- Approach (Which approach?): No preference. Proceed with your best judgement")
+	require.Contains(t, answer, "- Database (Which database?): No preference. Proceed with the default (Postgres).")
+	require.Contains(t, answer, "- Cache (Which cache?): Redis")
+	require.NotContains(t, answer, SkipAnswer)
+}
+
+func TestPredefinedAnswers_SkipDiffersFromSelection(t *testing.T) {
+	qs := []Question{{Question: "Which approach?", Header: "Approach", Default: "Option A"}}
+
+	skipped := PredefinedAnswers(map[string]string{"Approach": SkipAnswer}, nil)(qs)
+	selected := PredefinedAnswers(map[string]string{"Approach": "Option A"}, nil)(qs)
+	require.NotEqual(t, selected, skipped)
+	require.Contains(t, skipped, "No preference")
+}
+
+func multiSelectQuestion(labels ...string) Question {
+	q := choiceQuestion("", labels...)
+	q.Header, q.Question, q.MultiSelect = "Features", "Which features?", true
+	return q
+}
+
+func TestExpandMultiSelect(t *testing.T) {
+	q := multiSelectQuestion("Auth", "Billing", "Search")
+
+	require.Equal(t, []string{"Auth", "Billing", "Search"}, ExpandMultiSelect(q, AnswerAll))
+	require.Empty(t, ExpandMultiSelect(q, ""))
+	require.Empty(t, ExpandMultiSelect(q, AnswerNone))
+	require.Equal(t, []string{"Auth", "Search"}, ExpandMultiSelect(q, " Search, Auth ,Search"))
+	require.Equal(t, []string{"Billing", "Audit log"}, ExpandMultiSelect(q, "Audit log, Billing"), "unlisted labels follow the options")
+}
+
+func TestFormatAnswers_MultiSelectShortcuts(t *testing.T) {
+	qs := []Question{multiSelectQuestion("Auth", "Billing"), {Question: "Which approach?", Header: "Approach"}}
+
+	require.Contains(t, FormatAnswers(qs, map[string]string{"Features": "*"}), "- Features (Which features?): Auth, Billing")
+	require.Contains(t, FormatAnswers(qs, map[string]string{"Features": "-"}), "- Features (Which features?): None of the options.")
+	require.Contains(t, FormatAnswers(qs, map[string]string{"Features": ""}), "- Features (Which features?): None of the options.")
+	require.Contains(t, FormatAnswers(qs, map[string]string{"Features": "Billing", "Approach": "*"}), "- Approach (Which approach?): *",
+		"shortcuts only apply to multi-select questions")
+}
+
+func TestDetectQuestions_MultiSelect(t *testing.T) {
+	qs := detectQuestions(`<!--QUESTION:{"questions":[{"question":"Q?","header":"H","type":"choice","multiSelect":true,"options":[{"label":"A"}]},{"question":"T?","header":"T","multiSelect":true}]}-->`)
+	require.True(t, qs[0].MultiSelect)
+	require.False(t, qs[1].MultiSelect, "a text question cannot be multi-select")
+}
+
+// askingRunner asks which database to use in a new session and, once resumed,
+// records the answers it was given and finishes.
+type askingRunner struct {
+	answered string
+}
+
+func (a *askingRunner) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	events := make(chan Event, 2)
+	errc := make(chan error)
+	if opts.SessionID == "" {
+		events <- Event{Type: "system", Data: map[string]any{"session_id": "s1"}}
+		events <- assistantBlocks(map[string]any{"type": "text", "text": `<!--QUESTION:{"questions":[{"question":"Which DB?","header":"Database"}]}-->`})
+	} else {
+		a.answered = opts.Prompts.User
+		events <- Event{Type: "result", Data: map[string]any{"result": "done"}}
+	}
+	close(events)
+	close(errc)
+	return events, errc
+}
+
+func TestRunStepsWithOptions_AnswersFromRunOptions(t *testing.T) {
+	r := &askingRunner{}
+	steps := []Step{{Prompts: Prompts{User: "plan"}}}
+	base := RunOptions{Answers: map[string]string{"Database": "Postgres"}}
+	onQuestion := func([]Question) string {
+		t.Fatal("a question covered by Answers reached onQuestion")
+		return ""
+	}
+
+	require.NoError(t, RunStepsWithOptions(r, steps, base, nil, onQuestion))
+	require.Contains(t, r.answered, "- Database (Which DB?): Postgres")
+
+	asked := false
+	err := RunStepsWithOptions(&askingRunner{}, steps, RunOptions{Answers: map[string]string{"Cache": "Redis"}}, nil, func([]Question) string {
+		asked = true
+		return "asked a human"
+	})
+	require.NoError(t, err)
+	require.True(t, asked, "questions Answers does not cover fall back to onQuestion")
+}
@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchOptions controls RunBatch.
+type BatchOptions struct {
+	// Concurrency caps how many runs are in flight at once. Zero or
+	// negative runs them all concurrently.
+	Concurrency int
+
+	// CancelAllOnBudget cancels every other run once one ends with
+	// RunStatusBudgetExceeded: its metered spend reached its MaxCostUSD,
+	// which Supervise enforces, or the agent stopped at its own spend cap.
+	CancelAllOnBudget bool
+
+	// CancelAllOnError cancels every other run once one ends with
+	// RunStatusError.
+	CancelAllOnError bool
+}
+
+// BatchResult is the outcome of one run in a batch.
+type BatchResult struct {
+	Events []Event
+	Status RunStatus
+	Err    error
+}
+
+// RunBatch runs each of runs with r and returns their results in the same
+// order. Every run gets a Context derived from ctx, so cancelling ctx — or a
+// budget or error outcome when the matching BatchOptions flag is set —
+// cancels the runs still in flight and skips those not yet started. Cancelled
+// runs still report the events they produced, with RunStatusCancelled.
+func RunBatch(ctx context.Context, r Runner, runs []RunOptions, opts BatchOptions) []BatchResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	limit := opts.Concurrency
+	if limit <= 0 || limit > len(runs) {
+		limit = len(runs)
+	}
+	slots := make(chan struct{}, limit)
+	results := make([]BatchResult, len(runs))
+
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		// Slots are taken in order, so runs start in the order given and
+		// none starts after the batch is cancelled.
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			results[i] = BatchResult{Status: RunStatusCancelled, Err: err}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			run.Context = ctx
+			res := runOne(r, run)
+			results[i] = res
+			if (opts.CancelAllOnBudget && res.Status == RunStatusBudgetExceeded) ||
+				(opts.CancelAllOnError && res.Status == RunStatusError) {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runOne drains a single run. The run's own Context stops it, so draining
+// uses no deadline and never abandons the runner mid-send.
+func runOne(r Runner, opts RunOptions) BatchResult {
+	var res BatchResult
+	events, errc := r.Run(opts)
+	res.Err = Drain(context.Background(), events, errc, func(e Event) {
+		res.Events = append(res.Events, e)
+	})
+	res.Status = FinalStatus(res.Events, res.Err)
+	return res
+}
@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// shellRunner runs each prompt as a shell script, standing in for an agent
+// that honours RunOptions.Context.
+type shellRunner struct{}
+
+func (shellRunner) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	return RunCommand(fakeProcess(opts.Prompts.User), opts)
+}
+
+const (
+	// budgetScript spends $3 of claude-sonnet-4-5 input, over the $1 budget
+	// batchOf gives every run, and would then carry on working.
+	budgetScript = `echo '{"type":"assistant","message":{"id":"m1","model":"claude-sonnet-4-5","usage":{"input_tokens":1000000}}}'; sleep 10`
+	slowScript   = `echo '{"type":"system","session_id":"slow"}'; sleep 1; echo '{"type":"result","subtype":"success","result":"plan"}'`
+)
+
+// barrierRunner holds every run until n runs have started, then hands each
+// to inner, failing them all if the n do not start together.
+type barrierRunner struct {
+	inner   Runner
+	started sync.WaitGroup
+}
+
+func newBarrierRunner(inner Runner, n int) *barrierRunner {
+	b := &barrierRunner{inner: inner}
+	b.started.Add(n)
+	return b
+}
+
+func (b *barrierRunner) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	b.started.Done()
+	all := make(chan struct{})
+	go func() { b.started.Wait(); close(all) }()
+	select {
+	case <-all:
+		return b.inner.Run(opts)
+	case <-time.After(5 * time.Second):
+		return failed(errors.New("runs did not start concurrently"))
+	}
+}
+
+func batchOf(scripts ...string) []RunOptions {
+	runs := make([]RunOptions, len(scripts))
+	for i, s := range scripts {
+		runs[i] = RunOptions{Prompts: Prompts{User: s}, DiscardStderr: true, MaxCostUSD: 1}
+	}
+	return runs
+}
+
+func TestRunBatch_BudgetHitCancelsSiblingsWhenEnabled(t *testing.T) {
+	got := RunBatch(context.Background(), newBarrierRunner(shellRunner{}, 3), batchOf(budgetScript, slowScript, slowScript),
+		BatchOptions{CancelAllOnBudget: true})
+
+	require.Len(t, got, 3)
+	require.Equal(t, RunStatusBudgetExceeded, got[0].Status)
+	require.ErrorIs(t, got[0].Err, ErrBudgetExceeded, "the run's own budget enforcement stopped it")
+	for _, res := range got[1:] {
+		require.Equal(t, RunStatusCancelled, res.Status)
+		require.ErrorIs(t, res.Err, context.Canceled)
+	}
+}
+
+func TestRunBatch_BudgetHitLeavesSiblingsWhenDisabled(t *testing.T) {
+	got := RunBatch(context.Background(), shellRunner{}, batchOf(budgetScript, slowScript), BatchOptions{})
+
+	require.Equal(t, RunStatusBudgetExceeded, got[0].Status)
+	require.Equal(t, RunStatusSuccess, got[1].Status)
+	require.NoError(t, got[1].Err)
+	require.Len(t, got[1].Events, 2)
+}
+
+func TestRunBatch_AgentBudgetStopCancelsSiblings(t *testing.T) {
+	agentStop := `echo '{"type":"result","subtype":"error_max_budget_usd","is_error":true}'`
+
+	got := RunBatch(context.Background(), shellRunner{}, batchOf(agentStop, slowScript),
+		BatchOptions{CancelAllOnBudget: true})
+
+	require.Equal(t, RunStatusBudgetExceeded, got[0].Status, "the agent stopped at its own spend cap")
+	require.Equal(t, RunStatusCancelled, got[1].Status)
+}
+
+func TestRunBatch_CancelledRunKeepsPartialEvents(t *testing.T) {
+	got := RunBatch(context.Background(), shellRunner{}, batchOf(`sleep 0.3; exit 1`, slowScript),
+		BatchOptions{CancelAllOnError: true})
+
+	require.Equal(t, RunStatusError, got[0].Status)
+	require.Equal(t, RunStatusCancelled, got[1].Status)
+	require.Len(t, got[1].Events, 1)
+	require.Equal(t, "slow", got[1].Events[0].SessionID())
+}
+
+func TestRunBatch_UnstartedRunsSkippedAfterCancel(t *testing.T) {
+	got := RunBatch(context.Background(), shellRunner{}, batchOf(budgetScript, slowScript, slowScript),
+		BatchOptions{Concurrency: 1, CancelAllOnBudget: true})
+
+	require.Equal(t, RunStatusBudgetExceeded, got[0].Status)
+	for _, res := range got[1:] {
+		require.Equal(t, RunStatusCancelled, res.Status)
+		require.Empty(t, res.Events)
+	}
+}
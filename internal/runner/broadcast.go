@@ -0,0 +1,65 @@
+package runner
+
+// SlowConsumerPolicy decides what Broadcast does when a consumer's buffer is full.
+type SlowConsumerPolicy int
+
+const (
+	// SlowConsumerBlock waits for the consumer, pacing every consumer to the
+	// slowest one. Nothing is lost.
+	SlowConsumerBlock SlowConsumerPolicy = iota
+	// SlowConsumerDrop skips the event for a consumer whose buffer is full,
+	// so one stalled consumer cannot hold up the others.
+	SlowConsumerDrop
+)
+
+// DefaultBroadcastBuffer is the per-consumer buffer Broadcast uses, matching
+// the buffer of a runner's own events channel.
+const DefaultBroadcastBuffer = 64
+
+// BroadcastOptions controls BroadcastWithOptions.
+type BroadcastOptions struct {
+	Buffer int // per-consumer channel buffer; zero or negative means unbuffered
+	Policy SlowConsumerPolicy
+}
+
+// Broadcast fans in out to n channels that each receive every event, in
+// order, with DefaultBroadcastBuffer of buffering and SlowConsumerBlock. This
+// lets a run be rendered, recorded, and forwarded at the same time. All
+// returned channels are closed once in is closed.
+func Broadcast(in <-chan Event, n int) []<-chan Event {
+	return BroadcastWithOptions(in, n, BroadcastOptions{Buffer: DefaultBroadcastBuffer})
+}
+
+// BroadcastWithOptions is Broadcast with explicit buffering and slow-consumer
+// policy. With SlowConsumerBlock every consumer must keep reading, or the
+// source, and so the run, stalls.
+func BroadcastWithOptions(in <-chan Event, n int, opts BroadcastOptions) []<-chan Event {
+	buffer := max(opts.Buffer, 0)
+	outs := make([]chan Event, n)
+	result := make([]<-chan Event, n)
+	for i := range outs {
+		outs[i] = make(chan Event, buffer)
+		result[i] = outs[i]
+	}
+
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for e := range in {
+			for _, out := range outs {
+				if opts.Policy == SlowConsumerDrop {
+					select {
+					case out <- e:
+					default:
+					}
+					continue
+				}
+				out <- e
+			}
+		}
+	}()
+	return result
+}
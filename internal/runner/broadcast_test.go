@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// feed returns a closed channel carrying n numbered events.
+func feed(n int) <-chan Event {
+	in := make(chan Event, n)
+	for i := range n {
+		in <- Event{Type: "assistant", Data: map[string]any{"i": i}}
+	}
+	close(in)
+	return in
+}
+
+func TestBroadcast_EveryConsumerReceivesEveryEvent(t *testing.T) {
+	outs := Broadcast(feed(200), 3)
+	require.Len(t, outs, 3)
+
+	got := make([][]int, len(outs))
+	var wg sync.WaitGroup
+	for i, out := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range out {
+				got[i] = append(got[i], e.Data["i"].(int))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, seq := range got {
+		require.Len(t, seq, 200)
+		for i, v := range seq {
+			require.Equal(t, i, v)
+		}
+	}
+}
+
+func TestBroadcast_DropPolicySkipsStalledConsumer(t *testing.T) {
+	in := make(chan Event)
+	outs := BroadcastWithOptions(in, 2, BroadcastOptions{Buffer: 2, Policy: SlowConsumerDrop})
+
+	// Consumer 0 keeps up; consumer 1 never reads until the end.
+	var fast []string
+	for _, typ := range []string{"a", "b", "c", "d", "e"} {
+		in <- Event{Type: typ}
+		fast = append(fast, (<-outs[0]).Type)
+	}
+	close(in)
+
+	var slow []string
+	for e := range outs[1] {
+		slow = append(slow, e.Type)
+	}
+	require.Equal(t, []string{"a", "b", "c", "d", "e"}, fast)
+	require.Equal(t, []string{"a", "b"}, slow, "events beyond the stalled consumer's buffer are dropped")
+}
+
+func TestBroadcast_BlockPolicyPacesToSlowestConsumer(t *testing.T) {
+	in := make(chan Event)
+	outs := BroadcastWithOptions(in, 2, BroadcastOptions{Buffer: 1})
+
+	in <- Event{Type: "a"}
+	require.Equal(t, "a", (<-outs[0]).Type)
+	in <- Event{Type: "b"} // delivered to consumer 0; consumer 1 is still full
+
+	sent := make(chan struct{})
+	go func() {
+		in <- Event{Type: "c"}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("source was not held back by the unread consumer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.Equal(t, "a", (<-outs[1]).Type)
+	<-sent
+	close(in)
+
+	rest := make([][]string, len(outs))
+	var wg sync.WaitGroup
+	for i, out := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range out {
+				rest[i] = append(rest[i], e.Type)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, [][]string{{"b", "c"}, {"b", "c"}}, rest)
+}
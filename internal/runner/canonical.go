@@ -0,0 +1,112 @@
+package runner
+
+// The canonical event schema is the Claude CLI's stream-json shape, which
+// every Event accessor reads:
+//
+//   - "system" events with subtype "init" carry session_id and model.
+//   - "assistant" events carry message.model, message.usage and
+//     message.content, a list of "text" and "tool_use" (id, name, input)
+//     blocks.
+//   - "user" events carry message.content "tool_result" blocks
+//     (tool_use_id, is_error, content).
+//   - "result" events carry subtype, is_error, result, session_id, usage,
+//     num_turns, duration_ms and total_cost_usd.
+//
+// Runners for other agents translate their CLI's events into this shape with
+// a normalizer, so downstream code never needs to know which agent ran.
+
+// Normalizer rewrites one event in an agent's own schema into the canonical
+// schema.
+type Normalizer func(Event) Event
+
+var normalizers = map[string]Normalizer{
+	"claude": func(e Event) Event { return e },
+	"gemini": normalizeGemini,
+}
+
+// RegisterNormalizer adds the normalizer for events from source, a runner
+// name, replacing any existing one. It is typically called from an init()
+// function in the runner's package.
+func RegisterNormalizer(source string, n Normalizer) {
+	normalizers[source] = n
+}
+
+// Canonicalize returns e, emitted by the agent source names, in the canonical
+// schema, so accessors such as TextContent, ToolUses, Usage and ResultText
+// behave the same whichever agent produced it. Raw is kept as the agent
+// emitted it. Events from a source with no normalizer, and events a
+// normalizer does not recognise, are returned unchanged.
+func Canonicalize(e Event, source string) Event {
+	n, ok := normalizers[source]
+	if !ok {
+		return e
+	}
+	return n(e)
+}
+
+// normalizeGemini maps the Gemini CLI's stream-json events: init, message,
+// tool_use, tool_result and result.
+func normalizeGemini(e Event) Event {
+	d := e.Data
+	out := Event{Raw: e.Raw}
+	switch e.Type {
+	case "init":
+		out.Type = "system"
+		out.Data = map[string]any{"type": "system", "subtype": "init", "session_id": d["session_id"], "model": d["model"]}
+	case "message":
+		role, _ := d["role"].(string)
+		if role != "assistant" && role != "user" {
+			return e
+		}
+		out.Type = role
+		out.Data = map[string]any{"type": role, "message": map[string]any{
+			"role":    role,
+			"content": []any{map[string]any{"type": "text", "text": d["content"]}},
+		}}
+	case "tool_use":
+		out.Type = "assistant"
+		out.Data = map[string]any{"type": "assistant", "message": map[string]any{
+			"role": "assistant",
+			"content": []any{map[string]any{
+				"type": "tool_use", "id": d["tool_id"], "name": d["tool_name"], "input": d["parameters"],
+			}},
+		}}
+	case "tool_result":
+		status, _ := d["status"].(string)
+		output := d["output"]
+		if status == "error" {
+			if errInfo, ok := d["error"].(map[string]any); ok && output == nil {
+				output = errInfo["message"]
+			}
+		}
+		out.Type = "user"
+		out.Data = map[string]any{"type": "user", "message": map[string]any{
+			"role": "user",
+			"content": []any{map[string]any{
+				"type": "tool_result", "tool_use_id": d["tool_id"], "is_error": status == "error", "content": output,
+			}},
+		}}
+	case "result":
+		status, _ := d["status"].(string)
+		stats, _ := d["stats"].(map[string]any)
+		data := map[string]any{"type": "result", "subtype": "success", "is_error": status == "error"}
+		if stats != nil {
+			data["usage"] = map[string]any{"input_tokens": stats["input_tokens"], "output_tokens": stats["output_tokens"]}
+			data["duration_ms"] = stats["duration_ms"]
+		}
+		if status == "error" {
+			data["subtype"] = "error_during_execution"
+			if errInfo, ok := d["error"].(map[string]any); ok {
+				data["result"] = errInfo["message"]
+			}
+		}
+		if id, ok := d["session_id"]; ok {
+			data["session_id"] = id
+		}
+		out.Type = "result"
+		out.Data = data
+	default:
+		return e
+	}
+	return out
+}
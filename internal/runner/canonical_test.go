@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rawEvent decodes one line of agent output the way the stream decoder does.
+func rawEvent(t *testing.T, line string) Event {
+	t.Helper()
+	var data map[string]any
+	require.NoError(t, json.Unmarshal([]byte(line), &data))
+	eventType, _ := data["type"].(string)
+	return Event{Type: eventType, Data: data, Raw: json.RawMessage(line)}
+}
+
+// canonicalRun decodes lines from source and canonicalizes each event.
+func canonicalRun(t *testing.T, source string, lines ...string) []Event {
+	t.Helper()
+	events := make([]Event, len(lines))
+	for i, line := range lines {
+		events[i] = Canonicalize(rawEvent(t, line), source)
+	}
+	return events
+}
+
+func TestCanonicalize_GeminiMatchesClaude(t *testing.T) {
+	claude := canonicalRun(t, "claude",
+		`{"type":"system","subtype":"init","session_id":"s1","model":"m1"}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Reading the spec"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"/repo/spec.md"}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"permission denied"}]}}`,
+		`{"type":"result","subtype":"success","is_error":false,"session_id":"s1","duration_ms":1200,"usage":{"input_tokens":300,"output_tokens":40}}`,
+	)
+	gemini := canonicalRun(t, "gemini",
+		`{"type":"init","timestamp":"2025-10-10T12:00:00Z","session_id":"s1","model":"m1"}`,
+		`{"type":"message","timestamp":"2025-10-10T12:00:01Z","role":"assistant","content":"Reading the spec","delta":true}`,
+		`{"type":"tool_use","timestamp":"2025-10-10T12:00:02Z","tool_name":"Read","tool_id":"t1","parameters":{"file_path":"/repo/spec.md"}}`,
+		`{"type":"tool_result","timestamp":"2025-10-10T12:00:03Z","tool_id":"t1","status":"error","error":{"type":"permission","message":"permission denied"}}`,
+		`{"type":"result","timestamp":"2025-10-10T12:00:04Z","status":"success","stats":{"total_tokens":340,"input_tokens":300,"output_tokens":40,"duration_ms":1200,"tool_calls":1}}`,
+	)
+
+	require.Len(t, gemini, len(claude))
+	for i := range claude {
+		c, g := claude[i], gemini[i]
+		require.Equal(t, c.Type, g.Type, i)
+		require.Equal(t, c.TextContent(), g.TextContent(), i)
+		require.Equal(t, c.ToolUses(), g.ToolUses(), i)
+		require.Equal(t, c.HasToolError(), g.HasToolError(), i)
+		require.Equal(t, c.toolErrorText(), g.toolErrorText(), i)
+		cu, cok := c.Usage()
+		gu, gok := g.Usage()
+		require.Equal(t, cok, gok, i)
+		require.Equal(t, cu, gu, i)
+		require.Equal(t, c.IsResult(), g.IsResult(), i)
+		require.Equal(t, c.IsError(), g.IsError(), i)
+	}
+	require.Equal(t, "s1", gemini[0].SessionID())
+	init, ok := gemini[0].SystemInit()
+	require.True(t, ok)
+	require.Equal(t, "m1", init.Model)
+	require.Equal(t, RunMetadata(claude), RunMetadata(gemini))
+	require.Equal(t, rawEvent(t, `{"type":"init","timestamp":"2025-10-10T12:00:00Z","session_id":"s1","model":"m1"}`).Raw, gemini[0].Raw,
+		"Raw keeps the agent's own line")
+}
+
+func TestCanonicalize_GeminiErrorResult(t *testing.T) {
+	e := Canonicalize(rawEvent(t, `{"type":"result","status":"error","error":{"type":"quota","message":"quota exceeded"}}`), "gemini")
+	require.True(t, e.IsError())
+	require.Equal(t, "quota exceeded", e.ResultText())
+	_, ok := e.Usage()
+	require.False(t, ok)
+}
+
+func TestCanonicalize_UnknownSourceAndEventUnchanged(t *testing.T) {
+	e := rawEvent(t, `{"type":"thread.started","thread_id":"t1"}`)
+	require.Equal(t, e, Canonicalize(e, "codex"))
+	require.Equal(t, e, Canonicalize(e, "gemini"))
+
+	RegisterNormalizer("codex", func(e Event) Event {
+		e.Type = "system"
+		return e
+	})
+	t.Cleanup(func() { delete(normalizers, "codex") })
+	require.Equal(t, "system", Canonicalize(e, "codex").Type)
+}
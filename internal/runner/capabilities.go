@@ -0,0 +1,41 @@
+package runner
+
+// Capabilities lists the optional RunOptions a runner honours. Options a
+// runner cannot honour are ignored, with a "warning" event, rather than
+// failing the run.
+type Capabilities struct {
+	Temperature bool
+	Seed        bool
+	TextOnly    bool
+	FieldMap    bool // decodes agent JSON that a field map can rename
+}
+
+// CapabilityReporter is implemented by runners that can report their
+// Capabilities. The answer may depend on opts, e.g. on the configured
+// transport.
+type CapabilityReporter interface {
+	Capabilities(opts RunOptions) Capabilities
+}
+
+// UnsupportedWarnings returns a "warning" event, carrying the text in
+// Data["message"], for each option set on opts that caps does not support.
+func UnsupportedWarnings(opts RunOptions, caps Capabilities) []Event {
+	var msgs []string
+	if opts.Temperature != nil && !caps.Temperature {
+		msgs = append(msgs, "temperature is not supported by this runner and was ignored")
+	}
+	if opts.Seed != nil && !caps.Seed {
+		msgs = append(msgs, "seed is not supported by this runner and was ignored")
+	}
+	if opts.TextOnly && !caps.TextOnly {
+		msgs = append(msgs, "text-only mode is not supported by this runner and was ignored")
+	}
+	if len(fieldMap(opts)) > 0 && !caps.FieldMap {
+		msgs = append(msgs, "field map is not supported by this runner and was ignored")
+	}
+	events := make([]Event, len(msgs))
+	for i, m := range msgs {
+		events[i] = opts.stamp(Event{Type: "warning", Data: map[string]any{"message": m}})
+	}
+	return events
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrorClass says what a run's terminal error means for running it again.
+type ErrorClass int
+
+const (
+	// ErrorClassNone is the class of a nil error.
+	ErrorClassNone ErrorClass = iota
+	// ErrorClassTransient errors may clear if the same runner tries again,
+	// such as a crash or an overloaded provider.
+	ErrorClassTransient
+	// ErrorClassFatal errors will recur however often the same runner
+	// tries: the agent is missing or unregistered, or it cannot
+	// authenticate. Another runner may still succeed.
+	ErrorClassFatal
+	// ErrorClassAborted errors end a run on purpose: it was cancelled, ran
+	// out of budget, or had its plan rejected. Nothing should run it again.
+	ErrorClassAborted
+)
+
+// authFailureMarkers are lower-case fragments of the messages agents report
+// when they cannot authenticate.
+var authFailureMarkers = []string{
+	"invalid api key",
+	"invalid x-api-key",
+	"invalid credentials",
+	"authentication_error",
+	"authentication failed",
+	"unauthorized",
+	"403 forbidden",
+	"please run /login",
+	"not logged in",
+}
+
+// ClassifyError returns the class of a run's terminal error. Cancellation,
+// ErrBudgetExceeded and ErrPlanRejected are aborted; *ErrAgentNotFound,
+// *ErrUnsupportedRunner and errors whose message reports an authentication
+// failure are fatal; anything else is transient.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, ErrBudgetExceeded), errors.Is(err, ErrPlanRejected):
+		return ErrorClassAborted
+	}
+	var notFound *ErrAgentNotFound
+	var unsupported *ErrUnsupportedRunner
+	if errors.As(err, &notFound) || errors.As(err, &unsupported) {
+		return ErrorClassFatal
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range authFailureMarkers {
+		if strings.Contains(msg, marker) {
+			return ErrorClassFatal
+		}
+	}
+	return ErrorClassTransient
+}
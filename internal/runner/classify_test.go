@@ -0,0 +1,30 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ErrorClassNone},
+		{fmt.Errorf("run cancelled: %w", context.Canceled), ErrorClassAborted},
+		{fmt.Errorf("attempt 2: %w", ErrBudgetExceeded), ErrorClassAborted},
+		{ErrPlanRejected, ErrorClassAborted},
+		{&ErrAgentNotFound{Command: "claude", Err: errors.New("executable file not found")}, ErrorClassFatal},
+		{&ErrUnsupportedRunner{Command: "codex"}, ErrorClassFatal},
+		{errors.New("claude api transport: 401 Unauthorized: invalid x-api-key"), ErrorClassFatal},
+		{&ErrRunFailed{Command: "claude", ExitCode: 1, Err: errors.New("exit status 1")}, ErrorClassTransient},
+		{errors.New("overloaded"), ErrorClassTransient},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, ClassifyError(tt.err), "%v", tt.err)
+	}
+}
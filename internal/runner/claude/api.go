@@ -0,0 +1,262 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+	"github.com/jumppad-labs/spektacular/internal/pricing"
+	"github.com/jumppad-labs/spektacular/internal/runner"
+)
+
+const (
+	// DefaultAPIBaseURL is the Anthropic API endpoint used by the api
+	// transport when claude.base_url is unset.
+	DefaultAPIBaseURL = "https://api.anthropic.com"
+	// DefaultAPIModel is the model the api transport requests when
+	// RunOptions.Model is unset. The CLI picks its own default; the API
+	// requires one.
+	DefaultAPIModel = "claude-sonnet-4-5"
+
+	apiVersion      = "2023-06-01"
+	apiMaxTokens    = 16000
+	maxSSELineBytes = 1024 * 1024
+)
+
+// apiTransport calls the Anthropic Messages streaming API directly and maps
+// its server-sent events onto stream-json shaped Events: a system init event
+// when the message starts, an assistant event per completed content block,
+// and a result event carrying the usage and cost when it stops. It offers the
+// model no tools and cannot resume sessions. Each run is a single turn, which
+// satisfies any MaxTurns. Runs go through runner.Supervise like exec ones, so
+// limits, notifications and the output middleware apply alike; RawLog
+// receives the raw event stream.
+type apiTransport struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func newAPITransport(cfg config.ClaudeConfig, client *http.Client) *apiTransport {
+	t := &apiTransport{baseURL: cfg.BaseURL, apiKey: cfg.APIKey, client: client}
+	if t.baseURL == "" {
+		t.baseURL = DefaultAPIBaseURL
+	}
+	if t.apiKey == "" {
+		t.apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+	if t.client == nil {
+		t.client = http.DefaultClient
+	}
+	return t
+}
+
+// errNoAPIKey reports that the api transport has no key to authenticate with.
+var errNoAPIKey = errors.New("claude api transport: no API key (set claude.api_key or ANTHROPIC_API_KEY)")
+
+// preflight checks an API key is configured. It makes no request.
+func (t *apiTransport) preflight() error {
+	if t.apiKey == "" {
+		return errNoAPIKey
+	}
+	return nil
+}
+
+func (t *apiTransport) run(opts runner.RunOptions) (<-chan runner.Event, <-chan error) {
+	if err := t.preflight(); err != nil {
+		return failed(err)
+	}
+	if opts.SessionID != "" {
+		return failed(errors.New("claude api transport cannot resume sessions"))
+	}
+
+	return runner.Supervise(opts, t.stream, runner.UnsupportedWarnings(opts, apiCapabilities)...)
+}
+
+// apiCapabilities are the optional options the api transport honours.
+var apiCapabilities = runner.Capabilities{Temperature: true, TextOnly: true}
+
+// apiRequest is the Messages API request body.
+type apiRequest struct {
+	Model       string       `json:"model"`
+	MaxTokens   int          `json:"max_tokens"`
+	System      string       `json:"system,omitempty"`
+	Messages    []apiMessage `json:"messages"`
+	Temperature *float64     `json:"temperature,omitempty"`
+	Stream      bool         `json:"stream"`
+}
+
+type apiMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// sseEvent is the union of the streaming event payloads the transport reads.
+type sseEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		ID    string         `json:"id"`
+		Model string         `json:"model"`
+		Usage map[string]any `json:"usage"`
+	} `json:"message"`
+	ContentBlock map[string]any `json:"content_block"`
+	Delta        struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage map[string]any `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (t *apiTransport) stream(opts runner.RunOptions, events chan<- runner.Event) error {
+	model := opts.Model
+	if model == "" {
+		model = DefaultAPIModel
+	}
+	body, err := json.Marshal(apiRequest{
+		Model:       model,
+		MaxTokens:   apiMaxTokens,
+		System:      opts.Prompts.System,
+		Messages:    []apiMessage{{Role: "user", Content: opts.UserPrompt()}},
+		Temperature: opts.Temperature,
+		Stream:      true,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding api request: %w", err)
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(t.baseURL, "/")+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building api request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", t.apiKey)
+	req.Header.Set("Anthropic-Version", apiVersion)
+
+	began := time.Now()
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling anthropic api: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("anthropic api returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var (
+		sessionID string
+		usage     = map[string]any{}
+		block     map[string]any
+		text      strings.Builder
+		texts     []string
+	)
+	scanner := bufio.NewScanner(runner.TeeRawLog(resp.Body, opts))
+	scanner.Buffer(make([]byte, maxSSELineBytes), maxSSELineBytes)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var ev sseEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			continue
+		}
+
+		switch ev.Type {
+		case "message_start":
+			sessionID, model = ev.Message.ID, ev.Message.Model
+			if opts.PinnedSessionID != "" {
+				sessionID = opts.PinnedSessionID
+			}
+			for k, v := range ev.Message.Usage {
+				usage[k] = v
+			}
+			events <- runner.Event{Type: "system", Data: map[string]any{
+				"type": "system", "subtype": "init", "session_id": sessionID, "model": model,
+			}}
+		case "content_block_start":
+			block = ev.ContentBlock
+			text.Reset()
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" {
+				text.WriteString(ev.Delta.Text)
+			}
+		case "content_block_stop":
+			if block == nil {
+				continue
+			}
+			if block["type"] == "text" {
+				block["text"] = text.String()
+				texts = append(texts, text.String())
+			}
+			e := runner.Event{Type: "assistant", Data: map[string]any{
+				"type":       "assistant",
+				"session_id": sessionID,
+				"message":    map[string]any{"model": model, "content": []any{block}},
+			}}
+			if opts.OnQuestion != nil {
+				for _, q := range runner.DetectQuestions(e.TextContent()) {
+					opts.OnQuestion(q)
+				}
+			}
+			events <- e
+			block = nil
+		case "message_delta":
+			for k, v := range ev.Usage {
+				usage[k] = v
+			}
+		case "message_stop":
+			events <- apiResult(model, sessionID, strings.Join(texts, "\n"), usage, time.Since(began))
+			return nil
+		case "error":
+			events <- runner.Event{Type: "result", Data: map[string]any{
+				"type": "result", "subtype": "error_during_execution", "is_error": true,
+				"session_id": sessionID, "result": ev.Error.Message,
+			}}
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading api stream: %w", err)
+	}
+	return errors.New("anthropic api stream ended before message_stop")
+}
+
+// apiResult builds the terminal result event, pricing the usage with the
+// pricing registry when the model is known.
+func apiResult(model, sessionID, text string, usage map[string]any, elapsed time.Duration) runner.Event {
+	data := map[string]any{
+		"type":        "result",
+		"subtype":     "success",
+		"is_error":    false,
+		"session_id":  sessionID,
+		"result":      text,
+		"num_turns":   float64(1),
+		"duration_ms": float64(elapsed.Milliseconds()),
+		"usage":       usage,
+	}
+	e := runner.Event{Type: "result", Data: data}
+	if price, ok := pricing.Lookup(model); ok {
+		u, _ := e.Usage()
+		data["total_cost_usd"] = price.Cost(u.InputTokens, u.OutputTokens, u.CacheCreationInputTokens, u.CacheReadInputTokens)
+	}
+	return e
+}
@@ -0,0 +1,263 @@
+package claude
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+	"github.com/jumppad-labs/spektacular/internal/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildArgs(t *testing.T) {
+	base := []string{"-p", "--output-format", "stream-json", "--verbose"}
+	tests := []struct {
+		name string
+		opts runner.RunOptions
+		want []string
+	}{
+		{"defaults", runner.RunOptions{}, base},
+		{
+			"model and system prompt",
+			runner.RunOptions{Model: "opus", Prompts: runner.Prompts{System: "be brief", User: "ignored"}},
+			append(base, "--system-prompt", "be brief", "--model", "opus"),
+		},
+		{
+			"tools",
+			runner.RunOptions{AllowedTools: []string{"Read", "Grep"}, DisallowedTools: []string{"Bash"}},
+			append(base, "--allowedTools", "Read,Grep", "--disallowedTools", "Bash"),
+		},
+		{
+			"resume with permission mode and max turns",
+			runner.RunOptions{SessionID: "s1", PermissionMode: "plan", MaxTurns: 5},
+			append(base, "--permission-mode", "plan", "--max-turns", "5", "--resume", "s1"),
+		},
+		{
+			"partial messages",
+			runner.RunOptions{PartialMessages: true},
+			append(base, "--include-partial-messages"),
+		},
+		{
+			"streamed input",
+			runner.RunOptions{Input: strings.NewReader(""), PartialMessages: true},
+			append(base, "--input-format", "stream-json", "--include-partial-messages"),
+		},
+		{
+			"debug",
+			runner.RunOptions{Debug: true, Model: "opus"},
+			append(base, "--model", "opus", "--debug"),
+		},
+		{
+			"text only",
+			runner.RunOptions{TextOnly: true, DisallowedTools: []string{"Bash"}},
+			append(base, "--tools", "", "--strict-mcp-config", "--disallowedTools", "Bash"),
+		},
+		{
+			"additional directories",
+			runner.RunOptions{AddDirs: []string{"../shared", "/opt/lib"}, SessionID: "s1"},
+			append(base, "--add-dir", "../shared", "--add-dir", "/opt/lib", "--resume", "s1"),
+		},
+		{
+			"pinned session id",
+			runner.RunOptions{PinnedSessionID: "0b6f7c1e-4d2a-4f7e-9c3b-5a8d2e1f0c9a", AddDirs: []string{"/opt/lib"}},
+			append(base, "--add-dir", "/opt/lib", "--session-id", "0b6f7c1e-4d2a-4f7e-9c3b-5a8d2e1f0c9a"),
+		},
+		{
+			"settings file",
+			runner.RunOptions{Config: config.Config{Claude: config.ClaudeConfig{SettingsPath: "/etc/claude/settings.json"}}, SessionID: "s1"},
+			append(base, "--settings", "/etc/claude/settings.json", "--resume", "s1"),
+		},
+		{
+			"extra args come last",
+			runner.RunOptions{Model: "sonnet", ExtraArgs: []string{"--add-dir", "../shared"}},
+			append(base, "--model", "sonnet", "--add-dir", "../shared"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, New().buildArgs(tt.opts))
+		})
+	}
+}
+
+func TestCmd_AppendsPromptAfterBuiltArgs(t *testing.T) {
+	opts := runner.RunOptions{Model: "opus", ExtraArgs: []string{"--debug"}, Prompts: runner.Prompts{User: "plan it"}}
+	cmd, _, err := New().Cmd(opts)
+	require.NoError(t, err)
+	want := append([]string{"claude"}, New().buildArgs(opts)...)
+	require.Equal(t, append(want, "plan it"), cmd.Args)
+}
+
+func TestCmd_DebugNeedsStderr(t *testing.T) {
+	_, _, err := New().Cmd(runner.RunOptions{Debug: true, DiscardStderr: true})
+	require.ErrorContains(t, err, "cannot be combined with discarding stderr")
+}
+
+func TestRun_DebugLinesBecomeStderrEvents(t *testing.T) {
+	c := New()
+	c.Command = fakeCLI(t, `echo "[DEBUG] args: $*" >&2
+echo '[DEBUG] loading settings'
+echo '{"type":"result","result":"plan"}'`)
+
+	events, err := collect(c.Run(runner.RunOptions{Debug: true, Prompts: runner.Prompts{User: "plan it"}}))
+	require.NoError(t, err)
+	var stderr, results []string
+	for _, e := range events {
+		switch {
+		case e.Type == "stderr":
+			stderr = append(stderr, e.Data["line"].(string))
+		case e.IsResult():
+			results = append(results, e.ResultText())
+		}
+	}
+	require.ElementsMatch(t, []string{"[DEBUG] args: " + strings.Join(append(c.buildArgs(runner.RunOptions{Debug: true}), "plan it"), " "), "[DEBUG] loading settings"}, stderr)
+	require.Equal(t, []string{"plan"}, results)
+}
+
+func TestCmd_TextOnlyExcludesAllowedTools(t *testing.T) {
+	_, _, err := New().Cmd(runner.RunOptions{TextOnly: true, AllowedTools: []string{"Read", "Grep"}})
+	require.EqualError(t, err, "text-only runs cannot allow tools (allowed: Read, Grep)")
+}
+
+func TestCmd_PinnedSessionIDValidated(t *testing.T) {
+	_, _, err := New().Cmd(runner.RunOptions{PinnedSessionID: "fixture-1"})
+	require.EqualError(t, err, `pinned session id "fixture-1" must be a UUID`)
+
+	_, _, err = New().Cmd(runner.RunOptions{PinnedSessionID: "0b6f7c1e-4d2a-4f7e-9c3b-5a8d2e1f0c9a", SessionID: "s1"})
+	require.ErrorContains(t, err, `when resuming session "s1"`)
+}
+
+func TestCapabilities_TextOnly(t *testing.T) {
+	exec := runner.RunOptions{}
+	api := runner.RunOptions{Config: config.Config{Claude: config.ClaudeConfig{Transport: config.ClaudeTransportAPI}}}
+	require.True(t, New().Capabilities(exec).TextOnly)
+	require.True(t, New().Capabilities(api).TextOnly)
+	require.Empty(t, runner.UnsupportedWarnings(runner.RunOptions{TextOnly: true}, New().Capabilities(exec)))
+	require.Len(t, runner.UnsupportedWarnings(runner.RunOptions{TextOnly: true}, runner.Capabilities{}), 1)
+}
+
+func TestCmd_AddDirsResolvedAgainstCWD(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "shared"), 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "repo"), 0755))
+	opts := runner.RunOptions{CWD: filepath.Join(root, "repo"), AddDirs: []string{"../shared", root}}
+
+	cmd, _, err := New().Cmd(opts)
+	require.NoError(t, err)
+	require.Contains(t, strings.Join(cmd.Args, " "), "--add-dir ../shared --add-dir "+root)
+}
+
+func TestCmd_AddDirsMustExist(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "notes.md")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0644))
+
+	_, _, err := New().Cmd(runner.RunOptions{AddDirs: []string{filepath.Join(root, "missing")}})
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorContains(t, err, "additional directory")
+
+	_, _, err = New().Cmd(runner.RunOptions{AddDirs: []string{file}})
+	require.EqualError(t, err, fmt.Sprintf("additional directory %q is not a directory", file))
+}
+
+func TestCmd_SettingsFileMustExist(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "settings.json"), []byte(`{"hooks":{}}`), 0644))
+	settings := func(path string) runner.RunOptions {
+		return runner.RunOptions{CWD: root, Config: config.Config{Claude: config.ClaudeConfig{SettingsPath: path}}}
+	}
+
+	cmd, _, err := New().Cmd(settings("settings.json"))
+	require.NoError(t, err, "relative paths resolve against the working directory")
+	require.Contains(t, strings.Join(cmd.Args, " "), "--settings settings.json")
+
+	_, _, err = New().Cmd(settings("missing.json"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorContains(t, err, `settings file "missing.json"`)
+
+	_, _, err = New().Cmd(settings(root))
+	require.EqualError(t, err, fmt.Sprintf("settings file %q is a directory", root))
+}
+
+func TestBuildArgs_OutputFormatFromConfig(t *testing.T) {
+	opts := runner.RunOptions{Config: config.Config{Claude: config.ClaudeConfig{OutputFormat: config.OutputFormatJSON}}}
+	require.Equal(t, []string{"-p", "--output-format", "json", "--verbose"}, New().buildArgs(opts))
+}
+
+func TestCmd_PartialMessagesRequireStreamJSON(t *testing.T) {
+	opts := runner.RunOptions{
+		PartialMessages: true,
+		Config:          config.Config{Claude: config.ClaudeConfig{OutputFormat: config.OutputFormatJSON}},
+	}
+	_, _, err := New().Cmd(opts)
+	require.EqualError(t, err, `partial messages require the stream-json output format, not "json"`)
+}
+
+func TestCmd_StreamedInputRequiresStreamJSON(t *testing.T) {
+	opts := runner.RunOptions{
+		Input:  strings.NewReader(""),
+		Config: config.Config{Claude: config.ClaudeConfig{OutputFormat: config.OutputFormatJSON}},
+	}
+	_, _, err := New().Cmd(opts)
+	require.EqualError(t, err, `streamed input requires the stream-json output format, not "json"`)
+}
+
+func TestRun_JSONOutputFormatDecoded(t *testing.T) {
+	c := New()
+	c.Command = fakeCLI(t, `echo '[{"type":"system","session_id":"s1"},'
+echo '{"type":"assistant","message":{"content":[{"type":"text","text":"draft"}]}},'
+echo '{"type":"result","result":"plan"}]'`)
+	opts := runner.RunOptions{
+		Config:  config.Config{Claude: config.ClaudeConfig{OutputFormat: config.OutputFormatJSON}},
+		Prompts: runner.Prompts{User: "p"},
+	}
+
+	events, err := collect(c.Run(opts))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, "s1", events[0].SessionID())
+	require.Equal(t, "draft", events[1].TextContent())
+	require.Equal(t, "plan", events[2].ResultText())
+}
+
+func TestRun_TextOutputFormatBecomesResult(t *testing.T) {
+	c := New()
+	c.Command = fakeCLI(t, `[ "$3" = text ] && printf '## Plan\n\n1. Do it\n'`)
+	opts := runner.RunOptions{
+		Config:  config.Config{Claude: config.ClaudeConfig{OutputFormat: config.OutputFormatText}},
+		Prompts: runner.Prompts{User: "p"},
+	}
+
+	events, err := collect(c.Run(opts))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "## Plan\n\n1. Do it", events[0].ResultText())
+}
+
+func TestRun_ExecTransportWarnsOnSamplingOptions(t *testing.T) {
+	c := New()
+	c.Command = fakeCLI(t, `printf '{"type":"result","result":"%s"}\n' "$*"`)
+	plain := runner.RunOptions{Prompts: runner.Prompts{User: "plan it"}}
+	temp, seed := 0.0, 42
+	sampled := plain
+	sampled.Temperature = &temp
+	sampled.Seed = &seed
+
+	require.Equal(t, c.buildArgs(plain), c.buildArgs(sampled), "the CLI has no sampling flags")
+
+	events, err := collect(c.Run(plain))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	events, err = collect(c.Run(sampled))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, "warning", events[0].Type)
+	require.Contains(t, events[0].Data["message"], "temperature")
+	require.Contains(t, events[1].Data["message"], "seed")
+	require.True(t, events[2].IsResult())
+}
@@ -0,0 +1,268 @@
+// Package claude implements the Runner interface for the Claude CLI agent.
+package claude
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+	"github.com/jumppad-labs/spektacular/internal/runner"
+)
+
+// DefaultInlinePromptLimit is the largest prompt, in bytes, passed to the CLI
+// on argv. Linux caps a single argument at 128 KiB, so anything near that is
+// sent on stdin instead.
+const DefaultInlinePromptLimit = 64 * 1024
+
+// permissionModes are the values the CLI accepts for --permission-mode.
+var permissionModes = []string{"acceptEdits", "bypassPermissions", "default", "dontAsk", "plan"}
+
+// sessionIDPattern matches the UUIDs the CLI accepts for --session-id.
+var sessionIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Claude implements runner.Runner by spawning the Claude CLI subprocess.
+type Claude struct {
+	// Command is the CLI binary to run.
+	Command string
+	// InlinePromptLimit is the largest prompt, in bytes, passed inline on
+	// argv. Longer prompts are written to a temp file that is fed to the CLI
+	// on stdin and removed once the run ends. Zero uses DefaultInlinePromptLimit.
+	InlinePromptLimit int
+	// HTTPClient is used by the api transport. Nil uses http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// New returns a new Claude runner.
+func New() *Claude { return &Claude{Command: "claude"} }
+
+// Run executes opts with the transport selected by opts.Config.Claude — by
+// default the claude subprocess — and returns a channel of events and an
+// error channel.
+func (c *Claude) Run(opts runner.RunOptions) (<-chan runner.Event, <-chan error) {
+	t, err := c.transport(opts.Config.Claude)
+	if err != nil {
+		return failed(err)
+	}
+	return t.run(opts)
+}
+
+// Capabilities reports the optional options the transport selected by opts
+// honours. The CLI has no sampling flags; the api transport accepts a
+// temperature but, like the API itself, no seed. Both can run text-only: the
+// api transport never offers the model tools. Only the CLI's output can be
+// remapped with a field map; the api transport builds its events itself.
+func (c *Claude) Capabilities(opts runner.RunOptions) runner.Capabilities {
+	if opts.Config.Claude.Transport == config.ClaudeTransportAPI {
+		return apiCapabilities
+	}
+	return runner.Capabilities{TextOnly: true, FieldMap: true}
+}
+
+// Cmd builds the subprocess for opts. When the prompt exceeds the inline
+// limit it is staged in a temp file attached to stdin, and the returned
+// cleanup func removes that file. With opts.Input the prompt is instead the
+// first stream-json message on stdin, followed by everything Input yields.
+func (c *Claude) Cmd(opts runner.RunOptions) (*exec.Cmd, func(), error) {
+	if err := validate(opts); err != nil {
+		return nil, nil, err
+	}
+	args := c.buildArgs(opts)
+	prompt := opts.UserPrompt()
+	if opts.Input != nil {
+		return c.streamInputCmd(opts, args, prompt)
+	}
+
+	limit := c.InlinePromptLimit
+	if limit <= 0 {
+		limit = DefaultInlinePromptLimit
+	}
+
+	var stdin *os.File
+	if len(prompt) <= limit {
+		args = append(args, prompt)
+	} else {
+		f, err := stagePrompt(prompt)
+		if err != nil {
+			return nil, nil, err
+		}
+		stdin = f
+	}
+
+	cmd := c.command(opts, args)
+	if stdin == nil {
+		return cmd, nil, nil
+	}
+	cmd.Stdin = stdin
+	return cmd, func() {
+		stdin.Close()
+		os.Remove(stdin.Name())
+	}, nil
+}
+
+// command returns the CLI invocation with args, run in opts.CWD.
+func (c *Claude) command(opts runner.RunOptions, args []string) *exec.Cmd {
+	cmd := exec.Command(c.Command, args...) //nolint:gosec
+	cmd.Dir = opts.CWD
+	// The CLI has no idempotency flag; the key is exported for proxies.
+	cmd.Env = append(os.Environ(), runner.IdempotencyKeyEnv+"="+opts.ResolveIdempotencyKey())
+	return cmd
+}
+
+// streamInputCmd attaches an OS pipe to the CLI's stdin and feeds it the
+// prompt as a user message, then opts.Input, closing it when Input is
+// exhausted. A real pipe rather than an io.Reader keeps exec from waiting on
+// Input before the run can end.
+func (c *Claude) streamInputCmd(opts runner.RunOptions, args []string, prompt string) (*exec.Cmd, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating input pipe: %w", err)
+	}
+	go func() {
+		defer w.Close()
+		if _, err := w.Write(runner.UserMessage(prompt)); err != nil {
+			return
+		}
+		// A write error means the CLI has exited; nothing is left to feed.
+		io.Copy(w, opts.Input)
+	}()
+	cmd := c.command(opts, args)
+	cmd.Stdin = r
+	return cmd, func() { r.Close() }, nil
+}
+
+// buildArgs assembles the CLI flags for opts without spawning anything, so the
+// command line can be inspected in isolation. The prompt itself is not
+// included; Cmd appends it inline or routes it through stdin.
+func (c *Claude) buildArgs(opts runner.RunOptions) []string {
+	args := []string{"-p", "--output-format", runner.OutputFormat(opts), "--verbose"}
+	if opts.Prompts.System != "" {
+		args = append(args, "--system-prompt", opts.Prompts.System)
+	}
+	if opts.Model != "" {
+		args = append(args, "--model", opts.Model)
+	}
+	if opts.PermissionMode != "" {
+		args = append(args, "--permission-mode", opts.PermissionMode)
+	}
+	if opts.MaxTurns > 0 {
+		args = append(args, "--max-turns", strconv.Itoa(opts.MaxTurns))
+	}
+	if opts.Input != nil {
+		args = append(args, "--input-format", config.OutputFormatStreamJSON)
+	}
+	if opts.PartialMessages {
+		args = append(args, "--include-partial-messages")
+	}
+	if opts.Debug {
+		args = append(args, "--debug")
+	}
+	if opts.TextOnly {
+		// An empty tool list disables every built-in tool, and a strict MCP
+		// config with no servers leaves no MCP tools either.
+		args = append(args, "--tools", "", "--strict-mcp-config")
+	}
+	if len(opts.AllowedTools) > 0 {
+		args = append(args, "--allowedTools", strings.Join(opts.AllowedTools, ","))
+	}
+	if len(opts.DisallowedTools) > 0 {
+		args = append(args, "--disallowedTools", strings.Join(opts.DisallowedTools, ","))
+	}
+	for _, dir := range opts.AddDirs {
+		args = append(args, "--add-dir", dir)
+	}
+	if settings := opts.Config.Claude.SettingsPath; settings != "" {
+		args = append(args, "--settings", settings)
+	}
+	if opts.SessionID != "" {
+		args = append(args, "--resume", opts.SessionID)
+	}
+	if opts.PinnedSessionID != "" {
+		args = append(args, "--session-id", opts.PinnedSessionID)
+	}
+	return append(args, opts.ExtraArgs...)
+}
+
+// validate rejects options the CLI would refuse.
+func validate(opts runner.RunOptions) error {
+	if opts.PermissionMode != "" && !slices.Contains(permissionModes, opts.PermissionMode) {
+		return fmt.Errorf("unknown permission mode %q (must be one of %s)", opts.PermissionMode, strings.Join(permissionModes, ", "))
+	}
+	if opts.PartialMessages && runner.OutputFormat(opts) != config.OutputFormatStreamJSON {
+		return fmt.Errorf("partial messages require the %s output format, not %q", config.OutputFormatStreamJSON, runner.OutputFormat(opts))
+	}
+	if opts.Input != nil && runner.OutputFormat(opts) != config.OutputFormatStreamJSON {
+		return fmt.Errorf("streamed input requires the %s output format, not %q", config.OutputFormatStreamJSON, runner.OutputFormat(opts))
+	}
+	if opts.Debug && opts.DiscardStderr {
+		return fmt.Errorf("debug output is delivered as stderr events and cannot be combined with discarding stderr")
+	}
+	if opts.TextOnly && len(opts.AllowedTools) > 0 {
+		return fmt.Errorf("text-only runs cannot allow tools (allowed: %s)", strings.Join(opts.AllowedTools, ", "))
+	}
+	if opts.PinnedSessionID != "" {
+		if opts.SessionID != "" {
+			return fmt.Errorf("cannot pin session id %q when resuming session %q", opts.PinnedSessionID, opts.SessionID)
+		}
+		if !sessionIDPattern.MatchString(opts.PinnedSessionID) {
+			return fmt.Errorf("pinned session id %q must be a UUID", opts.PinnedSessionID)
+		}
+	}
+	for _, dir := range opts.AddDirs {
+		info, err := os.Stat(resolve(opts, dir))
+		if err != nil {
+			return fmt.Errorf("additional directory %q: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("additional directory %q is not a directory", dir)
+		}
+	}
+	if settings := opts.Config.Claude.SettingsPath; settings != "" {
+		info, err := os.Stat(resolve(opts, settings))
+		if err != nil {
+			return fmt.Errorf("settings file %q: %w", settings, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("settings file %q is a directory", settings)
+		}
+	}
+	return nil
+}
+
+// resolve returns path as the CLI, running in opts.CWD, will see it.
+func resolve(opts runner.RunOptions, path string) string {
+	if !filepath.IsAbs(path) && opts.CWD != "" {
+		return filepath.Join(opts.CWD, path)
+	}
+	return path
+}
+
+// stagePrompt writes prompt to a temp file and returns it rewound for reading.
+func stagePrompt(prompt string) (*os.File, error) {
+	f, err := os.CreateTemp("", "spektacular-prompt-*.md")
+	if err != nil {
+		return nil, fmt.Errorf("creating prompt file: %w", err)
+	}
+	if _, err := f.WriteString(prompt); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("writing prompt file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("rewinding prompt file: %w", err)
+	}
+	return f, nil
+}
+
+func init() {
+	runner.Register("claude", func() runner.Runner { return New() })
+}
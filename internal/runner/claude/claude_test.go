@@ -0,0 +1,200 @@
+package claude
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jumppad-labs/spektacular/internal/runner"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCLI writes an executable shell script standing in for the claude binary
+// and returns its path.
+func fakeCLI(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "claude")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	return path
+}
+
+// collect drains both channels returned by Run.
+func collect(events <-chan runner.Event, errc <-chan error) ([]runner.Event, error) {
+	var seen []runner.Event
+	for e := range events {
+		seen = append(seen, e)
+	}
+	return seen, <-errc
+}
+
+func TestCmd_PromptAtLimitPassesInline(t *testing.T) {
+	c := &Claude{Command: "claude", InlinePromptLimit: 8}
+
+	cmd, cleanup, err := c.Cmd(runner.RunOptions{Prompts: runner.Prompts{User: "12345678"}})
+	require.NoError(t, err)
+	require.Nil(t, cleanup)
+	require.Nil(t, cmd.Stdin)
+	require.Equal(t, "12345678", cmd.Args[len(cmd.Args)-1])
+}
+
+func TestCmd_PromptOverLimitUsesStdinFile(t *testing.T) {
+	c := &Claude{Command: "claude", InlinePromptLimit: 8}
+
+	cmd, cleanup, err := c.Cmd(runner.RunOptions{Prompts: runner.Prompts{User: "123456789"}})
+	require.NoError(t, err)
+	require.NotNil(t, cleanup)
+	require.NotContains(t, cmd.Args, "123456789")
+
+	f, ok := cmd.Stdin.(*os.File)
+	require.True(t, ok, "stdin should be the staged prompt file")
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "123456789", string(data))
+
+	cleanup()
+	_, err = os.Stat(f.Name())
+	require.True(t, os.IsNotExist(err), "cleanup should remove the prompt file")
+}
+
+func TestCmd_ZeroLimitUsesDefault(t *testing.T) {
+	c := New()
+	prompt := strings.Repeat("x", DefaultInlinePromptLimit)
+
+	cmd, cleanup, err := c.Cmd(runner.RunOptions{Prompts: runner.Prompts{User: prompt}})
+	require.NoError(t, err)
+	require.Nil(t, cleanup)
+	require.Equal(t, prompt, cmd.Args[len(cmd.Args)-1])
+}
+
+func TestRun_LargePromptFedOnStdinAndCleanedUp(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	// The fake CLI reports how many bytes arrived on stdin.
+	cli := fakeCLI(t, `n=$(wc -c | tr -d ' '); echo "{\"type\":\"result\",\"result\":\"$n\"}"`)
+	c := &Claude{Command: cli, InlinePromptLimit: 16}
+
+	events, err := collect(c.Run(runner.RunOptions{Prompts: runner.Prompts{User: strings.Repeat("p", 100)}}))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "100", events[0].ResultText())
+
+	entries, err := os.ReadDir(tmp)
+	require.NoError(t, err)
+	require.Empty(t, entries, "prompt temp file should be removed after the run")
+}
+
+func TestRun_SmallPromptPassedOnArgv(t *testing.T) {
+	cli := fakeCLI(t, `for a; do last=$a; done; echo "{\"type\":\"result\",\"result\":\"$last\"}"`)
+	c := &Claude{Command: cli}
+
+	events, err := collect(c.Run(runner.RunOptions{Prompts: runner.Prompts{User: "plan it"}}))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "plan it", events[0].ResultText())
+}
+
+func TestCmd_PermissionModeForwarded(t *testing.T) {
+	cmd, _, err := New().Cmd(runner.RunOptions{PermissionMode: "acceptEdits", Prompts: runner.Prompts{User: "p"}})
+	require.NoError(t, err)
+	require.Contains(t, strings.Join(cmd.Args, " "), "--permission-mode acceptEdits")
+}
+
+func TestCmd_UnknownPermissionModeErrors(t *testing.T) {
+	_, _, err := New().Cmd(runner.RunOptions{PermissionMode: "yolo"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `unknown permission mode "yolo"`)
+
+	events, err := collect(New().Run(runner.RunOptions{PermissionMode: "yolo"}))
+	require.Error(t, err)
+	require.Empty(t, events)
+}
+
+func TestCmd_EmptyPermissionModeOmitsFlag(t *testing.T) {
+	cmd, _, err := New().Cmd(runner.RunOptions{Prompts: runner.Prompts{User: "p"}})
+	require.NoError(t, err)
+	require.NotContains(t, cmd.Args, "--permission-mode")
+}
+
+func TestRun_PromptsPassedVerbatim(t *testing.T) {
+	// The fake CLI echoes the --system-prompt value and the trailing prompt
+	// argument back as the result so the test can compare them byte-for-byte.
+	cli := fakeCLI(t, `while [ $# -gt 1 ]; do [ "$1" = "--system-prompt" ] && sys=$2; shift; done
+printf '{"type":"system","system":"%s"}\n{"type":"result","result":"%s"}\n' "$sys" "$1"`)
+	c := &Claude{Command: cli}
+	user := "  # Custom Prompt -- A/B variant 2  "
+
+	events, err := collect(c.Run(runner.RunOptions{Prompts: runner.Prompts{User: user, System: "be terse"}}))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "be terse", events[0].Data["system"])
+	require.Equal(t, user, events[1].ResultText())
+}
+
+func TestRun_PromptOverridesUserPrompt(t *testing.T) {
+	cli := fakeCLI(t, `for a; do last=$a; done; printf '{"type":"result","result":"%s"}\n' "$last"`)
+	c := &Claude{Command: cli}
+	prompt := "  # Prebuilt prompt -- A/B variant 1  "
+
+	events, err := collect(c.Run(runner.RunOptions{Prompt: prompt, Prompts: runner.Prompts{User: "rebuilt prompt"}}))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, prompt, events[0].ResultText())
+
+	events, err = collect(c.Run(runner.RunOptions{Prompts: runner.Prompts{User: "rebuilt prompt"}}))
+	require.NoError(t, err)
+	require.Equal(t, "rebuilt prompt", events[0].ResultText(), "without Prompt the user prompt is sent")
+}
+
+func TestNew_RegistersClaudeRunner(t *testing.T) {
+	r, err := runner.NewRunner("claude")
+	require.NoError(t, err)
+	require.IsType(t, &Claude{}, r)
+}
+
+func TestCmd_ForwardsIdempotencyKey(t *testing.T) {
+	opts := runner.RunOptions{Prompts: runner.Prompts{User: "plan it"}}
+	first, _, err := New().Cmd(opts)
+	require.NoError(t, err)
+	retry, _, err := New().Cmd(opts)
+	require.NoError(t, err)
+
+	want := runner.IdempotencyKeyEnv + "=" + opts.ResolveIdempotencyKey()
+	require.Contains(t, first.Env, want)
+	require.Contains(t, retry.Env, want)
+
+	opts.IdempotencyKey = "req-42"
+	cmd, _, err := New().Cmd(opts)
+	require.NoError(t, err)
+	require.Contains(t, cmd.Env, runner.IdempotencyKeyEnv+"=req-42")
+}
+
+func TestStart_InjectWritesReminderToCLIStdin(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "stdin.log")
+	// The fake CLI logs the prompt line, then the injected line, before
+	// finishing; the prompt is not passed on argv.
+	cli := fakeCLI(t, `read -r prompt; echo '{"type":"system","session_id":"s1"}'
+read -r injected; printf '%s\n%s\n' "$prompt" "$injected" > `+log+`
+echo "{\"type\":\"result\",\"result\":\"$#\"}"`)
+	c := &Claude{Command: cli}
+
+	h := runner.Start(c, runner.RunOptions{Prompts: runner.Prompts{User: "plan it"}, Steerable: true})
+	require.Equal(t, "s1", (<-h.Events()).SessionID())
+	require.NoError(t, h.Inject("Stay within the spec's scope."))
+	var result runner.Event
+	for e := range h.Events() {
+		if e.IsResult() {
+			result = e
+		}
+	}
+	require.NoError(t, h.Wait())
+	require.Equal(t, "6", result.ResultText(), "only the built flags are passed on argv")
+
+	got, err := os.ReadFile(log)
+	require.NoError(t, err)
+	want := string(runner.UserMessage("plan it")) +
+		string(runner.UserMessage("<system-reminder>\nStay within the spec's scope.\n</system-reminder>"))
+	require.Equal(t, want, string(got))
+}
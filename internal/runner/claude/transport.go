@@ -0,0 +1,73 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+	"github.com/jumppad-labs/spektacular/internal/runner"
+)
+
+// transport executes a run for the Claude runner. Every transport delivers
+// the same Event shape the CLI's stream-json output produces, so consumers
+// do not depend on how the model was reached.
+type transport interface {
+	run(opts runner.RunOptions) (<-chan runner.Event, <-chan error)
+	// preflight checks the transport is usable without starting a run.
+	preflight() error
+}
+
+// execTransport drives the claude CLI as a subprocess.
+type execTransport struct{ c *Claude }
+
+func (t execTransport) run(opts runner.RunOptions) (<-chan runner.Event, <-chan error) {
+	return runner.Exec(t.c, opts)
+}
+
+// preflight checks the CLI is on PATH and answers --version.
+func (t execTransport) preflight() error {
+	path, err := exec.LookPath(t.c.Command)
+	if err != nil {
+		return &runner.ErrAgentNotFound{Command: t.c.Command, Err: err}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), runner.DefaultPreflightTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s --version: %w: %s", t.c.Command, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Preflight checks that the transport cfg selects is usable: the CLI is
+// installed and runs, or the api transport has an API key.
+func (c *Claude) Preflight(cfg config.Config) error {
+	t, err := c.transport(cfg.Claude)
+	if err != nil {
+		return err
+	}
+	return t.preflight()
+}
+
+// transport returns the transport selected by cfg.
+func (c *Claude) transport(cfg config.ClaudeConfig) (transport, error) {
+	switch cfg.Transport {
+	case "", config.ClaudeTransportExec:
+		return execTransport{c}, nil
+	case config.ClaudeTransportAPI:
+		return newAPITransport(cfg, c.HTTPClient), nil
+	}
+	return nil, fmt.Errorf("unknown claude transport %q", cfg.Transport)
+}
+
+// failed returns closed channels carrying only err.
+func failed(err error) (<-chan runner.Event, <-chan error) {
+	events := make(chan runner.Event)
+	errc := make(chan error, 1)
+	close(events)
+	errc <- err
+	close(errc)
+	return events, errc
+}
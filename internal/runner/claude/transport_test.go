@@ -0,0 +1,306 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+	"github.com/jumppad-labs/spektacular/internal/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_ExecTransportPassesBuiltArgv(t *testing.T) {
+	c := New()
+	c.Command = fakeCLI(t, `printf '{"type":"result","result":"%s"}\n' "$*"`)
+	opts := runner.RunOptions{
+		Model:   "opus",
+		Config:  config.Config{Claude: config.ClaudeConfig{Transport: config.ClaudeTransportExec}},
+		Prompts: runner.Prompts{User: "plan it"},
+	}
+
+	events, err := collect(c.Run(opts))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, strings.Join(append(c.buildArgs(opts), "plan it"), " "), events[0].ResultText())
+}
+
+func TestRun_UnknownTransportErrors(t *testing.T) {
+	opts := runner.RunOptions{Config: config.Config{Claude: config.ClaudeConfig{Transport: "grpc"}}}
+	events, err := collect(New().Run(opts))
+	require.EqualError(t, err, `unknown claude transport "grpc"`)
+	require.Empty(t, events)
+}
+
+// sseServer serves body as a Messages API event stream and records the
+// decoded request.
+func sseServer(t *testing.T, body string) (*httptest.Server, *apiRequest) {
+	t.Helper()
+	var got apiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/messages", r.URL.Path)
+		require.Equal(t, "sk-test", r.Header.Get("X-Api-Key"))
+		require.Equal(t, apiVersion, r.Header.Get("Anthropic-Version"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+// sse formats one server-sent event.
+func sse(event, data string) string {
+	return "event: " + event + "\ndata: " + data + "\n\n"
+}
+
+func apiOptions(baseURL string) runner.RunOptions {
+	return runner.RunOptions{
+		Model:   "claude-sonnet-4-5",
+		Config:  config.Config{Claude: config.ClaudeConfig{Transport: config.ClaudeTransportAPI, APIKey: "sk-test", BaseURL: baseURL}},
+		Prompts: runner.Prompts{System: "be brief", User: "plan it"},
+	}
+}
+
+func TestRun_APITransportMapsSSEToEvents(t *testing.T) {
+	stream := sse("message_start", `{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":1000,"output_tokens":1}}}`) +
+		sse("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`) +
+		sse("ping", `{"type":"ping"}`) +
+		sse("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"## Plan\n"}}`) +
+		sse("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"1. Do it"}}`) +
+		sse("content_block_stop", `{"type":"content_block_stop","index":0}`) +
+		sse("message_delta", `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":200}}`) +
+		sse("message_stop", `{"type":"message_stop"}`)
+	srv, req := sseServer(t, stream)
+
+	events, err := collect(New().Run(apiOptions(srv.URL)))
+	require.NoError(t, err)
+	require.Equal(t, apiRequest{
+		Model: "claude-sonnet-4-5", MaxTokens: apiMaxTokens, System: "be brief", Stream: true,
+		Messages: []apiMessage{{Role: "user", Content: "plan it"}},
+	}, *req)
+
+	require.Len(t, events, 3)
+	require.Equal(t, "msg_1", events[0].SessionID())
+	require.Equal(t, "claude-sonnet-4-5-20250929", events[0].Model())
+	require.Equal(t, "## Plan\n1. Do it", events[1].TextContent())
+
+	result := events[2]
+	require.True(t, result.IsResult())
+	require.False(t, result.IsError())
+	require.Equal(t, "## Plan\n1. Do it", result.ResultText())
+	usage := runner.SummarizeUsage(events)
+	require.Equal(t, runner.Usage{InputTokens: 1000, OutputTokens: 200}, usage.Usage)
+	require.InDelta(t, (1000*3+200*15)/1e6, usage.CostUSD, 1e-9)
+}
+
+func TestRun_APITransportPromptOverridesUserPrompt(t *testing.T) {
+	srv, req := sseServer(t, sse("message_stop", `{"type":"message_stop"}`))
+	opts := apiOptions(srv.URL)
+	opts.Prompt = "  # Prebuilt prompt -- A/B variant 1  "
+
+	_, err := collect(New().Run(opts))
+	require.NoError(t, err)
+	require.Equal(t, []apiMessage{{Role: "user", Content: opts.Prompt}}, req.Messages)
+	require.Equal(t, "be brief", req.System)
+}
+
+func TestRun_APITransportErrorEventBecomesErrorResult(t *testing.T) {
+	stream := sse("message_start", `{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5"}}`) +
+		sse("error", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	srv, _ := sseServer(t, stream)
+
+	events, err := collect(New().Run(apiOptions(srv.URL)))
+	require.NoError(t, err)
+	last := events[len(events)-1]
+	require.True(t, last.IsError())
+	require.Equal(t, "Overloaded", last.ResultText())
+}
+
+func TestRun_APITransportHTTPErrorReturned(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"error":{"message":"invalid x-api-key"}}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	_, err := collect(New().Run(apiOptions(srv.URL)))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "401 Unauthorized")
+	require.Contains(t, err.Error(), "invalid x-api-key")
+}
+
+func TestRun_APITransportPinnedSessionID(t *testing.T) {
+	stream := sse("message_start", `{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5"}}`) +
+		sse("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`) +
+		sse("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"plan"}}`) +
+		sse("content_block_stop", `{"type":"content_block_stop","index":0}`) +
+		sse("message_stop", `{"type":"message_stop"}`)
+	srv, _ := sseServer(t, stream)
+	opts := apiOptions(srv.URL)
+	opts.PinnedSessionID = "0b6f7c1e-4d2a-4f7e-9c3b-5a8d2e1f0c9a"
+	seed := 7
+	opts.Seed = &seed
+
+	events, err := collect(New().Run(opts))
+	require.NoError(t, err)
+	require.Equal(t, "warning", events[0].Type)
+	for _, e := range events {
+		require.Equal(t, opts.PinnedSessionID, e.SessionID(), e.Type)
+	}
+}
+
+func TestRun_APITransportCallsOnEvent(t *testing.T) {
+	stream := sse("message_start", `{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5"}}`) +
+		sse("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`) +
+		sse("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"plan"}}`) +
+		sse("content_block_stop", `{"type":"content_block_stop","index":0}`) +
+		sse("message_stop", `{"type":"message_stop"}`)
+	srv, _ := sseServer(t, stream)
+	opts := apiOptions(srv.URL)
+	var called []runner.Event
+	opts.OnEvent = func(e runner.Event) { called = append(called, e) }
+
+	events, err := collect(New().Run(opts))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, events, called)
+}
+
+func TestRun_APITransportRejectsResume(t *testing.T) {
+	opts := apiOptions("http://unused")
+	opts.SessionID = "s1"
+	_, err := collect(New().Run(opts))
+	require.EqualError(t, err, "claude api transport cannot resume sessions")
+}
+
+func TestRun_APITransportRequiresKey(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	opts := apiOptions("http://unused")
+	opts.Config.Claude.APIKey = ""
+	_, err := collect(New().Run(opts))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no API key")
+}
+
+func TestRun_APITransportForwardsTemperature(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sse("message_stop", `{"type":"message_stop"}`))
+	}))
+	defer srv.Close()
+
+	unset := apiOptions(srv.URL)
+	_, err := collect(New().Run(unset))
+	require.NoError(t, err)
+
+	set := apiOptions(srv.URL)
+	temp := 0.2
+	set.Temperature = &temp
+	_, err = collect(New().Run(set))
+	require.NoError(t, err)
+
+	require.Len(t, bodies, 2)
+	require.NotContains(t, bodies[0], "temperature")
+	require.Equal(t, 0.2, bodies[1]["temperature"])
+}
+
+func TestRun_APITransportWarnsOnSeed(t *testing.T) {
+	srv, _ := sseServer(t, sse("message_stop", `{"type":"message_stop"}`))
+	opts := apiOptions(srv.URL)
+	seed := 7
+	opts.Seed = &seed
+
+	events, err := collect(New().Run(opts))
+	require.NoError(t, err)
+	require.Equal(t, "warning", events[0].Type)
+	require.Contains(t, events[0].Data["message"], "seed")
+}
+
+func TestHealthCheck_ClaudeOnPath(t *testing.T) {
+	cli := fakeCLI(t, `[ "$1" = --version ] && echo "2.0.0 (Claude Code)"`)
+	t.Setenv("PATH", filepath.Dir(cli))
+	cfg := config.NewDefault()
+	cfg.Agent = "claude"
+
+	require.NoError(t, runner.HealthCheck(cfg))
+}
+
+func TestHealthCheck_MissingClaudeBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	cfg := config.NewDefault()
+	cfg.Agent = "claude"
+
+	err := runner.HealthCheck(cfg)
+	var notFound *runner.ErrAgentNotFound
+	require.ErrorAs(t, err, &notFound)
+	require.Equal(t, "claude", notFound.Command)
+}
+
+func TestPreflight_FailingVersionCheck(t *testing.T) {
+	c := New()
+	c.Command = fakeCLI(t, `echo "broken install" >&2; exit 1`)
+
+	require.ErrorContains(t, c.Preflight(config.Config{}), "--version: exit status 1: broken install")
+}
+
+func TestPreflight_APITransportNeedsKey(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	cfg := config.Config{Claude: config.ClaudeConfig{Transport: config.ClaudeTransportAPI}}
+	require.ErrorIs(t, New().Preflight(cfg), errNoAPIKey)
+
+	cfg.Claude.APIKey = "sk-test"
+	require.NoError(t, New().Preflight(cfg))
+}
+
+// notifications records each Notification it is sent.
+type notifications chan runner.Notification
+
+func (n notifications) Notify(msg runner.Notification) error {
+	n <- msg
+	return nil
+}
+
+func TestRun_TransportsShareRunBehaviour(t *testing.T) {
+	// Both agents answer with a result costing more than the budget.
+	cli := New()
+	cli.Command = fakeCLI(t, `echo '{"type":"result","subtype":"success","result":"plan","total_cost_usd":0.5}'`)
+	srv, _ := sseServer(t, sse("message_start", `{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5","usage":{"input_tokens":200000}}}`)+
+		sse("message_stop", `{"type":"message_stop"}`))
+
+	for _, transport := range []string{config.ClaudeTransportExec, config.ClaudeTransportAPI} {
+		t.Run(transport, func(t *testing.T) {
+			sent := make(notifications, 1)
+			var raw strings.Builder
+			var seen int
+			opts := apiOptions(srv.URL)
+			opts.Config.Claude.Transport = transport
+			opts.Notifier = sent
+			opts.RawLog = &raw
+			opts.OnEvent = func(runner.Event) { seen++ }
+			opts.MaxCostUSD = 0.1
+			opts.FieldMap = map[string]string{"msg": "message"}
+
+			events, err := collect(cli.Run(opts))
+			require.ErrorIs(t, err, runner.ErrBudgetExceeded)
+			require.Equal(t, runner.RunStatusBudgetExceeded, (<-sent).Status)
+			require.NotEmpty(t, raw.String(), "the raw agent output is logged")
+			require.Equal(t, len(events), seen)
+
+			var warned bool
+			for _, e := range events {
+				if msg, _ := e.Data["message"].(string); e.Type == "warning" && strings.Contains(msg, "field map") {
+					warned = true
+				}
+			}
+			require.Equal(t, transport == config.ClaudeTransportAPI, warned, "only the api transport ignores the field map")
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package runner
+
+import "time"
+
+// clock is the source of time for time-based features, so tests can drive
+// timeouts without real delays. Types that need time hold an unexported clock
+// field; nil means realClock.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) timer
+}
+
+// timer is the subset of *time.Timer used through a clock.
+type timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// realClock is the clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) timer         { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// clockOrReal returns c, or realClock if c is nil.
+func clockOrReal(c clock) clock {
+	if c == nil {
+		return realClock{}
+	}
+	return c
+}
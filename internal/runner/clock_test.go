@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), changed: make(chan struct{}, 1)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time { return c.NewTimer(d).C() }
+
+func (c *fakeClock) NewTimer(d time.Duration) timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.schedule(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer that comes due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	kept := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			kept = append(kept, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = kept
+}
+
+// BlockUntil waits until n timers are pending.
+func (c *fakeClock) BlockUntil(t *testing.T, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.timers) >= n
+	}, time.Second, time.Millisecond)
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+// schedule must be called with the clock's lock held.
+func (t *fakeTimer) schedule(d time.Duration) {
+	t.deadline = t.clock.now.Add(d)
+	t.clock.timers = append(t.clock.timers, t)
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.schedule(d)
+	return active
+}
+
+func TestFakeClock_FiresTimersWhenAdvanced(t *testing.T) {
+	c := newFakeClock()
+	start := c.Now()
+	short := c.After(time.Second)
+	long := c.NewTimer(time.Minute)
+
+	c.Advance(999 * time.Millisecond)
+	require.Empty(t, short)
+	c.Advance(time.Millisecond)
+	require.Equal(t, start.Add(time.Second), <-short)
+	require.Empty(t, long.C())
+
+	require.True(t, long.Stop())
+	c.Advance(time.Hour)
+	require.Empty(t, long.C())
+	require.False(t, long.Reset(time.Second))
+	c.Advance(time.Second)
+	require.Len(t, long.C(), 1)
+}
+
+func TestRealClock_Timer(t *testing.T) {
+	tm := clockOrReal(nil).NewTimer(time.Millisecond)
+	<-tm.C()
+	require.False(t, tm.Stop())
+}
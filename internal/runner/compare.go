@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+)
+
+// CompareConcurrency caps how many of Compare's runs are in flight at once.
+const CompareConcurrency = 4
+
+// CompareResult is one model's outcome in a Compare.
+type CompareResult struct {
+	Model    string
+	Plan     string        // final result text with markers stripped
+	Usage    UsageSummary  // tokens and cost of the run
+	Duration time.Duration // wall-clock time of the run
+	Err      error         // why the model produced no plan, if it did not
+}
+
+// Compare plans spec once per model with the agent cfg.Agent names, so the
+// plans can be compared side by side. At most CompareConcurrency runs are in
+// flight at once. Results are returned in the order of models, and a model
+// whose run fails carries the failure in its Err without affecting the
+// others. Cancelling ctx stops the runs in flight and fails those not yet
+// started.
+func Compare(ctx context.Context, cfg config.Config, spec string, models []string) []CompareResult {
+	results := make([]CompareResult, len(models))
+	for i, model := range models {
+		results[i].Model = model
+	}
+	r, err := NewRunner(cfg.Agent)
+	if err != nil {
+		for i := range results {
+			results[i].Err = fmt.Errorf("creating runner: %w", err)
+		}
+		return results
+	}
+
+	slots := make(chan struct{}, CompareConcurrency)
+	var wg sync.WaitGroup
+	for i, model := range models {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			results[i].Err = fmt.Errorf("running %s: %w", cfg.Agent, err)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			began := time.Now()
+			res := &results[i]
+			res.Plan, res.Usage, res.Err = planSpec(ctx, r, cfg, spec, model)
+			res.Duration = time.Since(began)
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,100 @@
+package runner_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+	"github.com/jumppad-labs/spektacular/internal/runner"
+	"github.com/jumppad-labs/spektacular/internal/runner/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// modelRunner plans with text naming the requested model, failing for the
+// model "broken", and tracks how many runs are in flight at once.
+type modelRunner struct {
+	inFlight, maxInFlight *atomic.Int32
+}
+
+func (m modelRunner) Run(opts runner.RunOptions) (<-chan runner.Event, <-chan error) {
+	events := make(chan runner.Event, 2)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(events)
+		n := m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+		for {
+			peak := m.maxInFlight.Load()
+			if n <= peak || m.maxInFlight.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if opts.Model == "broken" {
+			errc <- &runner.ErrRunFailed{Command: "mock", ExitCode: 1}
+			return
+		}
+		events <- testutil.AssistantUsage(opts.Model, 100, 50)
+		events <- runner.Event{Type: "result", Data: map[string]any{"result": "plan by " + opts.Model + "<!--FINISHED-->", "total_cost_usd": 0.01}}
+	}()
+	return events, errc
+}
+
+// registerModelRunner registers a modelRunner for the test and returns the
+// config selecting it and the peak number of concurrent runs.
+func registerModelRunner(t *testing.T) (config.Config, *atomic.Int32) {
+	t.Helper()
+	var inFlight, maxInFlight atomic.Int32
+	cfg := testutil.RegisterRunner(t, "mock-compare", func() runner.Runner { return modelRunner{&inFlight, &maxInFlight} })
+	return cfg, &maxInFlight
+}
+
+func TestCompare_PlansPerModel(t *testing.T) {
+	cfg, _ := registerModelRunner(t)
+
+	results := runner.Compare(context.Background(), cfg, "# Feature: auth", []string{"opus", "broken", "sonnet"})
+	require.Len(t, results, 3)
+
+	require.Equal(t, "opus", results[0].Model)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, "plan by opus", results[0].Plan)
+	require.Equal(t, 0.01, results[0].Usage.CostUSD)
+	require.Equal(t, []string{"opus"}, results[0].Usage.Models)
+	require.Positive(t, results[0].Duration)
+
+	require.Equal(t, "broken", results[1].Model)
+	var failed *runner.ErrRunFailed
+	require.ErrorAs(t, results[1].Err, &failed, "a failing model does not abort the comparison")
+	require.Empty(t, results[1].Plan)
+
+	require.Equal(t, "plan by sonnet", results[2].Plan)
+}
+
+func TestCompare_RespectsConcurrencyCap(t *testing.T) {
+	cfg, maxInFlight := registerModelRunner(t)
+	models := make([]string, runner.CompareConcurrency*2+1)
+	for i := range models {
+		models[i] = "sonnet"
+	}
+
+	for _, res := range runner.Compare(context.Background(), cfg, "spec", models) {
+		require.NoError(t, res.Err)
+	}
+	require.LessOrEqual(t, maxInFlight.Load(), int32(runner.CompareConcurrency))
+}
+
+func TestCompare_CancelledAndUnknownRunner(t *testing.T) {
+	cfg, _ := registerModelRunner(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, res := range runner.Compare(ctx, cfg, "spec", []string{"opus", "sonnet"}) {
+		require.ErrorIs(t, res.Err, context.Canceled)
+	}
+
+	cfg.Agent = "unknown-agent"
+	results := runner.Compare(context.Background(), cfg, "spec", []string{"opus"})
+	require.ErrorContains(t, results[0].Err, "creating runner")
+}
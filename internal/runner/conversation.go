@@ -0,0 +1,113 @@
+package runner
+
+import "strings"
+
+// Turn is one side of a conversation: everything a role said before the other
+// role spoke.
+type Turn struct {
+	Role  string // "user" or "assistant"
+	Text  string // the turn's text blocks, joined by newlines
+	Tools []ToolCall
+}
+
+// ToolCall is a tool the assistant invoked during a turn, with its result
+// once the agent reported one.
+type ToolCall struct {
+	ID      string
+	Name    string
+	Input   map[string]any
+	Output  string // the result's flattened text; empty until it arrives
+	IsError bool
+}
+
+// Conversation reconstructs the user and assistant turns of a run from its
+// events, in order, for replaying prior turns to a follow-up run. Consecutive
+// events of one role form a single turn, so roles alternate. Tool results,
+// which the agent reports in user events, are attached to the tool call they
+// answer rather than starting a user turn, and tool_call events from
+// CombineToolCalls are accepted too. System, result and partial events carry
+// run metadata rather than conversation (see RunMetadata) and are skipped.
+func Conversation(events []Event) []Turn {
+	var turns []Turn
+	calls := map[string]*ToolCall{}
+	// add appends to the last turn when it has role, or starts a new one.
+	add := func(role string, texts []string, tools []ToolCall) {
+		if len(texts) == 0 && len(tools) == 0 {
+			return
+		}
+		if len(turns) == 0 || turns[len(turns)-1].Role != role {
+			turns = append(turns, Turn{Role: role})
+		}
+		t := &turns[len(turns)-1]
+		if len(texts) > 0 {
+			t.Text = strings.TrimPrefix(t.Text+"\n"+strings.Join(texts, "\n"), "\n")
+		}
+		t.Tools = append(t.Tools, tools...)
+	}
+
+	for _, e := range events {
+		switch e.Type {
+		case "assistant":
+			var texts []string
+			if text := e.TextContent(); text != "" {
+				texts = append(texts, text)
+			}
+			var tools []ToolCall
+			for _, block := range e.ToolUses() {
+				call := ToolCall{}
+				call.ID, _ = block["id"].(string)
+				call.Name, _ = block["name"].(string)
+				call.Input, _ = block["input"].(map[string]any)
+				tools = append(tools, call)
+			}
+			add("assistant", texts, tools)
+		case "tool_call":
+			call := ToolCall{}
+			call.ID, _ = e.Data["id"].(string)
+			call.Name, _ = e.Data["name"].(string)
+			call.Input, _ = e.Data["input"].(map[string]any)
+			call.Output, _ = e.Data["output"].(string)
+			call.IsError, _ = e.Data["is_error"].(bool)
+			add("assistant", nil, []ToolCall{call})
+		case "user":
+			for _, r := range e.toolResults() {
+				id, _ := r["tool_use_id"].(string)
+				if call := calls[id]; call != nil {
+					call.Output = toolResultText(r)
+					call.IsError, _ = r["is_error"].(bool)
+				}
+			}
+			add("user", userTexts(e), nil)
+		}
+		// Pointers into the last turn's Tools stay valid until it grows, so
+		// they are refreshed after every event.
+		if len(turns) > 0 {
+			last := &turns[len(turns)-1]
+			for i := range last.Tools {
+				if id := last.Tools[i].ID; id != "" {
+					calls[id] = &last.Tools[i]
+				}
+			}
+		}
+	}
+	return turns
+}
+
+// userTexts returns the text of a user event: its message content when that
+// is a plain string, or else its text blocks.
+func userTexts(e Event) []string {
+	msg, _ := e.Data["message"].(map[string]any)
+	if s, ok := msg["content"].(string); ok {
+		if s == "" {
+			return nil
+		}
+		return []string{s}
+	}
+	var texts []string
+	for _, block := range e.contentBlocks() {
+		if t, ok := block["text"].(string); ok && block["type"] == "text" {
+			texts = append(texts, t)
+		}
+	}
+	return texts
+}
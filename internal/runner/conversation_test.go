@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// userText builds a user event carrying a message with a plain string content.
+func userText(text string) Event {
+	return Event{Type: "user", Data: map[string]any{"message": map[string]any{"content": text}}}
+}
+
+func textBlock(text string) map[string]any {
+	return map[string]any{"type": "text", "text": text}
+}
+
+func TestConversation_MultiTurn(t *testing.T) {
+	events := []Event{
+		{Type: "system", Data: map[string]any{"subtype": "init", "session_id": "s1"}},
+		userText("fix the build"),
+		assistantBlocks(textBlock("Let me look.")),
+		assistantBlocks(toolUse("t1", "Bash", map[string]any{"command": "go build ./..."})),
+		toolResult("t1", true, "undefined: foo"),
+		assistantBlocks(textBlock("foo is missing; adding it.")),
+		{Type: "stream_event", Data: map[string]any{"event": map[string]any{"type": "content_block_delta"}}},
+		{Type: "result", Data: map[string]any{"result": "done"}},
+		userText("thanks, now run the tests"),
+		assistantBlocks(textBlock("All tests pass.")),
+	}
+
+	turns := Conversation(events)
+
+	require.Len(t, turns, 4)
+	for i, turn := range turns {
+		require.Equal(t, []string{"user", "assistant"}[i%2], turn.Role, "turn %d", i)
+	}
+	require.Equal(t, "fix the build", turns[0].Text)
+	require.Equal(t, "Let me look.\nfoo is missing; adding it.", turns[1].Text)
+	require.Equal(t, []ToolCall{{
+		ID:      "t1",
+		Name:    "Bash",
+		Input:   map[string]any{"command": "go build ./..."},
+		Output:  "undefined: foo",
+		IsError: true,
+	}}, turns[1].Tools)
+	require.Equal(t, "thanks, now run the tests", turns[2].Text)
+	require.Equal(t, "All tests pass.", turns[3].Text)
+	require.Empty(t, turns[3].Tools)
+}
+
+func TestConversation_UserTextBlocks(t *testing.T) {
+	user := Event{Type: "user", Data: map[string]any{"message": map[string]any{
+		"content": []any{textBlock("first"), textBlock("second")},
+	}}}
+
+	turns := Conversation([]Event{user, assistantBlocks(textBlock("ok"))})
+
+	require.Equal(t, []Turn{{Role: "user", Text: "first\nsecond"}, {Role: "assistant", Text: "ok"}}, turns)
+}
+
+func TestConversation_CombinedToolCalls(t *testing.T) {
+	events := CombineToolCalls([]Event{
+		assistantBlocks(toolUse("t1", "Read", map[string]any{"file_path": "a.go"})),
+		toolResult("t1", false, "package a"),
+		assistantBlocks(textBlock("read it")),
+	})
+
+	turns := Conversation(events)
+
+	require.Len(t, turns, 1)
+	require.Equal(t, "read it", turns[0].Text)
+	require.Len(t, turns[0].Tools, 1)
+	require.Equal(t, "Read", turns[0].Tools[0].Name)
+	require.Equal(t, "package a", turns[0].Tools[0].Output)
+}
+
+func TestConversation_MetadataOnly(t *testing.T) {
+	require.Empty(t, Conversation([]Event{
+		{Type: "system", Data: map[string]any{"subtype": "init"}},
+		{Type: "result", Data: map[string]any{"result": "done"}},
+	}))
+}
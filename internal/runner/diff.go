@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"reflect"
+	"slices"
+)
+
+// DefaultVolatileFields are the event fields DiffRecordings ignores: values
+// that differ between otherwise identical runs, at any depth of an event's
+// data.
+var DefaultVolatileFields = []string{"session_id", "uuid", "id", "tool_use_id", "timestamp", "duration_ms", "duration_api_ms"}
+
+// DiffOptions controls DiffRecordingsWithOptions.
+type DiffOptions struct {
+	// IgnoreFields are data keys left out of the comparison wherever they
+	// occur. Nil compares every field.
+	IgnoreFields []string
+}
+
+// DiffEntry is an event found in only one recording, with its index there.
+type DiffEntry struct {
+	Index int
+	Event Event
+}
+
+// EventChange is a pair of aligned events whose data differs.
+type EventChange struct {
+	IndexA, IndexB int
+	A, B           Event
+}
+
+// RecordingDiff reports how recording B differs from recording A.
+type RecordingDiff struct {
+	Added   []DiffEntry // in B only
+	Removed []DiffEntry // in A only
+	Changed []EventChange
+}
+
+// Identical reports whether the recordings matched.
+func (d RecordingDiff) Identical() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffRecordings compares two recorded runs, ignoring DefaultVolatileFields.
+func DiffRecordings(a, b []Event) RecordingDiff {
+	return DiffRecordingsWithOptions(a, b, DiffOptions{IgnoreFields: DefaultVolatileFields})
+}
+
+// DiffRecordingsWithOptions compares two recorded runs. Events are aligned by
+// the longest common subsequence of their types, preferring pairs whose data
+// is equal; aligned events with differing data are changes, and the rest are
+// additions or removals.
+func DiffRecordingsWithOptions(a, b []Event, opts DiffOptions) RecordingDiff {
+	na := normalizeEvents(a, opts.IgnoreFields)
+	nb := normalizeEvents(b, opts.IgnoreFields)
+
+	// score[i][j] is the best alignment of a[i:] and b[j:]: two points for an
+	// equal pair, one for a pair with only the type in common.
+	score := make([][]int, len(a)+1)
+	for i := range score {
+		score[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			best := max(score[i+1][j], score[i][j+1])
+			if p := pairScore(a[i], b[j], na[i], nb[j]); p > 0 {
+				best = max(best, score[i+1][j+1]+p)
+			}
+			score[i][j] = best
+		}
+	}
+
+	var d RecordingDiff
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		p := pairScore(a[i], b[j], na[i], nb[j])
+		switch {
+		case p > 0 && score[i][j] == score[i+1][j+1]+p:
+			if p == 1 {
+				d.Changed = append(d.Changed, EventChange{IndexA: i, IndexB: j, A: a[i], B: b[j]})
+			}
+			i, j = i+1, j+1
+		case score[i][j] == score[i+1][j]:
+			d.Removed = append(d.Removed, DiffEntry{Index: i, Event: a[i]})
+			i++
+		default:
+			d.Added = append(d.Added, DiffEntry{Index: j, Event: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		d.Removed = append(d.Removed, DiffEntry{Index: i, Event: a[i]})
+	}
+	for ; j < len(b); j++ {
+		d.Added = append(d.Added, DiffEntry{Index: j, Event: b[j]})
+	}
+	return d
+}
+
+// pairScore rates aligning two events: 2 if equal after normalizing, 1 if
+// only their types match, 0 if they cannot be aligned.
+func pairScore(a, b Event, na, nb any) int {
+	if a.Type != b.Type {
+		return 0
+	}
+	if reflect.DeepEqual(na, nb) {
+		return 2
+	}
+	return 1
+}
+
+func normalizeEvents(events []Event, ignore []string) []any {
+	out := make([]any, len(events))
+	for i, e := range events {
+		out[i] = withoutFields(e.Data, ignore)
+	}
+	return out
+}
+
+// withoutFields returns a copy of v with the ignored keys removed from every
+// map within it.
+func withoutFields(v any, ignore []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, val := range v {
+			if !slices.Contains(ignore, k) {
+				out[k] = withoutFields(val, ignore)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = withoutFields(val, ignore)
+		}
+		return out
+	default:
+		return v
+	}
+}
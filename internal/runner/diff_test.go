@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func recording(session, plan string) []Event {
+	return []Event{
+		{Type: "system", Data: map[string]any{"subtype": "init", "session_id": session}},
+		assistantBlocks(map[string]any{"type": "text", "text": "Reading."}, toolUse(session+"-1", "Read", map[string]any{"file_path": "spec.md"})),
+		{Type: "result", Data: map[string]any{"result": plan, "session_id": session, "duration_ms": 1200.0}},
+	}
+}
+
+func TestDiffRecordings_IdenticalIgnoresVolatileFields(t *testing.T) {
+	d := DiffRecordings(recording("s1", "plan"), recording("s2", "plan"))
+	require.True(t, d.Identical(), "%+v", d)
+
+	strict := DiffRecordingsWithOptions(recording("s1", "plan"), recording("s2", "plan"), DiffOptions{})
+	require.Len(t, strict.Changed, 3, "without ignored fields the session IDs differ")
+}
+
+func TestDiffRecordings_AddedEvent(t *testing.T) {
+	a := recording("s1", "plan")
+	extra := assistantBlocks(map[string]any{"type": "text", "text": "Checking tests."})
+	b := append(append(append([]Event{}, a[:2]...), extra), a[2])
+
+	d := DiffRecordings(a, b)
+	require.Empty(t, d.Removed)
+	require.Empty(t, d.Changed)
+	require.Equal(t, []DiffEntry{{Index: 2, Event: extra}}, d.Added)
+
+	reverse := DiffRecordings(b, a)
+	require.Equal(t, []DiffEntry{{Index: 2, Event: extra}}, reverse.Removed)
+}
+
+func TestDiffRecordings_ChangedText(t *testing.T) {
+	d := DiffRecordings(recording("s1", "## Plan v1"), recording("s1", "## Plan v2"))
+	require.Empty(t, d.Added)
+	require.Empty(t, d.Removed)
+	require.Len(t, d.Changed, 1)
+	require.Equal(t, 2, d.Changed[0].IndexA)
+	require.Equal(t, "## Plan v1", d.Changed[0].A.ResultText())
+	require.Equal(t, "## Plan v2", d.Changed[0].B.ResultText())
+}
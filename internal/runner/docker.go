@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// DefaultContainerWorkdir is where Docker mounts the run's working directory.
+const DefaultContainerWorkdir = "/workspace"
+
+// Mount is a host path bind-mounted into the container.
+type Mount struct {
+	Source   string // host path
+	Target   string // container path
+	ReadOnly bool
+}
+
+// Docker wraps a CommandRunner so each run executes inside a container via
+// `docker run`. The run's working directory is mounted at Workdir and used as
+// the container's working directory; the inner command line is executed
+// unchanged inside the image. Streaming and channel semantics are identical
+// to running the inner runner directly.
+type Docker struct {
+	Inner   CommandRunner
+	Image   string
+	Mounts  []Mount
+	Env     []string // KEY=VALUE, or KEY to pass the host value through
+	Workdir string   // container working directory; empty uses DefaultContainerWorkdir
+	Binary  string   // docker CLI to invoke; empty uses "docker"
+}
+
+// NewDocker returns a Docker runner executing inner inside image.
+func NewDocker(inner CommandRunner, image string) *Docker {
+	return &Docker{Inner: inner, Image: image}
+}
+
+// Run executes the inner runner's command inside the container.
+func (d *Docker) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	return Exec(d, opts)
+}
+
+// Cmd builds the `docker run` subprocess wrapping the inner runner's command.
+// The inner command's stdin and cleanup are carried over, and the variables
+// it adds to the host environment, such as IdempotencyKeyEnv, are passed into
+// the container ahead of Env.
+func (d *Docker) Cmd(opts RunOptions) (*exec.Cmd, func(), error) {
+	if d.Image == "" {
+		return nil, nil, fmt.Errorf("docker runner: image must not be empty")
+	}
+	inner, cleanup, err := d.Inner.Cmd(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostDir := inner.Dir
+	if hostDir == "" {
+		hostDir, err = os.Getwd()
+		if err != nil {
+			if cleanup != nil {
+				cleanup()
+			}
+			return nil, nil, fmt.Errorf("getting working directory: %w", err)
+		}
+	}
+
+	binary := d.Binary
+	if binary == "" {
+		binary = "docker"
+	}
+	cmd := exec.Command(binary, d.buildArgs(hostDir, envAdditions(inner.Env), inner.Args)...) //nolint:gosec
+	cmd.Stdin = inner.Stdin
+	return cmd, cleanup, nil
+}
+
+// buildArgs assembles the `docker run` arguments for running innerArgs with
+// hostDir mounted as the working directory and env set in the container.
+func (d *Docker) buildArgs(hostDir string, env, innerArgs []string) []string {
+	workdir := d.Workdir
+	if workdir == "" {
+		workdir = DefaultContainerWorkdir
+	}
+
+	args := []string{"run", "--rm", "-i",
+		"-v", hostDir + ":" + workdir,
+		"-w", workdir,
+	}
+	for _, m := range d.Mounts {
+		spec := m.Source + ":" + m.Target
+		if m.ReadOnly {
+			spec += ":ro"
+		}
+		args = append(args, "-v", spec)
+	}
+	for _, e := range append(env, d.Env...) {
+		args = append(args, "-e", e)
+	}
+	args = append(args, d.Image)
+	return append(args, innerArgs...)
+}
+
+// envAdditions returns the KEY=VALUE entries of env that the host environment
+// does not already hold. A nil env, which inherits the host environment, has
+// none.
+func envAdditions(env []string) []string {
+	host := map[string]bool{}
+	for _, e := range os.Environ() {
+		host[e] = true
+	}
+	var added []string
+	for _, e := range env {
+		if !host[e] {
+			added = append(added, e)
+		}
+	}
+	return added
+}
@@ -0,0 +1,148 @@
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stubCommandRunner is a CommandRunner returning a fixed command line.
+type stubCommandRunner struct {
+	args    []string
+	dir     string
+	env     []string // nil inherits the host environment
+	err     error
+	cleaned bool
+}
+
+func (s *stubCommandRunner) Run(opts RunOptions) (<-chan Event, <-chan error) { return Exec(s, opts) }
+
+func (s *stubCommandRunner) Cmd(_ RunOptions) (*exec.Cmd, func(), error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	cmd := exec.Command(s.args[0], s.args[1:]...)
+	cmd.Dir = s.dir
+	cmd.Env = s.env
+	cmd.Stdin = strings.NewReader("prompt on stdin")
+	return cmd, func() { s.cleaned = true }, nil
+}
+
+func TestDocker_Cmd_BuildsDockerRunArgv(t *testing.T) {
+	inner := &stubCommandRunner{args: []string{"claude", "-p", "plan it"}, dir: "/home/me/repo"}
+	d := NewDocker(inner, "ghcr.io/acme/agent:latest")
+	d.Mounts = []Mount{
+		{Source: "/home/me/shared", Target: "/shared", ReadOnly: true},
+		{Source: "/home/me/.claude", Target: "/root/.claude"},
+	}
+	d.Env = []string{"ANTHROPIC_API_KEY", "LOG_LEVEL=debug"}
+
+	cmd, cleanup, err := d.Cmd(RunOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"docker", "run", "--rm", "-i",
+		"-v", "/home/me/repo:/workspace",
+		"-w", "/workspace",
+		"-v", "/home/me/shared:/shared:ro",
+		"-v", "/home/me/.claude:/root/.claude",
+		"-e", "ANTHROPIC_API_KEY",
+		"-e", "LOG_LEVEL=debug",
+		"ghcr.io/acme/agent:latest",
+		"claude", "-p", "plan it",
+	}, cmd.Args)
+	require.NotNil(t, cmd.Stdin, "inner stdin should be forwarded to docker")
+
+	cleanup()
+	require.True(t, inner.cleaned)
+}
+
+func TestDocker_Cmd_CustomWorkdirAndCurrentDir(t *testing.T) {
+	inner := &stubCommandRunner{args: []string{"claude"}}
+	d := &Docker{Inner: inner, Image: "img", Workdir: "/src"}
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	cmd, _, err := d.Cmd(RunOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"docker", "run", "--rm", "-i", "-v", cwd + ":/src", "-w", "/src", "img", "claude"}, cmd.Args)
+}
+
+func TestDocker_Cmd_Errors(t *testing.T) {
+	_, _, err := (&Docker{Inner: &stubCommandRunner{args: []string{"x"}}}).Cmd(RunOptions{})
+	require.ErrorContains(t, err, "image must not be empty")
+
+	boom := errors.New("boom")
+	_, _, err = NewDocker(&stubCommandRunner{err: boom}, "img").Cmd(RunOptions{})
+	require.ErrorIs(t, err, boom)
+}
+
+func TestDocker_Run_StreamsEventsFromStubbedDocker(t *testing.T) {
+	// A stubbed docker binary that emits stream-json like the wrapped agent.
+	bin := filepath.Join(t.TempDir(), "docker")
+	script := "#!/bin/sh\necho 'pulling image' >&2\necho '{\"type\":\"result\",\"result\":\"containerised plan\"}'\n"
+	require.NoError(t, os.WriteFile(bin, []byte(script), 0755))
+
+	inner := &stubCommandRunner{args: []string{"claude", "-p"}, dir: t.TempDir()}
+	d := NewDocker(inner, "img")
+	d.Binary = bin
+
+	events, err := collect(d.Run(RunOptions{}))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	var result string
+	for _, e := range events {
+		if e.IsResult() {
+			result = e.ResultText()
+		}
+	}
+	require.Equal(t, "containerised plan", result)
+	require.True(t, inner.cleaned, "inner cleanup should run after the container exits")
+}
+
+func TestDocker_Cmd_PassesInnerEnvAdditions(t *testing.T) {
+	t.Setenv("SPEKTACULAR_TEST_HOST", "already set")
+	key := IdempotencyKeyEnv + "=" + RunOptions{Prompts: Prompts{User: "plan it"}}.ResolveIdempotencyKey()
+	inner := &stubCommandRunner{
+		args: []string{"claude", "-p", "plan it"},
+		dir:  "/repo",
+		env:  append(os.Environ(), key),
+	}
+	d := NewDocker(inner, "agent:latest")
+	d.Env = []string{"LOG_LEVEL=debug"}
+
+	cmd, _, err := d.Cmd(RunOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"docker", "run", "--rm", "-i",
+		"-v", "/repo:/workspace",
+		"-w", "/workspace",
+		"-e", key,
+		"-e", "LOG_LEVEL=debug",
+		"agent:latest",
+		"claude", "-p", "plan it",
+	}, cmd.Args, "only the inner command's additions to the host environment are passed")
+}
+
+func TestDocker_Run_IdempotencyKeyReachesContainer(t *testing.T) {
+	// A stubbed docker binary that, like the container's agent, reports the
+	// idempotency key its environment was given through -e.
+	bin := filepath.Join(t.TempDir(), "docker")
+	script := "#!/bin/sh\nfor a; do case \"$a\" in " + IdempotencyKeyEnv + "=*) key=\"${a#*=}\";; esac; done\n" +
+		"printf '{\"type\":\"result\",\"result\":\"%s\"}\\n' \"$key\"\n"
+	require.NoError(t, os.WriteFile(bin, []byte(script), 0755))
+
+	inner := &stubCommandRunner{args: []string{"claude"}, dir: t.TempDir(), env: append(os.Environ(), IdempotencyKeyEnv+"=run-42")}
+	d := NewDocker(inner, "img")
+	d.Binary = bin
+
+	events, err := collect(d.Run(RunOptions{}))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "run-42", events[0].ResultText())
+}
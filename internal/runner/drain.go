@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoSessionID is returned by AwaitSessionID when the event stream ends
+// before any event carries a session ID.
+var ErrNoSessionID = errors.New("event stream ended without a session id")
+
+// Drain consumes the channels returned by Runner.Run, invoking onEvent for
+// every event in order. It keeps reading until both channels are closed, so
+// the runner goroutine is never left blocked on a send, and then returns the
+// first non-nil error received. If ctx is cancelled first, Drain returns
+// ctx.Err() at once and leaves a goroutine discarding whatever else arrives
+// until both channels close. onEvent may be nil.
+func Drain(ctx context.Context, events <-chan Event, errc <-chan error, onEvent func(Event)) error {
+	var firstErr error
+	for events != nil || errc != nil {
+		select {
+		case <-ctx.Done():
+			go discard(events, errc)
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if onEvent != nil {
+				onEvent(e)
+			}
+		case err, ok := <-errc:
+			if !ok {
+				errc = nil
+				continue
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// discard reads events and errc until both are closed, dropping what arrives.
+// A nil channel counts as closed.
+func discard(events <-chan Event, errc <-chan error) {
+	for events != nil || errc != nil {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+			}
+		case _, ok := <-errc:
+			if !ok {
+				errc = nil
+			}
+		}
+	}
+}
+
+// RunUntilQuestion starts a run and collects its events until the agent asks
+// a question or the run ends, returning the events seen so far and the
+// questions. When a question arrives the rest of the run is cancelled and
+// discarded, handing control back to the caller; to continue, run again with
+// SessionID set to the session of the returned events and the user prompt set
+// to the answers, e.g. from FormatAnswers. Questions is empty if the run ended
+// without asking. Cancelling ctx stops the run and returns ctx.Err().
+func RunUntilQuestion(ctx context.Context, r Runner, opts RunOptions) ([]Event, []Question, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	opts.Context = runCtx
+	events, errc := r.Run(opts)
+
+	var seen []Event
+	for e := range events {
+		seen = append(seen, e)
+		if qs := detectQuestions(e.TextContent()); len(qs) > 0 {
+			cancel()
+			// Drain so the runner's goroutine can exit; the error is the
+			// cancellation we just caused.
+			Drain(context.Background(), events, errc, nil)
+			return seen, qs, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return seen, nil, err
+	}
+	return seen, nil, <-errc
+}
+
+// AwaitSessionID reads events until one carries a session ID and returns the
+// ID together with every event read so far, including that one, so the caller
+// can replay them to its downstream consumer before continuing to read the
+// channel. It returns ErrNoSessionID if events closes first, or ctx.Err() if
+// ctx is done first; the events read are returned in either case.
+func AwaitSessionID(ctx context.Context, events <-chan Event) (string, []Event, error) {
+	var seen []Event
+	for {
+		select {
+		case <-ctx.Done():
+			return "", seen, ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return "", seen, ErrNoSessionID
+			}
+			seen = append(seen, e)
+			if id := e.SessionID(); id != "" {
+				return id, seen, nil
+			}
+		}
+	}
+}
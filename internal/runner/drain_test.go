@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrain_NormalCompletion(t *testing.T) {
+	events := make(chan Event, 3)
+	errc := make(chan error, 1)
+	events <- Event{Type: "system"}
+	events <- Event{Type: "assistant"}
+	events <- Event{Type: "result"}
+	close(events)
+	close(errc)
+
+	var types []string
+	err := Drain(context.Background(), events, errc, func(e Event) { types = append(types, e.Type) })
+	require.NoError(t, err)
+	require.Equal(t, []string{"system", "assistant", "result"}, types)
+}
+
+func TestDrain_ErrorMidStream(t *testing.T) {
+	events := make(chan Event)
+	errc := make(chan error, 2)
+	boom := errors.New("boom")
+
+	go func() {
+		events <- Event{Type: "assistant"}
+		errc <- boom
+		errc <- errors.New("second")
+		// Events delivered after the error are still consumed so the
+		// producer never blocks.
+		events <- Event{Type: "result"}
+		close(events)
+		close(errc)
+	}()
+
+	var types []string
+	err := Drain(context.Background(), events, errc, func(e Event) { types = append(types, e.Type) })
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, []string{"assistant", "result"}, types)
+}
+
+func TestDrain_ContextCancellation(t *testing.T) {
+	events := make(chan Event)
+	errc := make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() { done <- Drain(ctx, events, errc, nil) }()
+
+	events <- Event{Type: "assistant"}
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	close(events)
+	close(errc)
+}
+
+func TestDrain_CancellationLeavesNoProducerBlocked(t *testing.T) {
+	events := make(chan Event)
+	errc := make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	base := runtime.NumGoroutine()
+
+	require.ErrorIs(t, Drain(ctx, events, errc, nil), context.Canceled)
+
+	// The producer's unbuffered sends after the cancellation still complete.
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for range 10 {
+			events <- Event{Type: "assistant"}
+		}
+		close(events)
+		errc <- context.Canceled
+		close(errc)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("producer left blocked after Drain returned")
+	}
+	// Polled here rather than with require.Eventually, whose condition runs on
+	// a goroutine of its own.
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > base; time.Sleep(time.Millisecond) {
+		require.True(t, time.Now().Before(deadline), "the discarding goroutine exits once both channels close")
+	}
+}
+
+func TestRunUntilQuestion_StopsAtFirstQuestion(t *testing.T) {
+	script := `echo '{"type":"system","session_id":"s1"}'
+echo '{"type":"assistant","message":{"content":[{"type":"text","text":"Reading the spec."}]}}'
+echo '{"type":"assistant","message":{"content":[{"type":"text","text":"<!--QUESTION:{\"questions\":[{\"question\":\"Which DB?\",\"header\":\"Database\"}]}-->"}]}}'
+sleep 10
+echo '{"type":"result","result":"too late"}'`
+
+	start := time.Now()
+	events, qs, err := RunUntilQuestion(context.Background(), shellRunner{}, RunOptions{Prompts: Prompts{User: script}})
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Len(t, events, 3)
+	require.Equal(t, "s1", events[0].SessionID())
+	require.Len(t, qs, 1)
+	require.Equal(t, "Database", qs[0].Header)
+
+	// Resuming: the session and formatted answer make the follow-up run.
+	resume := RunOptions{SessionID: events[0].SessionID(), Prompts: Prompts{User: FormatAnswers(qs, map[string]string{"Database": "Postgres"})}}
+	require.Contains(t, resume.Prompts.User, "- Database (Which DB?): Postgres")
+}
+
+func TestRunUntilQuestion_RunEndsWithoutQuestion(t *testing.T) {
+	script := `echo '{"type":"assistant","message":{"content":[{"type":"text","text":"plan"}]}}'; echo '{"type":"result","result":"plan"}'`
+
+	events, qs, err := RunUntilQuestion(context.Background(), shellRunner{}, RunOptions{Prompts: Prompts{User: script}})
+	require.NoError(t, err)
+	require.Empty(t, qs)
+	require.Len(t, events, 2)
+	require.Equal(t, "plan", events[1].ResultText())
+}
+
+func TestRunUntilQuestion_ReturnsRunError(t *testing.T) {
+	_, qs, err := RunUntilQuestion(context.Background(), shellRunner{}, RunOptions{Prompts: Prompts{User: "exit 4"}, DiscardStderr: true})
+	require.Empty(t, qs)
+	var failed *ErrRunFailed
+	require.ErrorAs(t, err, &failed)
+}
+
+func TestAwaitSessionID_Early(t *testing.T) {
+	events := make(chan Event, 3)
+	events <- Event{Type: "system", Data: map[string]any{"session_id": "s1"}}
+	events <- Event{Type: "assistant", Data: map[string]any{}}
+
+	id, seen, err := AwaitSessionID(context.Background(), events)
+	require.NoError(t, err)
+	require.Equal(t, "s1", id)
+	require.Len(t, seen, 1)
+	require.Len(t, events, 1, "events after the session ID are left for the consumer")
+}
+
+func TestAwaitSessionID_Late(t *testing.T) {
+	events := make(chan Event, 3)
+	events <- Event{Type: "stderr", Data: map[string]any{"line": "warming up"}}
+	events <- Event{Type: "stdout", Data: map[string]any{"line": "INFO"}}
+	events <- Event{Type: "system", Data: map[string]any{"session_id": "s2"}}
+
+	id, seen, err := AwaitSessionID(context.Background(), events)
+	require.NoError(t, err)
+	require.Equal(t, "s2", id)
+	require.Equal(t, []string{"stderr", "stdout", "system"}, []string{seen[0].Type, seen[1].Type, seen[2].Type})
+}
+
+func TestAwaitSessionID_Never(t *testing.T) {
+	events := make(chan Event, 1)
+	events <- Event{Type: "stderr", Data: map[string]any{"line": "boom"}}
+	close(events)
+
+	id, seen, err := AwaitSessionID(context.Background(), events)
+	require.ErrorIs(t, err, ErrNoSessionID)
+	require.Empty(t, id)
+	require.Len(t, seen, 1)
+}
+
+func TestAwaitSessionID_ContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, _, err := AwaitSessionID(ctx, make(chan Event))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
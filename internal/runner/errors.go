@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ErrUnsupportedRunner is returned by NewRunner when no runner is registered
+// under the requested command.
+type ErrUnsupportedRunner struct {
+	Command   string
+	Available []string // registered names, sorted
+}
+
+func (e *ErrUnsupportedRunner) Error() string {
+	return fmt.Sprintf("unsupported runner: %q (available: %v)", e.Command, e.Available)
+}
+
+// ErrAgentNotFound is returned when the agent executable cannot be found or
+// started.
+type ErrAgentNotFound struct {
+	Command string
+	Err     error
+}
+
+func (e *ErrAgentNotFound) Error() string {
+	return fmt.Sprintf("starting %s process: %v", e.Command, e.Err)
+}
+
+func (e *ErrAgentNotFound) Unwrap() error { return e.Err }
+
+// ErrRunFailed is returned when the agent process exits unsuccessfully.
+// ExitCode is -1 when the process did not exit normally, e.g. it was killed.
+type ErrRunFailed struct {
+	Command  string
+	ExitCode int
+	Err      error
+}
+
+func (e *ErrRunFailed) Error() string {
+	return fmt.Sprintf("%s process exited with error: %v", e.Command, e.Err)
+}
+
+func (e *ErrRunFailed) Unwrap() error { return e.Err }
+
+// ErrTimeout is returned when a run is killed for exceeding
+// RunOptions.Timeout or, when Idle is set, for going RunOptions.IdleTimeout
+// without an event from the agent. It matches context.DeadlineExceeded.
+type ErrTimeout struct {
+	Idle  bool
+	Limit time.Duration
+}
+
+func (e *ErrTimeout) Error() string {
+	if e.Idle {
+		return fmt.Sprintf("run killed after %s without an event from the agent", e.Limit)
+	}
+	return fmt.Sprintf("run killed after exceeding its %s timeout", e.Limit)
+}
+
+func (e *ErrTimeout) Unwrap() error { return context.DeadlineExceeded }
+
+// ErrFatalStderr is returned when a stderr line of the agent matches one of
+// RunOptions.FatalStderrPatterns, after the run has been killed.
+type ErrFatalStderr struct {
+	Line    string // the matching stderr line
+	Pattern string // the pattern it matched
+}
+
+func (e *ErrFatalStderr) Error() string {
+	return fmt.Sprintf("stopped on fatal stderr line: %s", e.Line)
+}
+
+// ErrAnswerMismatch is returned when an answer to a text question does not
+// match the question's Pattern.
+type ErrAnswerMismatch struct {
+	Header  string // the question's header
+	Answer  string
+	Pattern string
+}
+
+func (e *ErrAnswerMismatch) Error() string {
+	return fmt.Sprintf("question %q: answer %q does not match the required pattern %s", e.Header, e.Answer, e.Pattern)
+}
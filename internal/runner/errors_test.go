@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRunner_UnsupportedRunnerErrorAs(t *testing.T) {
+	_, err := NewRunner("unknown-agent")
+
+	var target *ErrUnsupportedRunner
+	require.True(t, errors.As(err, &target))
+	require.Equal(t, "unknown-agent", target.Command)
+	require.Equal(t, registeredNames(), target.Available)
+}
+
+func TestRunCommand_AgentNotFoundErrorAs(t *testing.T) {
+	_, err := collect(RunCommand(exec.Command("spektacular-no-such-agent"), RunOptions{}))
+
+	var target *ErrAgentNotFound
+	require.True(t, errors.As(err, &target))
+	require.Equal(t, "spektacular-no-such-agent", target.Command)
+	require.ErrorIs(t, err, exec.ErrNotFound)
+	require.Contains(t, err.Error(), "starting spektacular-no-such-agent process")
+}
+
+func TestRunCommand_RunFailedErrorAs(t *testing.T) {
+	_, err := collect(RunCommand(fakeProcess(`exit 3`), RunOptions{DiscardStderr: true}))
+
+	var target *ErrRunFailed
+	require.True(t, errors.As(err, &target))
+	require.Equal(t, "sh", target.Command)
+	require.Equal(t, 3, target.ExitCode)
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, "sh process exited with error: exit status 3", err.Error())
+}
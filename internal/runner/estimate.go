@@ -0,0 +1,50 @@
+package runner
+
+import "github.com/jumppad-labs/spektacular/internal/pricing"
+
+// charsPerToken is the rough prompt-size heuristic used for estimates. It
+// tracks typical English and code prose; exact counts need the provider's
+// tokenizer.
+const charsPerToken = 4
+
+// RunEstimate is an upfront estimate of a run's prompt size and input cost.
+type RunEstimate struct {
+	Model       string
+	InputTokens int
+	CostUSD     float64 // input cost only; zero when the model is unpriced
+	Priced      bool    // whether the model is registered with the pricing package
+}
+
+// EstimateTokens returns a rough token count for text.
+func EstimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// EstimateRun estimates the input tokens and cost of sending prompt to model
+// without calling the agent, at the input rate registered for model with the
+// pricing package. Output tokens are unknowable upfront, so the cost
+// covers the prompt only and is a lower bound for the run.
+func EstimateRun(prompt string, model string) RunEstimate {
+	est := RunEstimate{Model: model, InputTokens: EstimateTokens(prompt)}
+	if price, ok := pricing.Lookup(model); ok {
+		est.Priced = true
+		est.CostUSD = price.Cost(est.InputTokens, 0, 0, 0)
+	}
+	return est
+}
+
+// FitsContext estimates whether prompt fits in model's context window, as
+// registered with the pricing package, so an overflow can be reported before
+// the run starts. It returns whether the prompt fits and, when it does not,
+// the estimated number of tokens over the window. Unknown models, and the
+// agent default named by an empty model, are assumed to have
+// pricing.DefaultContextWindow. The estimate covers the prompt only; the
+// model's output also needs room in the window.
+func FitsContext(prompt string, model string) (bool, int) {
+	window, _ := pricing.ContextWindow(model)
+	over := EstimateTokens(prompt) - window
+	if over > 0 {
+		return false, over
+	}
+	return true, 0
+}
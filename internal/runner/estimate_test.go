@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jumppad-labs/spektacular/internal/pricing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	require.Equal(t, 0, EstimateTokens(""))
+	require.Equal(t, 1, EstimateTokens("abc"))
+	require.Equal(t, 1, EstimateTokens("abcd"))
+	require.Equal(t, 2, EstimateTokens("abcde"))
+}
+
+func TestEstimateRun_CostForKnownModels(t *testing.T) {
+	prompt := strings.Repeat("x", 4_000_000) // one million tokens
+
+	sonnet := EstimateRun(prompt, "sonnet")
+	require.Equal(t, 1_000_000, sonnet.InputTokens)
+	require.True(t, sonnet.Priced)
+	require.InDelta(t, 3.0, sonnet.CostUSD, 1e-9)
+
+	opus := EstimateRun(prompt[:400_000], "claude-opus-4-1")
+	require.Equal(t, 100_000, opus.InputTokens)
+	require.InDelta(t, 1.5, opus.CostUSD, 1e-9)
+}
+
+func TestEstimateRun_UnknownModelIsUnpriced(t *testing.T) {
+	est := EstimateRun("some prompt", "gpt-x")
+	require.False(t, est.Priced)
+	require.Zero(t, est.CostUSD)
+	require.Equal(t, 3, est.InputTokens)
+}
+
+func TestEstimateRun_UsesRegisteredPriceOverride(t *testing.T) {
+	orig, _ := pricing.Lookup("sonnet")
+	pricing.Register("sonnet", pricing.Price{Input: 10})
+	defer pricing.Register("sonnet", orig)
+
+	est := EstimateRun(strings.Repeat("x", 400_000), "sonnet")
+	require.InDelta(t, 1.0, est.CostUSD, 1e-9)
+}
+
+func TestFitsContext_SmallPromptFits(t *testing.T) {
+	fits, over := FitsContext("# Feature: auth", "sonnet")
+	require.True(t, fits)
+	require.Zero(t, over)
+}
+
+func TestFitsContext_LargePromptOverflowsSmallWindow(t *testing.T) {
+	orig, _ := pricing.ContextWindow("haiku")
+	pricing.RegisterContextWindow("haiku", 1000)
+	defer pricing.RegisterContextWindow("haiku", orig)
+
+	prompt := strings.Repeat("x", 6000) // 1500 tokens
+	fits, over := FitsContext(prompt, "haiku")
+	require.False(t, fits)
+	require.Equal(t, 500, over)
+
+	fits, _ = FitsContext(prompt, "sonnet")
+	require.True(t, fits, "the same prompt fits a full-size window")
+}
+
+func TestFitsContext_UnknownModelUsesDefaultWindow(t *testing.T) {
+	fits, over := FitsContext(strings.Repeat("x", (pricing.DefaultContextWindow+10)*4), "")
+	require.False(t, fits)
+	require.Equal(t, 10, over)
+}
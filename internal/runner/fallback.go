@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// FallbackRunner runs with the first of Runners and, when a run fails with an
+// ErrorClassFatal error, such as an authentication failure, runs it again
+// with the next. A run that fails with an error reported in its result event
+// rather than on the error channel is classified by the result's text.
+//
+// Each attempt's events are held back until its outcome is known, so only
+// the events of the attempt that ends the run are forwarded, preceded by a
+// "warning" event for each runner that was passed over. Any other failure
+// ends the run without falling back. If every runner fails fatally, the run
+// ends with the last one's events and error.
+type FallbackRunner struct {
+	Runners []Runner
+}
+
+// NewFallbackRunner returns a FallbackRunner trying runners in order.
+func NewFallbackRunner(runners ...Runner) *FallbackRunner {
+	return &FallbackRunner{Runners: runners}
+}
+
+// Run runs opts with each runner in turn until one does not fail fatally.
+func (f *FallbackRunner) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	if len(f.Runners) == 0 {
+		return failed(errors.New("fallback runner has no runners"))
+	}
+	events := make(chan Event, 64)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		if err := f.run(opts, events); err != nil {
+			errc <- err
+		}
+		close(errc)
+	}()
+
+	return events, errc
+}
+
+func (f *FallbackRunner) run(opts RunOptions, events chan<- Event) error {
+	var warnings, seen []Event
+	var err error
+	for i, r := range f.Runners {
+		seen = nil
+		inner, innerErrc := r.Run(opts)
+		err = Drain(context.Background(), inner, innerErrc, func(e Event) { seen = append(seen, e) })
+
+		outcome := outcomeError(seen, err)
+		if i == len(f.Runners)-1 || ClassifyError(outcome) != ErrorClassFatal {
+			break
+		}
+		msg := fmt.Sprintf("runner %d of %d failed, falling back to the next: %v", i+1, len(f.Runners), outcome)
+		warnings = append(warnings, opts.stamp(Event{Type: "warning", Data: map[string]any{"message": msg}}))
+	}
+	for _, e := range append(warnings, seen...) {
+		events <- e
+	}
+	return err
+}
+
+// outcomeError returns err, or, for a run that reported its failure in an
+// error result event instead, an error carrying the result's text.
+func outcomeError(events []Event, err error) error {
+	if err != nil {
+		return err
+	}
+	if e, ok := lastResult(events); ok && e.IsError() {
+		return fmt.Errorf("agent reported an error: %s", e.ResultText())
+	}
+	return nil
+}
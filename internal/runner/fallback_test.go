@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// outcomeRunner emits events and then err on every run, counting its calls.
+type outcomeRunner struct {
+	events []Event
+	err    error
+	calls  int
+}
+
+func (r *outcomeRunner) Run(RunOptions) (<-chan Event, <-chan error) {
+	r.calls++
+	events := make(chan Event, len(r.events))
+	errc := make(chan error, 1)
+	for _, e := range r.events {
+		events <- e
+	}
+	close(events)
+	if r.err != nil {
+		errc <- r.err
+	}
+	close(errc)
+	return events, errc
+}
+
+func resultEvent(text string, isError bool) Event {
+	return Event{Type: "result", Data: map[string]any{"type": "result", "result": text, "is_error": isError}}
+}
+
+func TestFallbackRunner_FallsBackOnFatalError(t *testing.T) {
+	primary := &outcomeRunner{
+		events: []Event{{Type: "system", Data: map[string]any{"session_id": "p1"}}, resultEvent("Invalid API key · Please run /login", true)},
+	}
+	secondary := &outcomeRunner{events: []Event{resultEvent("plan", false)}}
+
+	events, err := collect(NewFallbackRunner(primary, secondary).Run(RunOptions{}))
+	require.NoError(t, err)
+	require.Equal(t, 1, secondary.calls)
+	require.Len(t, events, 2, "the failed attempt's events are held back")
+	require.Equal(t, "warning", events[0].Type)
+	require.Contains(t, events[0].Data["message"], "runner 1 of 2 failed, falling back to the next: agent reported an error: Invalid API key")
+	require.Equal(t, "plan", events[1].ResultText())
+}
+
+func TestFallbackRunner_AllFailReturnsLastError(t *testing.T) {
+	first := &outcomeRunner{err: &ErrAgentNotFound{Command: "claude", Err: errors.New("not found")}}
+	last := &outcomeRunner{events: []Event{{Type: "system"}}, err: &ErrAgentNotFound{Command: "gemini", Err: errors.New("not found")}}
+
+	events, err := collect(NewFallbackRunner(first, last).Run(RunOptions{}))
+	require.EqualError(t, err, "starting gemini process: not found")
+	require.Len(t, events, 2)
+	require.Equal(t, "warning", events[0].Type)
+	require.Equal(t, "system", events[1].Type)
+}
+
+func TestFallbackRunner_TransientErrorDoesNotFallBack(t *testing.T) {
+	primary := &outcomeRunner{err: &ErrRunFailed{Command: "claude", ExitCode: 1, Err: errors.New("exit status 1")}}
+	secondary := &outcomeRunner{events: []Event{resultEvent("plan", false)}}
+
+	_, err := collect(NewFallbackRunner(primary, secondary).Run(RunOptions{}))
+	var runErr *ErrRunFailed
+	require.ErrorAs(t, err, &runErr)
+	require.Zero(t, secondary.calls)
+}
+
+func TestFallbackRunner_NoRunners(t *testing.T) {
+	_, err := collect(NewFallbackRunner().Run(RunOptions{}))
+	require.EqualError(t, err, "fallback runner has no runners")
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+)
+
+// fingerprintInputs are the RunOptions fields that change what the agent is
+// asked to do. Callbacks, limits, and stream-handling switches are left out.
+type fingerprintInputs struct {
+	System          string   `json:"system"`
+	User            string   `json:"user"`
+	Model           string   `json:"model"`
+	PermissionMode  string   `json:"permission_mode"`
+	AllowedTools    []string `json:"allowed_tools"`
+	DisallowedTools []string `json:"disallowed_tools"`
+	ExtraArgs       []string `json:"extra_args"`
+	SessionID       string   `json:"session_id"`
+	CWD             string   `json:"cwd"`
+}
+
+// Fingerprint returns a hex SHA-256 digest of the semantically significant
+// inputs of opts: prompts, model, permission mode, tool lists, extra args,
+// resumed session, and working directory. Tool lists are order-insensitive.
+// Options that only affect how a run is observed or bounded — callbacks,
+// Context, limits, stderr handling — do not change the fingerprint, so it is
+// suitable as a cache or deduplication key.
+func Fingerprint(opts RunOptions) string {
+	in := fingerprintInputs{
+		System:          opts.Prompts.System,
+		User:            opts.UserPrompt(),
+		Model:           opts.Model,
+		PermissionMode:  opts.PermissionMode,
+		AllowedTools:    sortedCopy(opts.AllowedTools),
+		DisallowedTools: sortedCopy(opts.DisallowedTools),
+		ExtraArgs:       opts.ExtraArgs,
+		SessionID:       opts.SessionID,
+		CWD:             opts.CWD,
+	}
+	// Marshalling a struct of strings cannot fail.
+	b, _ := json.Marshal(in)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func sortedCopy(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	c := slices.Clone(s)
+	slices.Sort(c)
+	return c
+}
@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint_StableAcrossEquivalentOptions(t *testing.T) {
+	base := RunOptions{
+		Prompts:      Prompts{System: "sys", User: "plan this"},
+		Model:        "opus",
+		AllowedTools: []string{"Read", "Grep"},
+	}
+	equivalent := base
+	equivalent.AllowedTools = []string{"Grep", "Read"}
+	equivalent.Context = context.Background()
+	equivalent.OnQuestion = func(Question) {}
+	equivalent.Timeout = time.Minute
+	equivalent.DiscardStderr = true
+	equivalent.IdempotencyKey = "req-1"
+
+	fp := Fingerprint(base)
+	require.Len(t, fp, 64)
+	require.Equal(t, fp, Fingerprint(base))
+	require.Equal(t, fp, Fingerprint(equivalent))
+}
+
+func TestFingerprint_ChangesWithSignificantInputs(t *testing.T) {
+	base := RunOptions{Prompts: Prompts{User: "plan this"}, Model: "opus"}
+	fp := Fingerprint(base)
+
+	for name, mutate := range map[string]func(*RunOptions){
+		"prompt":      func(o *RunOptions) { o.Prompts.User = "plan that" },
+		"system":      func(o *RunOptions) { o.Prompts.System = "be brief" },
+		"model":       func(o *RunOptions) { o.Model = "sonnet" },
+		"tools":       func(o *RunOptions) { o.DisallowedTools = []string{"Bash"} },
+		"permissions": func(o *RunOptions) { o.PermissionMode = "plan" },
+		"extra args":  func(o *RunOptions) { o.ExtraArgs = []string{"--debug"} },
+	} {
+		changed := base
+		mutate(&changed)
+		require.NotEqual(t, fp, Fingerprint(changed), name)
+	}
+}
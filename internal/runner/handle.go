@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"context"
+	"sync"
+)
+
+// RunHandle manages one run started with Start. Read its events from Events,
+// steer it with Inject, stop it early with Cancel, and collect its terminal
+// error with Wait.
+type RunHandle struct {
+	events <-chan Event
+	cancel context.CancelFunc
+	done   chan struct{}
+	input  *inputQueue // nil unless the run is steerable
+
+	mu        sync.Mutex
+	sessionID string
+	err       error
+}
+
+// Start runs r with opts and returns a handle to the run. The run's context
+// is derived from opts.Context, so cancelling either stops it. With
+// opts.Steerable, opts.Input is set to the queue Inject writes to, and closed
+// once the first result arrives so the agent can exit.
+func Start(r Runner, opts RunOptions) *RunHandle {
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	opts.Context = ctx
+	events := make(chan Event, 64)
+	h := &RunHandle{events: events, cancel: cancel, done: make(chan struct{})}
+	if opts.Steerable {
+		h.input = newInputQueue()
+		opts.Input = h.input
+	}
+	inner, errc := r.Run(opts)
+
+	go func() {
+		defer close(h.done)
+		defer cancel()
+		if h.input != nil {
+			defer h.input.close()
+		}
+		for e := range inner {
+			if id := e.SessionID(); id != "" {
+				h.mu.Lock()
+				h.sessionID = id
+				h.mu.Unlock()
+			}
+			if e.IsResult() && h.input != nil {
+				h.input.close()
+			}
+			events <- e
+		}
+		close(events)
+		err := <-errc
+		h.mu.Lock()
+		h.err = err
+		h.mu.Unlock()
+	}()
+	return h
+}
+
+// Events returns the run's event channel, closed when the run finishes.
+func (h *RunHandle) Events() <-chan Event { return h.events }
+
+// Cancel stops the run. The event channel closes once the agent has exited
+// and Wait then reports the cancellation. Cancel is safe to call more than
+// once and after the run has finished.
+func (h *RunHandle) Cancel() { h.cancel() }
+
+// Inject sends text to the agent as a system reminder, a user message framed
+// so the agent reads it as steering rather than a new task, without
+// restarting the run. The agent sees it once it next reads its input,
+// typically after its current turn. Inject never blocks and is safe to call
+// concurrently with reading events. It returns ErrNotSteerable unless the
+// run was started with RunOptions.Steerable, and ErrInputClosed after the
+// first result.
+func (h *RunHandle) Inject(text string) error {
+	if h.input == nil {
+		return ErrNotSteerable
+	}
+	return h.input.write(UserMessage(reminder(text)))
+}
+
+// SessionID returns the most recent session ID seen in the events read so far,
+// or "" if none has arrived yet.
+func (h *RunHandle) SessionID() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sessionID
+}
+
+// Wait discards any events not yet read, blocks until the run finishes, and
+// returns its terminal error. Call it after reading Events, or instead of
+// reading them.
+func (h *RunHandle) Wait() error {
+	for range h.events {
+	}
+	<-h.done
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
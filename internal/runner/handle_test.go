@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart_CancelStopsStream(t *testing.T) {
+	h := Start(shellRunner{}, RunOptions{Prompts: Prompts{User: slowScript}, DiscardStderr: true})
+	first := <-h.Events()
+	require.Equal(t, "slow", first.SessionID())
+	require.Equal(t, "slow", h.SessionID())
+
+	start := time.Now()
+	h.Cancel()
+	for e := range h.Events() {
+		require.False(t, e.IsResult(), "the run should stop before its result")
+	}
+	require.ErrorIs(t, h.Wait(), context.Canceled)
+	require.Less(t, time.Since(start), 900*time.Millisecond)
+	h.Cancel()
+}
+
+func TestStart_WaitReturnsTerminalError(t *testing.T) {
+	h := Start(shellRunner{}, RunOptions{Prompts: Prompts{User: `echo '{"type":"system","session_id":"s1"}'; exit 4`}, DiscardStderr: true})
+
+	var failed *ErrRunFailed
+	require.ErrorAs(t, h.Wait(), &failed, "Wait drains unread events")
+	require.Equal(t, 4, failed.ExitCode)
+	require.Equal(t, "s1", h.SessionID())
+}
+
+func TestStart_WaitAfterCleanRun(t *testing.T) {
+	h := Start(shellRunner{}, RunOptions{Prompts: Prompts{User: `echo '{"type":"result","result":"done"}'`}})
+
+	var results []string
+	for e := range h.Events() {
+		results = append(results, e.ResultText())
+	}
+	require.Equal(t, []string{"done"}, results)
+	require.NoError(t, h.Wait())
+}
+
+// stdinRunner is a shellRunner that attaches RunOptions.Input to the
+// script's stdin.
+type stdinRunner struct{}
+
+func (stdinRunner) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	cmd := fakeProcess(opts.Prompts.User)
+	cmd.Stdin = opts.Input
+	return RunCommand(cmd, opts)
+}
+
+// echoInputScript echoes n input lines, each a user message event, then
+// reports a result.
+func echoInputScript(n int) string {
+	return fmt.Sprintf(`echo '{"type":"system","session_id":"s1"}'; i=0; while [ $i -lt %d ]; do read -r line; printf '%%s\n' "$line"; i=$((i+1)); done; echo '{"type":"result","result":"done"}'`, n)
+}
+
+func TestRunHandle_InjectWritesReminderToInput(t *testing.T) {
+	h := Start(stdinRunner{}, RunOptions{Prompts: Prompts{User: echoInputScript(1)}, Steerable: true})
+	require.Equal(t, "s1", (<-h.Events()).SessionID())
+
+	require.NoError(t, h.Inject("Stay within the spec's scope."))
+	var injected []string
+	for e := range h.Events() {
+		for _, block := range e.contentBlocks() {
+			injected = append(injected, block["text"].(string))
+		}
+	}
+	require.NoError(t, h.Wait())
+	require.Equal(t, []string{"<system-reminder>\nStay within the spec's scope.\n</system-reminder>"}, injected)
+	require.ErrorIs(t, h.Inject("too late"), ErrInputClosed)
+}
+
+func TestRunHandle_InjectConcurrentWithReading(t *testing.T) {
+	const n = 8
+	h := Start(stdinRunner{}, RunOptions{Prompts: Prompts{User: echoInputScript(n)}, Steerable: true})
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, h.Inject(fmt.Sprintf("reminder %d", i)))
+		}()
+	}
+	var users int
+	for e := range h.Events() {
+		if e.Type == "user" {
+			users++
+		}
+	}
+	wg.Wait()
+	require.NoError(t, h.Wait())
+	require.Equal(t, n, users, "every injected message arrives as a whole line")
+}
+
+func TestRunHandle_InjectNeedsSteerableRun(t *testing.T) {
+	h := Start(shellRunner{}, RunOptions{Prompts: Prompts{User: `echo '{"type":"result","result":"done"}'`}})
+	require.ErrorIs(t, h.Inject("hello"), ErrNotSteerable)
+	require.NoError(t, h.Wait())
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+)
+
+// DefaultPreflightTimeout bounds a runner's preflight check, such as running
+// the agent CLI's --version.
+const DefaultPreflightTimeout = 10 * time.Second
+
+// Preflighter is implemented by runners that can check, without starting a
+// run, that they are usable with cfg: that the agent CLI is installed, or
+// that credentials are configured.
+type Preflighter interface {
+	Preflight(cfg config.Config) error
+}
+
+// HealthCheck reports whether cfg describes a usable agent, for readiness
+// checks of a service embedding the runner, e.g. on /healthz. It validates
+// cfg, resolves the runner cfg.Agent names, and runs its preflight check if it
+// has one. It returns nil when healthy.
+func HealthCheck(cfg config.Config) error {
+	if cfg.Agent == "" {
+		return errors.New("health check: no agent configured")
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("health check: invalid config: %w", err)
+	}
+	r, err := NewRunner(cfg.Agent)
+	if err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	if p, ok := r.(Preflighter); ok {
+		if err := p.Preflight(cfg); err != nil {
+			return fmt.Errorf("health check: %s preflight: %w", cfg.Agent, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package runner_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+	"github.com/jumppad-labs/spektacular/internal/runner"
+	"github.com/jumppad-labs/spektacular/internal/runner/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// preflightRunner is a Replay of no events whose preflight check returns err.
+type preflightRunner struct {
+	testutil.Replay
+	err error
+}
+
+func (p *preflightRunner) Preflight(config.Config) error { return p.err }
+
+func registerPreflightRunner(t *testing.T, err error) config.Config {
+	t.Helper()
+	return testutil.RegisterRunner(t, "preflight-runner", func() runner.Runner { return &preflightRunner{err: err} })
+}
+
+func TestHealthCheck_Healthy(t *testing.T) {
+	require.NoError(t, runner.HealthCheck(registerPreflightRunner(t, nil)))
+}
+
+func TestHealthCheck_PreflightFailure(t *testing.T) {
+	missing := &runner.ErrAgentNotFound{Command: "agent", Err: errors.New("not found")}
+	err := runner.HealthCheck(registerPreflightRunner(t, missing))
+
+	var notFound *runner.ErrAgentNotFound
+	require.ErrorAs(t, err, &notFound)
+	require.ErrorContains(t, err, "preflight-runner preflight")
+}
+
+func TestHealthCheck_ConfigProblems(t *testing.T) {
+	cfg := config.NewDefault()
+	require.EqualError(t, runner.HealthCheck(cfg), "health check: no agent configured")
+
+	cfg.Agent = "unknown-agent"
+	require.ErrorContains(t, runner.HealthCheck(cfg), "unsupported runner")
+
+	cfg = registerPreflightRunner(t, nil)
+	cfg.Run.MaxTurns = -1
+	require.ErrorContains(t, runner.HealthCheck(cfg), "invalid config: run.max_turns must not be negative")
+}
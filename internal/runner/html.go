@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// transcriptTemplate renders a run as a standalone HTML page. html/template
+// escapes every interpolated value, so agent and tool output cannot inject
+// markup or script.
+var transcriptTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Spektacular transcript</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.assistant { white-space: pre-wrap; line-height: 1.5; margin: 1rem 0; }
+details.tool { background: #f4f4f6; border-radius: 4px; margin: 0.5rem 0; padding: 0.4rem 0.8rem; }
+details.tool.error { background: #fbeaea; }
+details.tool pre { white-space: pre-wrap; overflow-x: auto; }
+.result { border-top: 2px solid #ccc; margin-top: 2rem; padding-top: 1rem; }
+.result.error h2 { color: #b00; }
+.result dl { display: grid; grid-template-columns: max-content auto; gap: 0.2rem 1rem; }
+</style>
+</head>
+<body>
+<h1>Transcript</h1>
+{{- range .Entries}}
+{{- if eq .Kind "text"}}
+<div class="assistant">{{.Text}}</div>
+{{- else if eq .Kind "tool_use"}}
+<details class="tool"><summary>Tool call: {{.Title}}</summary><pre>{{.Text}}</pre></details>
+{{- else}}
+<details class="tool{{if .IsError}} error{{end}}"><summary>{{.Title}}</summary><pre>{{.Text}}</pre></details>
+{{- end}}
+{{- end}}
+{{- with .Result}}
+<section class="result{{if .IsError}} error{{end}}">
+<h2>{{if .IsError}}Run failed{{else}}Result{{end}}</h2>
+<div class="assistant">{{.Text}}</div>
+<dl>
+<dt>Cost</dt><dd>{{.Cost}}</dd>
+<dt>Input tokens</dt><dd>{{.Usage.InputTokens}}</dd>
+<dt>Output tokens</dt><dd>{{.Usage.OutputTokens}}</dd>
+<dt>Cache tokens</dt><dd>{{.Usage.CacheCreationInputTokens}} written, {{.Usage.CacheReadInputTokens}} read</dd>
+</dl>
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+type transcriptEntry struct {
+	Kind    string // "text", "tool_use" or "tool_result"
+	Title   string
+	Text    string
+	IsError bool
+}
+
+type transcriptResult struct {
+	Text    string
+	IsError bool
+	Cost    string
+	Usage   Usage
+}
+
+// omittedToolInput stands in for a tool call's input when
+// TranscriptOptions.OmitToolInput is set.
+const omittedToolInput = "(input omitted)"
+
+// TranscriptOptions controls how RenderHTMLWithOptions renders a transcript.
+type TranscriptOptions struct {
+	// OmitToolInput keeps each tool call's name but leaves out its input,
+	// which can carry whole file contents or secrets.
+	OmitToolInput bool
+}
+
+// RenderHTML writes events as a self-contained HTML document: assistant text
+// in order, each tool call and tool result as a collapsible section, and a
+// summary of the final result with its cost and token usage.
+func RenderHTML(events []Event, w io.Writer) error {
+	return RenderHTMLWithOptions(events, w, TranscriptOptions{})
+}
+
+// RenderHTMLWithOptions is RenderHTML with rendering controlled by opts.
+func RenderHTMLWithOptions(events []Event, w io.Writer, opts TranscriptOptions) error {
+	var data struct {
+		Entries []transcriptEntry
+		Result  *transcriptResult
+	}
+	for _, e := range events {
+		switch e.Type {
+		case "assistant":
+			for _, block := range e.contentBlocks() {
+				switch block["type"] {
+				case "text":
+					if t, _ := block["text"].(string); t != "" {
+						data.Entries = append(data.Entries, transcriptEntry{Kind: "text", Text: StripMarkers(t)})
+					}
+				case "tool_use":
+					name, _ := block["name"].(string)
+					text := omittedToolInput
+					if !opts.OmitToolInput {
+						input, _ := json.MarshalIndent(block["input"], "", "  ")
+						text = string(input)
+					}
+					data.Entries = append(data.Entries, transcriptEntry{Kind: "tool_use", Title: name, Text: text})
+				}
+			}
+		case "user":
+			for _, r := range e.toolResults() {
+				isErr, _ := r["is_error"].(bool)
+				title := "Tool result"
+				if isErr {
+					title = "Tool error"
+				}
+				data.Entries = append(data.Entries, transcriptEntry{Kind: "tool_result", Title: title, Text: toolResultText(r), IsError: isErr})
+			}
+		case "result":
+			data.Result = &transcriptResult{
+				Text:    e.ResultText(),
+				IsError: e.IsError(),
+				Cost:    fmt.Sprintf("$%.4f", e.CostUSD()),
+			}
+		}
+	}
+	if data.Result != nil {
+		data.Result.Usage = SummarizeUsage(events).Usage
+	}
+	if err := transcriptTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("rendering transcript: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderHTML_Structure(t *testing.T) {
+	events := []Event{
+		{Type: "system", Data: map[string]any{"subtype": "init"}},
+		assistantBlocks(
+			map[string]any{"type": "text", "text": "Reading the spec."},
+			toolUse("1", "Read", map[string]any{"file_path": "spec.md"}),
+		),
+		toolResult("1", true, "no such file"),
+		assistantWithUsage("claude-sonnet", 1200, 340, 0, 0),
+		{Type: "result", Data: map[string]any{"result": "The plan.", "total_cost_usd": 0.0123}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderHTML(events, &buf))
+	out := buf.String()
+
+	require.Contains(t, out, "<!DOCTYPE html>")
+	require.Contains(t, out, `<div class="assistant">Reading the spec.</div>`)
+	require.Contains(t, out, "<summary>Tool call: Read</summary>")
+	require.Contains(t, out, "&#34;file_path&#34;: &#34;spec.md&#34;")
+	require.Contains(t, out, `<details class="tool error"><summary>Tool error</summary><pre>no such file</pre>`)
+	require.Contains(t, out, "<h2>Result</h2>")
+	require.Contains(t, out, "<dd>$0.0123</dd>")
+	require.Contains(t, out, "<dd>1200</dd>")
+	require.Contains(t, out, "<dd>340</dd>")
+}
+
+func TestRenderHTML_EscapesAgentContent(t *testing.T) {
+	events := []Event{
+		assistantBlocks(map[string]any{"type": "text", "text": "<script>alert(1)</script>"}),
+		{Type: "result", Data: map[string]any{"result": `<img src=x onerror="alert(2)">`, "is_error": true}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderHTML(events, &buf))
+	out := buf.String()
+
+	require.NotContains(t, out, "<script>")
+	require.Contains(t, out, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	require.NotContains(t, out, "<img")
+	require.Contains(t, out, "<h2>Run failed</h2>")
+}
+
+func TestRenderHTML_NoResultOmitsSummary(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, RenderHTML(nil, &buf))
+	require.NotContains(t, buf.String(), `class="result`)
+}
+
+func TestRenderHTMLWithOptions_OmitToolInput(t *testing.T) {
+	events := []Event{
+		assistantBlocks(
+			toolUse("1", "Write", map[string]any{"file_path": "creds.env", "content": "API_TOKEN=secret"}),
+			toolUse("2", "Bash", map[string]any{"command": "cat creds.env"}),
+		),
+		toolResult("1", false, "written"),
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderHTMLWithOptions(events, &buf, TranscriptOptions{OmitToolInput: true}))
+	out := buf.String()
+
+	require.Contains(t, out, "<summary>Tool call: Write</summary><pre>"+omittedToolInput+"</pre>")
+	require.Contains(t, out, "<summary>Tool call: Bash</summary>")
+	require.Contains(t, out, "<pre>written</pre>", "tool results are still shown")
+	require.NotContains(t, out, "secret")
+	require.NotContains(t, out, "cat creds.env")
+}
@@ -0,0 +1,25 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// IdempotencyKeyEnv is the environment variable through which CLI runners
+// expose the idempotency key to the agent process and any proxy in front of
+// the model API.
+const IdempotencyKeyEnv = "SPEKTACULAR_IDEMPOTENCY_KEY"
+
+// ResolveIdempotencyKey returns o.IdempotencyKey, or when unset a SHA-256 of
+// the system and user prompts. The derived key depends only on the prompts,
+// so every retry of the same request carries the same key.
+func (o RunOptions) ResolveIdempotencyKey() string {
+	if o.IdempotencyKey != "" {
+		return o.IdempotencyKey
+	}
+	h := sha256.New()
+	h.Write([]byte(o.Prompts.System))
+	h.Write([]byte{0})
+	h.Write([]byte(o.UserPrompt()))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,33 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveIdempotencyKey_DerivedFromPrompts(t *testing.T) {
+	opts := RunOptions{Prompts: Prompts{System: "sys", User: "plan this"}}
+	key := opts.ResolveIdempotencyKey()
+	require.Len(t, key, 64)
+
+	retry := opts
+	retry.SessionID = "s1"
+	retry.Retries = 2
+	require.Equal(t, key, retry.ResolveIdempotencyKey(), "retries of the same prompt share a key")
+
+	other := RunOptions{Prompts: Prompts{System: "sys", User: "plan that"}}
+	require.NotEqual(t, key, other.ResolveIdempotencyKey())
+
+	prebuilt := RunOptions{Prompt: "plan this", Prompts: Prompts{System: "sys", User: "rebuilt"}}
+	require.Equal(t, key, prebuilt.ResolveIdempotencyKey(), "the key follows the prompt the agent is sent")
+
+	// The separator keeps the system/user boundary significant.
+	shifted := RunOptions{Prompts: Prompts{System: "sysplan", User: " this"}}
+	require.NotEqual(t, key, shifted.ResolveIdempotencyKey())
+}
+
+func TestResolveIdempotencyKey_ExplicitKeyWins(t *testing.T) {
+	opts := RunOptions{IdempotencyKey: "req-42", Prompts: Prompts{User: "plan this"}}
+	require.Equal(t, "req-42", opts.ResolveIdempotencyKey())
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrNotSteerable is returned by RunHandle.Inject for a run not started
+	// with RunOptions.Steerable.
+	ErrNotSteerable = errors.New("run is not steerable")
+	// ErrInputClosed is returned by RunHandle.Inject once the run's input has
+	// closed: after its first result or once it has ended.
+	ErrInputClosed = errors.New("run input is closed")
+)
+
+// UserMessage returns text as a stream-json user message line, the framing
+// RunOptions.Input carries.
+func UserMessage(text string) []byte {
+	msg := map[string]any{
+		"type": "user",
+		"message": map[string]any{
+			"role":    "user",
+			"content": []any{map[string]any{"type": "text", "text": text}},
+		},
+	}
+	// Marshalling maps of strings cannot fail.
+	line, _ := json.Marshal(msg)
+	return append(line, '\n')
+}
+
+// reminder wraps text so the agent reads it as steering from the harness
+// rather than a new task.
+func reminder(text string) string {
+	return "<system-reminder>\n" + text + "\n</system-reminder>"
+}
+
+// inputQueue is an unbounded in-memory pipe: writes never block, and reads
+// block until data is written or the queue is closed.
+type inputQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte
+	closed bool
+}
+
+func newInputQueue() *inputQueue {
+	q := &inputQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Read implements io.Reader, returning io.EOF once the queue is closed and
+// drained.
+func (q *inputQueue) Read(p []byte) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.buf) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, q.buf)
+	q.buf = q.buf[n:]
+	return n, nil
+}
+
+// write queues b whole, or returns ErrInputClosed.
+func (q *inputQueue) write(b []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrInputClosed
+	}
+	q.buf = append(q.buf, b...)
+	q.cond.Broadcast()
+	return nil
+}
+
+// close ends the queue; data already written is still read.
+func (q *inputQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
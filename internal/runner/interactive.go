@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAnswerTimeout is returned by Prompter when no answer arrives within its
+// Timeout and the question has no default to fall back on.
+var ErrAnswerTimeout = errors.New("timed out waiting for an answer")
+
+// Prompter asks detected questions on a line-oriented stream, such as a
+// terminal, and reads one line per answer. Choice questions are listed as
+// numbered options and accept either the number or the label; an empty line
+// selects the question's Default.
+type Prompter struct {
+	In  io.Reader
+	Out io.Writer
+	// Timeout bounds the wait for each answer. When it elapses the question's
+	// Default is used, or ErrAnswerTimeout is returned if it has none. Zero
+	// waits forever.
+	Timeout time.Duration
+
+	clock clock // nil uses the real clock
+
+	once  sync.Once
+	lines chan string
+}
+
+// NewPrompter returns a Prompter reading answers from in and writing
+// questions to out.
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{In: in, Out: out}
+}
+
+// Answer asks every question in qs and returns the answers formatted with
+// FormatAnswers, ready to resume the agent session.
+func (p *Prompter) Answer(qs []Question) (string, error) {
+	answers := make(map[string]string, len(qs))
+	for _, q := range qs {
+		a, err := p.Ask(q)
+		if err != nil {
+			return "", err
+		}
+		answers[q.Header] = a
+	}
+	return FormatAnswers(qs, answers), nil
+}
+
+// Ask prints q and returns the answer read from In. A typed answer that
+// fails q.CheckAnswer is rejected with the reason and the question is asked
+// again; the Default used on a timeout is taken as is.
+func (p *Prompter) Ask(q Question) (string, error) {
+	for {
+		a, timedOut, err := p.ask(q)
+		if err != nil || timedOut {
+			return a, err
+		}
+		if err := q.CheckAnswer(a); err != nil {
+			fmt.Fprintf(p.Out, "%v\n", err)
+			continue
+		}
+		return a, nil
+	}
+}
+
+// ask prints q once and returns the answer read from In, unchecked, and
+// whether it is the Default because the wait timed out.
+func (p *Prompter) ask(q Question) (string, bool, error) {
+	labels := q.OptionLabels()
+	fmt.Fprintf(p.Out, "\n%s\n", q.Question)
+	if q.Type == QuestionTypeChoice {
+		for i, label := range labels {
+			fmt.Fprintf(p.Out, "  %d. %s\n", i+1, label)
+		}
+	}
+	if q.Default != "" {
+		fmt.Fprintf(p.Out, "[default: %s] ", q.Default)
+	}
+	fmt.Fprint(p.Out, "> ")
+
+	line, err := p.readLine()
+	switch {
+	case errors.Is(err, ErrAnswerTimeout) && q.Default != "":
+		fmt.Fprintf(p.Out, "\nno answer, using default %q\n", q.Default)
+		return q.Default, true, nil
+	case err != nil:
+		return "", false, fmt.Errorf("question %q: %w", q.Header, err)
+	}
+
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return q.Default, false, nil
+	}
+	if q.Type == QuestionTypeChoice {
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(labels) {
+			return labels[n-1], false, nil
+		}
+	}
+	return line, false, nil
+}
+
+// readLine returns the next input line, waiting at most Timeout. Lines are
+// read by a single background goroutine, so a line typed after a timeout is
+// delivered to the next question rather than lost.
+func (p *Prompter) readLine() (string, error) {
+	p.once.Do(func() {
+		p.lines = make(chan string)
+		go func() {
+			defer close(p.lines)
+			scanner := bufio.NewScanner(p.In)
+			for scanner.Scan() {
+				p.lines <- scanner.Text()
+			}
+		}()
+	})
+
+	var timeout <-chan time.Time
+	if p.Timeout > 0 {
+		timeout = clockOrReal(p.clock).After(p.Timeout)
+	}
+
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		return line, nil
+	case <-timeout:
+		return "", ErrAnswerTimeout
+	}
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrompter_Ask_ChoiceByNumberAndLabel(t *testing.T) {
+	var out strings.Builder
+	p := NewPrompter(strings.NewReader("2\nC\n\n"), &out)
+	q := choiceQuestion("A", "A", "B")
+
+	a, err := p.Ask(q)
+	require.NoError(t, err)
+	require.Equal(t, "B", a)
+	require.Contains(t, out.String(), "  2. B")
+
+	a, err = p.Ask(q)
+	require.NoError(t, err)
+	require.Equal(t, "C", a, "free text is accepted as an Other answer")
+
+	a, err = p.Ask(q)
+	require.NoError(t, err)
+	require.Equal(t, "A", a, "empty line selects the default")
+}
+
+func TestPrompter_Ask_RepromptsOnPatternMismatch(t *testing.T) {
+	var out strings.Builder
+	p := NewPrompter(strings.NewReader("main\n\nfeature/auth\n"), &out)
+
+	a, err := p.Ask(branchQuestion())
+	require.NoError(t, err)
+	require.Equal(t, "feature/auth", a)
+	require.Equal(t, 3, strings.Count(out.String(), "Which branch?"), "asked until the answer matches")
+	require.Contains(t, out.String(), `answer "main" does not match the required pattern feature/[a-z-]+`)
+}
+
+func TestPrompter_Ask_PatternlessTextAcceptsAnything(t *testing.T) {
+	p := NewPrompter(strings.NewReader("Anything, really!\n"), io.Discard)
+	a, err := p.Ask(Question{Question: "Notes?", Header: "Notes", Type: QuestionTypeText})
+	require.NoError(t, err)
+	require.Equal(t, "Anything, really!", a)
+}
+
+func TestPrompter_Ask_TimeoutAppliesDefault(t *testing.T) {
+	in, _ := io.Pipe() // blocks forever
+	clock := newFakeClock()
+	p := NewPrompter(in, io.Discard)
+	p.Timeout = 30 * time.Second
+	p.clock = clock
+
+	type answer struct {
+		text string
+		err  error
+	}
+	done := make(chan answer, 1)
+	go func() {
+		a, err := p.Ask(choiceQuestion("B", "A", "B"))
+		done <- answer{a, err}
+	}()
+
+	clock.BlockUntil(t, 1)
+	clock.Advance(p.Timeout - time.Nanosecond)
+	require.Empty(t, done, "the timeout has not elapsed yet")
+	clock.Advance(time.Nanosecond)
+	got := <-done
+	require.NoError(t, got.err)
+	require.Equal(t, "B", got.text)
+}
+
+func TestPrompter_Ask_TimeoutWithoutDefaultErrors(t *testing.T) {
+	in, _ := io.Pipe()
+	clock := newFakeClock()
+	p := NewPrompter(in, io.Discard)
+	p.Timeout = time.Minute
+	p.clock = clock
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := p.Ask(Question{Question: "Branch name?", Header: "Branch"})
+		errc <- err
+	}()
+	clock.BlockUntil(t, 1)
+	clock.Advance(time.Minute)
+	err := <-errc
+	require.True(t, errors.Is(err, ErrAnswerTimeout), "got %v", err)
+
+	go func() {
+		_, err := p.Answer([]Question{{Question: "Branch name?", Header: "Branch"}})
+		errc <- err
+	}()
+	clock.BlockUntil(t, 1)
+	clock.Advance(time.Minute)
+	require.ErrorIs(t, <-errc, ErrAnswerTimeout)
+}
+
+func TestPrompter_Answer_FormatsAllAnswers(t *testing.T) {
+	p := NewPrompter(strings.NewReader("1\nfeature/x\n"), io.Discard)
+
+	got, err := p.Answer([]Question{choiceQuestion("", "A", "B"), {Question: "Branch?", Header: "Branch"}})
+	require.NoError(t, err)
+	require.Contains(t, got, "- Approach (Which approach?): A")
+	require.Contains(t, got, "- Branch (Branch?): feature/x")
+}
+
+func TestPrompter_Ask_EOF(t *testing.T) {
+	_, err := NewPrompter(strings.NewReader(""), io.Discard).Ask(Question{Question: "Q?", Header: "H"})
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,23 @@
+package runner
+
+import "fmt"
+
+// ResourceLimitError is returned when the agent process tree exceeds a
+// resource limit set on RunOptions and is terminated.
+type ResourceLimitError struct {
+	Resource string  // "memory" or "cpu"
+	Limit    float64 // configured cap: bytes for memory, percent for cpu
+	Used     float64 // observed usage when the run was killed
+}
+
+func (e *ResourceLimitError) Error() string {
+	if e.Resource == "memory" {
+		return fmt.Sprintf("agent exceeded memory limit: using %.0f bytes, limit %.0f bytes", e.Used, e.Limit)
+	}
+	return fmt.Sprintf("agent exceeded %s limit: using %.1f%%, limit %.1f%%", e.Resource, e.Used, e.Limit)
+}
+
+// hasLimits reports whether opts sets any resource limit.
+func hasLimits(opts RunOptions) bool {
+	return opts.MaxMemoryBytes > 0 || opts.MaxCPUPercent > 0
+}
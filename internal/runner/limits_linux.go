@@ -0,0 +1,119 @@
+//go:build linux
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MaxMemoryBytes and MaxCPUPercent are enforced by polling rather than by the
+// kernel: watchLimits samples the agent's process group from /proc every
+// limitPollInterval and kills the group once a sample is over a limit. The
+// process tree can overshoot a limit until the next sample, and a child that
+// moves itself into another process group or session escapes measurement.
+
+// limitPollInterval is how often the process tree is sampled.
+var limitPollInterval = 100 * time.Millisecond
+
+// cpuWindow is the span over which CPU usage is averaged before comparing it
+// with MaxCPUPercent, so short bursts are tolerated.
+const cpuWindow = time.Second
+
+// clockTicks is USER_HZ, the unit of utime/stime in /proc/<pid>/stat. It is
+// 100 on every mainstream Linux build.
+const clockTicks = 100
+
+// prepareLimits places the agent in its own process group so the whole tree,
+// including tool subprocesses, can be measured and killed together.
+func prepareLimits(cmd *exec.Cmd, opts RunOptions) error {
+	if !hasLimits(opts) {
+		return nil
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	return nil
+}
+
+// terminate kills the agent, and its whole process group when limits placed
+// it in one.
+func terminate(cmd *exec.Cmd) {
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return
+	}
+	cmd.Process.Kill()
+}
+
+// watchLimits samples the process group led by pid until done is closed. If a
+// limit is exceeded it sends a *ResourceLimitError and then kills the group.
+// The returned channel is closed when watching stops.
+func watchLimits(pid int, opts RunOptions, done <-chan struct{}) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		ticker := time.NewTicker(limitPollInterval)
+		defer ticker.Stop()
+
+		windowStart := time.Now()
+		windowTicks, _ := groupUsage(pid)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			ticks, rss := groupUsage(pid)
+			if opts.MaxMemoryBytes > 0 && rss > opts.MaxMemoryBytes {
+				errc <- &ResourceLimitError{Resource: "memory", Limit: float64(opts.MaxMemoryBytes), Used: float64(rss)}
+				syscall.Kill(-pid, syscall.SIGKILL)
+				return
+			}
+			if elapsed := time.Since(windowStart); opts.MaxCPUPercent > 0 && elapsed >= cpuWindow {
+				percent := float64(ticks-windowTicks) / clockTicks / elapsed.Seconds() * 100
+				if percent > opts.MaxCPUPercent {
+					errc <- &ResourceLimitError{Resource: "cpu", Limit: opts.MaxCPUPercent, Used: percent}
+					syscall.Kill(-pid, syscall.SIGKILL)
+					return
+				}
+				windowStart, windowTicks = time.Now(), ticks
+			}
+		}
+	}()
+	return errc
+}
+
+// groupUsage sums CPU ticks and resident bytes over every process in the
+// process group pgid.
+func groupUsage(pgid int) (ticks int64, rss int64) {
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	page := int64(os.Getpagesize())
+	for _, path := range stats {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			continue // process exited between glob and read
+		}
+		// The command name may contain spaces; fields are counted after it.
+		s := string(raw)
+		fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+		if len(fields) < 22 {
+			continue
+		}
+		if g, _ := strconv.Atoi(fields[2]); g != pgid {
+			continue
+		}
+		utime, _ := strconv.ParseInt(fields[11], 10, 64)
+		stime, _ := strconv.ParseInt(fields[12], 10, 64)
+		pages, _ := strconv.ParseInt(fields[21], 10, 64)
+		ticks += utime + stime
+		rss += pages * page
+	}
+	return ticks, rss
+}
@@ -0,0 +1,81 @@
+//go:build linux
+
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestHelperMemoryHog is not a real test: when re-executed with
+// SPEKTACULAR_MEMORY_HOG set it allocates and touches memory until killed.
+func TestHelperMemoryHog(t *testing.T) {
+	if os.Getenv("SPEKTACULAR_MEMORY_HOG") != "1" {
+		t.Skip("helper process")
+	}
+	var hoard [][]byte
+	for i := 0; i < 1024; i++ {
+		chunk := make([]byte, 1<<20)
+		for j := range chunk {
+			chunk[j] = byte(j)
+		}
+		hoard = append(hoard, chunk)
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Second)
+	_ = hoard
+}
+
+// TestHelperCPUHog is not a real test: when re-executed with
+// SPEKTACULAR_CPU_HOG set it spins on one core until killed.
+func TestHelperCPUHog(t *testing.T) {
+	if os.Getenv("SPEKTACULAR_CPU_HOG") != "1" {
+		t.Skip("helper process")
+	}
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+	}
+}
+
+func TestRunCommand_KillsProcessOverCPULimit(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperCPUHog$")
+	cmd.Env = append(os.Environ(), "SPEKTACULAR_CPU_HOG=1")
+
+	start := time.Now()
+	_, err := collect(RunCommand(cmd, RunOptions{MaxCPUPercent: 20}))
+	require.Less(t, time.Since(start), 8*time.Second, "hog should be killed before it finishes")
+
+	var limitErr *ResourceLimitError
+	require.True(t, errors.As(err, &limitErr), "expected *ResourceLimitError, got %v", err)
+	require.Equal(t, "cpu", limitErr.Resource)
+	require.Greater(t, limitErr.Used, limitErr.Limit)
+	require.Contains(t, err.Error(), "exceeded cpu limit")
+}
+
+func TestRunCommand_KillsProcessOverMemoryLimit(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperMemoryHog$")
+	cmd.Env = append(os.Environ(), "SPEKTACULAR_MEMORY_HOG=1")
+
+	start := time.Now()
+	_, err := collect(RunCommand(cmd, RunOptions{MaxMemoryBytes: 64 << 20}))
+	require.Less(t, time.Since(start), 8*time.Second, "hog should be killed before it finishes")
+
+	var limitErr *ResourceLimitError
+	require.True(t, errors.As(err, &limitErr), "expected *ResourceLimitError, got %v", err)
+	require.Equal(t, "memory", limitErr.Resource)
+	require.Greater(t, limitErr.Used, limitErr.Limit)
+	require.Contains(t, err.Error(), "exceeded memory limit")
+}
+
+func TestRunCommand_NoLimitsLeavesProcessAlone(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"result","result":"ok"}'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{}))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Nil(t, cmd.SysProcAttr)
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package runner
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// prepareLimits rejects resource limits, which are only enforced on Linux.
+func prepareLimits(_ *exec.Cmd, opts RunOptions) error {
+	if hasLimits(opts) {
+		return fmt.Errorf("resource limits are not supported on %s", runtime.GOOS)
+	}
+	return nil
+}
+
+// terminate kills the agent process.
+func terminate(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// watchLimits is never reached off Linux because prepareLimits fails first.
+func watchLimits(_ int, _ RunOptions, _ <-chan struct{}) <-chan error {
+	errc := make(chan error)
+	close(errc)
+	return errc
+}
@@ -0,0 +1,91 @@
+package runner
+
+import "strings"
+
+// DefaultLocale is the prompt locale used when none is set or the requested
+// one has no translation table.
+const DefaultLocale = "en"
+
+// promptStrings holds the fixed text of a user prompt in one language. User
+// content, such as the spec, hints and footer, is never translated.
+type promptStrings struct {
+	withHeader       string // like PromptWithHeader; args: header, content
+	knowledgeHint    string // the hint opening withHeader
+	extraSources     string // introduces PromptOptions.KnowledgeHints
+	knowledgeOmitted string // replaces hints dropped to fit MaxTokens
+	truncated        string // ends truncated content; args: characters removed
+	footer           string // like PromptFooter; args: footer
+	headers          map[string]string
+}
+
+// promptLocales are the translation tables for non-English prompts, keyed by
+// lower-case language tag.
+var promptLocales = map[string]promptStrings{
+	"de": {
+		knowledgeHint:    "Zusätzliches Projektwissen, Architekturkontext und frühere Erkenntnisse finden Sie in '" + DefaultKnowledgeDir + "'. Nutzen Sie Ihre verfügbaren Werkzeuge, um dieses Verzeichnis bei Bedarf zu erkunden.",
+		extraSources:     "Ziehen Sie außerdem diese Wissensquellen heran:",
+		knowledgeOmitted: "(Weitere Wissensquellen wurden weggelassen, um die Größenbeschränkung des Prompts einzuhalten.)",
+		truncated:        "\n\n[... gekürzt: %d Zeichen ausgelassen, um die Größenbeschränkung des Prompts einzuhalten ...]",
+		footer: `
+
+---
+
+# Erforderlicher Abschluss
+
+Beenden Sie Ihre Ausgabe mit dem Inhalt zwischen den <required-footer>-Tags, exakt und vollständig wiedergegeben.
+
+<required-footer>
+%s
+</required-footer>`,
+		headers: map[string]string{"Specification to Plan": "Zu planende Spezifikation"},
+	},
+	"es": {
+		knowledgeHint:    "Encontrará conocimiento adicional del proyecto, contexto arquitectónico y aprendizajes previos en '" + DefaultKnowledgeDir + "'. Utilice las herramientas disponibles para explorar este directorio según sea necesario.",
+		extraSources:     "Consulte también estas fuentes de conocimiento:",
+		knowledgeOmitted: "(Se omitieron fuentes de conocimiento adicionales para respetar el límite de tamaño del prompt.)",
+		truncated:        "\n\n[... truncado: se omitieron %d caracteres para respetar el límite de tamaño del prompt ...]",
+		footer: `
+
+---
+
+# Pie obligatorio
+
+Termine su respuesta con el contenido entre las etiquetas <required-footer>, reproducido exactamente y por completo.
+
+<required-footer>
+%s
+</required-footer>`,
+		headers: map[string]string{"Specification to Plan": "Especificación a planificar"},
+	},
+}
+
+// localeStrings returns the prompt text for locale, matching a region-qualified
+// tag such as "de-AT" or "de_AT" by its language, and falling back to English.
+// English reads the package-level templates, so overriding them still applies.
+func localeStrings(locale string) promptStrings {
+	tag := strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	lang, _, _ := strings.Cut(tag, "-")
+	for _, key := range []string{tag, lang} {
+		if s, ok := promptLocales[key]; ok {
+			s.withHeader = s.knowledgeHint + "\n\n---\n\n# %s\n\n%s"
+			return s
+		}
+	}
+	return promptStrings{
+		withHeader:       PromptWithHeader,
+		knowledgeHint:    knowledgeHint,
+		extraSources:     "Also consult these knowledge sources:",
+		knowledgeOmitted: knowledgeOmittedMarker,
+		truncated:        contentTruncatedMarker,
+		footer:           PromptFooter,
+	}
+}
+
+// header translates header if it is one of the fixed labels the prompt
+// builders use; any other header is the caller's and is kept as given.
+func (s promptStrings) header(header string) string {
+	if h, ok := s.headers[header]; ok {
+		return h
+	}
+	return header
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// markerPattern matches a <!--KIND:payload--> protocol marker, capturing the
+// upper-case kind and the payload, which may span lines.
+var markerPattern = regexp.MustCompile(`<!--([A-Z][A-Z_]*):([\s\S]*?)-->`)
+
+// DetectMarkers scans text for <!--KIND:{...}--> markers and returns their raw
+// JSON payloads grouped by kind, each group in document order. Only the given
+// kinds are collected, or every kind when none are given. Payloads that are
+// not valid JSON are skipped, so one malformed marker does not hide the rest.
+// A marker missing its closing --> is skipped at the next <!--, so it never
+// swallows the marker that follows it.
+func DetectMarkers(text string, kinds ...string) map[string][]json.RawMessage {
+	found := map[string][]json.RawMessage{}
+	for pos := 0; pos < len(text); {
+		rest := text[pos:]
+		m := markerPattern.FindStringSubmatchIndex(rest)
+		if m == nil {
+			break
+		}
+		kind, body := rest[m[2]:m[3]], rest[m[4]:m[5]]
+		if i := strings.Index(body, "<!--"); i >= 0 {
+			pos += m[4] + i
+			continue
+		}
+		pos += m[1]
+		payload := strings.TrimSpace(body)
+		if len(kinds) > 0 && !slices.Contains(kinds, kind) {
+			continue
+		}
+		if !json.Valid([]byte(payload)) {
+			continue
+		}
+		found[kind] = append(found[kind], json.RawMessage(payload))
+	}
+	return found
+}
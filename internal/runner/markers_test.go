@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectMarkers_GroupsMixedKinds(t *testing.T) {
+	text := "Working.\n" +
+		`<!--STATUS:{"phase":"analysis"}-->` + "\n" +
+		`<!--ACTION:{"name":"open_pr","draft":true}-->` + "\n" +
+		`<!--QUESTION:{"questions":[{"question":"Q?","header":"H"}]}-->` + "\n" +
+		"<!--STATUS:not json-->\n" +
+		"<!--ACTION:\n  {\"name\": \"notify\"}\n-->\n" +
+		"<!-- GOTO: verification -->\n<!--FINISHED-->\n" +
+		`<!--STATUS:{"phase":"done"}-->`
+
+	all := DetectMarkers(text)
+	require.Equal(t, []json.RawMessage{json.RawMessage(`{"phase":"analysis"}`), json.RawMessage(`{"phase":"done"}`)}, all["STATUS"])
+	require.Equal(t, []json.RawMessage{json.RawMessage(`{"name":"open_pr","draft":true}`), json.RawMessage(`{"name": "notify"}`)}, all["ACTION"])
+	require.Len(t, all["QUESTION"], 1)
+	require.Len(t, all, 3, "FINISHED and GOTO carry no JSON payload")
+
+	only := DetectMarkers(text, "ACTION", "UNKNOWN")
+	require.Len(t, only, 1)
+	require.Len(t, only["ACTION"], 2)
+	require.Empty(t, DetectMarkers("no markers"))
+}
+
+func TestDetectMarkers_UnclosedMarkerDoesNotSwallowNext(t *testing.T) {
+	text := `<!--STATUS:{"phase":"analysis"}` + "\nStill thinking.\n" +
+		`<!--QUESTION:{"questions":[{"question":"Which database?","header":"DB"}]}-->` + "\n" +
+		`<!--ACTION:{"name":"open_pr"}-->`
+
+	all := DetectMarkers(text)
+	require.Len(t, all["QUESTION"], 1)
+	require.Len(t, all["ACTION"], 1)
+	require.Empty(t, all["STATUS"], "the unclosed marker is skipped")
+
+	qs := detectQuestions(text)
+	require.Len(t, qs, 1)
+	require.Equal(t, "DB", qs[0].Header)
+}
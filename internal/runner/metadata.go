@@ -0,0 +1,54 @@
+package runner
+
+// errNoResult is RunMeta.Error for a run that never produced a result event.
+const errNoResult = "run produced no result"
+
+// RunMeta is the metadata of a completed run, for persisting alongside its
+// output.
+type RunMeta struct {
+	SessionID    string   `json:"session_id,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	NumTurns     int      `json:"num_turns"`
+	DurationMS   int      `json:"duration_ms"`
+	Usage        Usage    `json:"usage"`
+	CostUSD      float64  `json:"cost_usd"`
+	ToolsUsed    []string `json:"tools_used,omitempty"`
+	FilesTouched []string `json:"files_touched,omitempty"`
+	Error        string   `json:"error,omitempty"` // the error result's text, if the run failed
+}
+
+// RunMetadata collects the metadata of a run from its events. The session and
+// model are the last ones reported; turns and duration come from the final
+// result event, usage and cost as SummarizeUsage computes them, and the tool
+// and file lists as ToolNamesUsed and FilesTouched do. Error is the text of an
+// error result, or a fixed message when the run produced no result at all.
+func RunMetadata(events []Event) RunMeta {
+	usage := SummarizeUsage(events)
+	meta := RunMeta{
+		Usage:        usage.Usage,
+		CostUSD:      usage.CostUSD,
+		ToolsUsed:    ToolNamesUsed(events),
+		FilesTouched: FilesTouched(events),
+		Error:        errNoResult,
+	}
+	for _, e := range events {
+		if id := e.SessionID(); id != "" {
+			meta.SessionID = id
+		}
+		if m := e.Model(); m != "" {
+			meta.Model = m
+		}
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if e := events[i]; e.IsResult() {
+			meta.NumTurns = intField(e.Data, "num_turns")
+			meta.DurationMS = intField(e.Data, "duration_ms")
+			meta.Error = ""
+			if e.IsError() {
+				meta.Error = e.ResultText()
+			}
+			break
+		}
+	}
+	return meta
+}
@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMetadata_RepresentativeRun(t *testing.T) {
+	events := []Event{
+		{Type: "system", Data: map[string]any{"subtype": "init", "session_id": "s1", "model": "claude-sonnet-4-5"}},
+		assistantBlocks(toolUse("1", "Read", map[string]any{"file_path": "/repo/spec.md"})),
+		toolResult("1", false, "# Spec"),
+		assistantWithUsage("claude-sonnet-4-5-20250929", 100, 50, 10, 20),
+		assistantBlocks(toolUse("2", "Write", map[string]any{"file_path": "/repo/plan.md"}), toolUse("3", "Bash", nil)),
+		assistantWithUsage("claude-sonnet-4-5-20250929", 200, 80, 0, 30),
+		{Type: "result", Data: map[string]any{
+			"subtype": "success", "result": "## Plan", "session_id": "s1",
+			"num_turns": float64(4), "duration_ms": float64(4200), "total_cost_usd": 0.031,
+		}},
+	}
+
+	require.Equal(t, RunMeta{
+		SessionID:    "s1",
+		Model:        "claude-sonnet-4-5-20250929",
+		NumTurns:     4,
+		DurationMS:   4200,
+		Usage:        Usage{InputTokens: 300, OutputTokens: 130, CacheCreationInputTokens: 10, CacheReadInputTokens: 50},
+		CostUSD:      0.031,
+		ToolsUsed:    []string{"Bash", "Read", "Write"},
+		FilesTouched: []string{"/repo/plan.md", "/repo/spec.md"},
+	}, RunMetadata(events))
+}
+
+func TestRunMetadata_Errors(t *testing.T) {
+	failed := RunMetadata([]Event{{Type: "result", Data: map[string]any{"is_error": true, "result": "max turns reached", "num_turns": float64(10)}}})
+	require.Equal(t, "max turns reached", failed.Error)
+	require.Equal(t, 10, failed.NumTurns)
+
+	require.Equal(t, errNoResult, RunMetadata([]Event{{Type: "system", Data: map[string]any{"session_id": "s1"}}}).Error)
+}
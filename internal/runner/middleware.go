@@ -0,0 +1,69 @@
+package runner
+
+import "slices"
+
+// Middleware transforms an event stream. It must close its output channel
+// once in is closed and drained.
+type Middleware func(in <-chan Event) <-chan Event
+
+// Chain composes mws into one Middleware. Events pass through the middlewares
+// in the order given, so the first middleware sees the raw stream. An empty
+// chain returns its input unchanged.
+func Chain(mws ...Middleware) Middleware {
+	return func(in <-chan Event) <-chan Event {
+		out := in
+		for _, mw := range mws {
+			out = mw(out)
+		}
+		return out
+	}
+}
+
+// MapEvents returns a Middleware that replaces each event with fn's result,
+// dropping it when fn reports false.
+func MapEvents(fn func(Event) (Event, bool)) Middleware {
+	return func(in <-chan Event) <-chan Event {
+		out := make(chan Event, 64)
+		go func() {
+			defer close(out)
+			for e := range in {
+				if mapped, ok := fn(e); ok {
+					out <- mapped
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Tap returns a Middleware that calls fn with each event, in order, before
+// passing it on unchanged. fn runs on the middleware's goroutine, so while it
+// runs the events behind it wait in the buffer; a nil fn returns the stream
+// as is.
+func Tap(fn func(Event)) Middleware {
+	if fn == nil {
+		return func(in <-chan Event) <-chan Event { return in }
+	}
+	return MapEvents(func(e Event) (Event, bool) {
+		fn(e)
+		return e, true
+	})
+}
+
+// FilterEventTypes returns a Middleware passing on only events whose Type is
+// one of types. No types passes every event.
+func FilterEventTypes(types ...string) Middleware {
+	if len(types) == 0 {
+		return func(in <-chan Event) <-chan Event { return in }
+	}
+	return MapEvents(func(e Event) (Event, bool) {
+		return e, slices.Contains(types, e.Type)
+	})
+}
+
+// OutputMiddleware returns the Middleware applying opts' EventTypes filter and
+// then its OnEvent callback, in that order, so the callback sees what the
+// consumer does. Runners apply it to the event channel they return.
+func OutputMiddleware(opts RunOptions) Middleware {
+	return Chain(FilterEventTypes(opts.EventTypes...), Tap(opts.OnEvent))
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// streamOf returns a closed channel holding events.
+func streamOf(events ...Event) <-chan Event {
+	ch := make(chan Event, len(events))
+	for _, e := range events {
+		ch <- e
+	}
+	close(ch)
+	return ch
+}
+
+// appendTag returns a Middleware that appends name to each event's "tags".
+func appendTag(name string) Middleware {
+	return MapEvents(func(e Event) (Event, bool) {
+		tags, _ := e.Data["tags"].([]string)
+		e.Data = map[string]any{"tags": append(append([]string(nil), tags...), name)}
+		return e, true
+	})
+}
+
+func TestChain_EmptyIsIdentity(t *testing.T) {
+	in := streamOf(Event{Type: "system"})
+	require.Equal(t, in, Chain()(in))
+}
+
+func TestChain_AppliesMiddlewaresInOrder(t *testing.T) {
+	dropStderr := MapEvents(func(e Event) (Event, bool) { return e, e.Type != "stderr" })
+
+	out := Chain(appendTag("first"), dropStderr, appendTag("second"))(streamOf(
+		Event{Type: "system"},
+		Event{Type: "stderr"},
+		Event{Type: "result"},
+	))
+
+	var got []Event
+	for e := range out {
+		got = append(got, e)
+	}
+	require.Len(t, got, 2)
+	require.Equal(t, "system", got[0].Type)
+	require.Equal(t, "result", got[1].Type)
+	for _, e := range got {
+		require.Equal(t, []string{"first", "second"}, e.Data["tags"])
+	}
+}
+
+func TestFilterEventTypes(t *testing.T) {
+	in := streamOf(Event{Type: "system"}, Event{Type: "assistant"}, Event{Type: "result"}, Event{Type: "stderr"})
+	var got []string
+	for e := range FilterEventTypes("result", "system")(in) {
+		got = append(got, e.Type)
+	}
+	require.Equal(t, []string{"system", "result"}, got)
+
+	all := streamOf(Event{Type: "system"})
+	require.Equal(t, all, FilterEventTypes()(all), "no types passes every event")
+}
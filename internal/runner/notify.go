@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultWebhookTimeout bounds a WebhookNotifier delivery when Timeout is unset.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// resultExcerptRunes caps the result text carried by a Notification.
+const resultExcerptRunes = 500
+
+// Notification summarises a finished run for a Notifier.
+type Notification struct {
+	Status     RunStatus `json:"status"`
+	CostUSD    float64   `json:"cost_usd"`
+	DurationMS int64     `json:"duration_ms"`
+	Result     string    `json:"result,omitempty"` // leading excerpt of the result text
+	Error      string    `json:"error,omitempty"`  // terminal run error, if any
+}
+
+// Notifier is told when a run completes or fails.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// WebhookNotifier POSTs each Notification as JSON to URL, which suits Slack
+// workflow webhooks and most chat-ops receivers.
+type WebhookNotifier struct {
+	URL     string
+	Client  *http.Client  // nil uses http.DefaultClient
+	Timeout time.Duration // per-delivery limit; zero uses DefaultWebhookTimeout
+}
+
+// Notify POSTs n to the webhook and fails on a non-2xx response.
+func (w *WebhookNotifier) Notify(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("encoding notification: %w", err)
+	}
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// notify builds the Notification for a finished run and delivers it. The
+// notifier's error is dropped: the run has already ended and has nowhere to
+// report it.
+func notify(n Notifier, result *Event, runErr error, elapsed time.Duration) {
+	var events []Event
+	if result != nil {
+		events = []Event{*result}
+	}
+	msg := Notification{
+		Status:     FinalStatus(events, runErr),
+		DurationMS: elapsed.Milliseconds(),
+	}
+	if result != nil {
+		msg.CostUSD = result.CostUSD()
+		msg.Result = excerpt(result.ResultText(), resultExcerptRunes)
+	}
+	if runErr != nil {
+		msg.Error = runErr.Error()
+	}
+	_ = n.Notify(msg)
+}
+
+// excerpt returns at most max runes of s, marking a cut with an ellipsis.
+func excerpt(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max]) + "…"
+}
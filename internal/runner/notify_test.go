@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// webhookServer records each JSON notification POSTed to it.
+func webhookServer(t *testing.T, delay time.Duration) (*httptest.Server, <-chan Notification) {
+	t.Helper()
+	got := make(chan Notification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var n Notification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		time.Sleep(delay)
+		got <- n
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func TestRunCommand_NotifiesWebhookOnCompletion(t *testing.T) {
+	srv, got := webhookServer(t, 0)
+	cmd := fakeProcess(`echo '{"type":"result","subtype":"success","result":"the plan","total_cost_usd":0.25}'`)
+
+	_, err := collect(RunCommand(cmd, RunOptions{Notifier: &WebhookNotifier{URL: srv.URL}}))
+	require.NoError(t, err)
+
+	select {
+	case n := <-got:
+		require.Equal(t, RunStatusSuccess, n.Status)
+		require.InDelta(t, 0.25, n.CostUSD, 1e-9)
+		require.Equal(t, "the plan", n.Result)
+		require.Empty(t, n.Error)
+		require.GreaterOrEqual(t, n.DurationMS, int64(0))
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestRunCommand_NotifiesWebhookOnFailure(t *testing.T) {
+	srv, got := webhookServer(t, 0)
+	cmd := fakeProcess(`exit 2`)
+
+	_, err := collect(RunCommand(cmd, RunOptions{DiscardStderr: true, Notifier: &WebhookNotifier{URL: srv.URL}}))
+	require.Error(t, err)
+
+	select {
+	case n := <-got:
+		require.Equal(t, RunStatusError, n.Status)
+		require.Contains(t, n.Error, "exited with error")
+		require.Empty(t, n.Result)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+// blockingNotifier records each notification and then blocks until release
+// is closed.
+type blockingNotifier struct {
+	got     chan Notification
+	release chan struct{}
+}
+
+func (b *blockingNotifier) Notify(n Notification) error {
+	b.got <- n
+	<-b.release
+	return nil
+}
+
+func TestRunCommand_SlowNotifierDoesNotBlockRun(t *testing.T) {
+	notifier := &blockingNotifier{got: make(chan Notification, 1), release: make(chan struct{})}
+	cmd := fakeProcess(`echo '{"type":"result","result":"done"}'`)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := collect(RunCommand(cmd, RunOptions{Notifier: notifier}))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run waited for the notifier")
+	}
+	require.Equal(t, "done", (<-notifier.got).Result)
+	close(notifier.release)
+}
+
+func TestWebhookNotifier_TimesOut(t *testing.T) {
+	srv, _ := webhookServer(t, 300*time.Millisecond)
+
+	err := (&WebhookNotifier{URL: srv.URL, Timeout: 50 * time.Millisecond}).Notify(Notification{Status: RunStatusSuccess})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "posting webhook")
+}
+
+func TestWebhookNotifier_NonSuccessStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := (&WebhookNotifier{URL: srv.URL}).Notify(Notification{})
+	require.EqualError(t, err, "webhook returned 403 Forbidden")
+}
+
+func TestExcerpt_TruncatesOnRuneBoundary(t *testing.T) {
+	require.Equal(t, "short", excerpt("short", 10))
+	require.Equal(t, "héll…", excerpt("héllo wörld", 4))
+	require.Len(t, []rune(excerpt(strings.Repeat("x", 600), resultExcerptRunes)), resultExcerptRunes+1)
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+)
+
+// PlanResult is the outcome of a drained planning run, for callers that want
+// a value rather than channels.
+type PlanResult struct {
+	events    []Event
+	text      string
+	questions []Question
+	usage     UsageSummary
+	err       error
+}
+
+// Text returns the final result text with markers stripped, or empty if the
+// run produced no successful result.
+func (p *PlanResult) Text() string { return p.text }
+
+// Questions returns the questions the agent asked, in order, and left
+// unanswered by RunOptions.Answers.
+func (p *PlanResult) Questions() []Question { return p.questions }
+
+// Usage returns the run's token usage and cost.
+func (p *PlanResult) Usage() UsageSummary { return p.usage }
+
+// Cost returns the run's cost in USD.
+func (p *PlanResult) Cost() float64 { return p.usage.CostUSD }
+
+// Events returns every event of the run.
+func (p *PlanResult) Events() []Event { return p.events }
+
+// Err returns why the run failed: the runner's error, an error result, or
+// the lack of any result. It is nil for a successful run.
+func (p *PlanResult) Err() error { return p.err }
+
+// Plan plans spec with the agent cfg.Agent names and returns the drained run.
+// opts is completed with the planning prompt, unless opts.Prompts.User is
+// already set, and with cfg, ctx and cfg's run defaults. Once the run has
+// started, Plan returns a non-nil PlanResult and its Err; a nil PlanResult
+// means the runner could not be created. When the agent stops to ask
+// questions that opts.Answers answers in full, Plan resumes the session with
+// those answers, up to maxAnsweredResumes times, and the result covers every
+// resumed run. Cancelling ctx stops the run.
+func Plan(ctx context.Context, cfg config.Config, spec string, opts RunOptions) (*PlanResult, error) {
+	r, err := NewRunner(cfg.Agent)
+	if err != nil {
+		return nil, fmt.Errorf("creating runner: %w", err)
+	}
+	if opts.Prompts.User == "" {
+		opts.Prompts.User = BuildPrompt(spec)
+	}
+	p := runPlan(ctx, r, cfg, opts)
+	return p, p.err
+}
+
+// runPlan runs opts, completed with cfg, ctx and cfg's run defaults, with r
+// and drains the run into a PlanResult, retrying a failed run up to
+// opts.Retries times. Plan, PlanFile and Compare all plan through it, so they
+// judge a run's outcome alike.
+func runPlan(ctx context.Context, r Runner, cfg config.Config, opts RunOptions) *PlanResult {
+	opts.Config = cfg
+	opts.Context = ctx
+	opts = opts.WithDefaults(cfg.Run)
+
+	if opts.Retries > 0 {
+		r = NewRetry(r)
+	}
+
+	p := &PlanResult{}
+	var runErr error
+	for resumes := 0; ; resumes++ {
+		first := len(p.events)
+		stream, errc := r.Run(opts)
+		runErr = Drain(ctx, stream, errc, func(e Event) { p.events = append(p.events, e) })
+		p.questions = nil
+		for _, e := range p.events[first:] {
+			p.questions = append(p.questions, detectQuestions(e.TextContent())...)
+		}
+		if runErr != nil || len(p.questions) == 0 || opts.Answers == nil || resumes == maxAnsweredResumes {
+			break
+		}
+		session := sessionOf(p.events[first:])
+		if session == "" || len(UnansweredQuestions(p.questions, opts.Answers)) > 0 {
+			break
+		}
+		opts.SessionID = session
+		opts.Prompt = ""
+		opts.Prompts.User = PredefinedAnswers(opts.Answers, nil)(p.questions)
+	}
+	p.usage = SummarizeUsage(p.events)
+
+	result, found := lastResult(p.events)
+	switch {
+	case runErr != nil:
+		p.err = fmt.Errorf("running %s: %w", cfg.Agent, runErr)
+	case !found:
+		p.err = fmt.Errorf("running %s: agent produced no result", cfg.Agent)
+	case result.IsError():
+		p.err = fmt.Errorf("running %s: agent reported an error: %s", cfg.Agent, result.ResultText())
+	default:
+		p.text = StripMarkers(result.ResultText())
+	}
+	return p
+}
+
+// maxAnsweredResumes bounds how many times runPlan resumes a session with
+// RunOptions.Answers, so an agent that keeps asking cannot run up costs.
+const maxAnsweredResumes = 8
+
+// sessionOf returns the last session id carried by events, or empty.
+func sessionOf(events []Event) string {
+	for i := len(events) - 1; i >= 0; i-- {
+		if id := events[i].SessionID(); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// lastResult returns the last result event in events.
+func lastResult(events []Event) (Event, bool) {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].IsResult() {
+			return events[i], true
+		}
+	}
+	return Event{}, false
+}
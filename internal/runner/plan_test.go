@@ -0,0 +1,139 @@
+package runner_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jumppad-labs/spektacular/internal/runner"
+	"github.com/jumppad-labs/spektacular/internal/runner/claude"
+	"github.com/jumppad-labs/spektacular/internal/runner/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan_WithoutQuestions(t *testing.T) {
+	cfg, replay := testutil.RegisterReplay(t, "mock-replay",
+		testutil.AssistantUsage("claude-sonnet", 100, 50),
+		runner.Event{Type: "result", Data: map[string]any{"result": "## Plan\n<!--FINISHED-->", "total_cost_usd": 0.02}},
+	)
+	cfg.Run.MaxTurns = 3
+
+	plan, err := runner.Plan(context.Background(), cfg, "# Feature: auth", runner.RunOptions{Model: "opus"})
+	require.NoError(t, err)
+	require.NoError(t, plan.Err())
+	require.Equal(t, "## Plan", plan.Text())
+	require.Empty(t, plan.Questions())
+	require.Equal(t, 0.02, plan.Cost())
+	require.Equal(t, 150, plan.Usage().InputTokens+plan.Usage().OutputTokens)
+	require.Len(t, plan.Events(), 2)
+
+	got := replay.Opts()
+	require.Equal(t, runner.BuildPrompt("# Feature: auth"), got.Prompts.User)
+	require.Equal(t, "opus", got.Model)
+	require.Equal(t, 3, got.MaxTurns)
+}
+
+func TestPlan_WithQuestions(t *testing.T) {
+	question := `<!--QUESTION:{"questions":[{"question":"Which database?","header":"Database"}]}-->`
+	cfg, _ := testutil.RegisterReplay(t, "mock-replay",
+		runner.Event{Type: "assistant", Data: map[string]any{"message": map[string]any{
+			"content": []any{map[string]any{"type": "text", "text": "Before planning:\n" + question}},
+		}}},
+		runner.Event{Type: "result", Data: map[string]any{"result": "Before planning:\n" + question}},
+	)
+
+	plan, err := runner.Plan(context.Background(), cfg, "# Feature: auth", runner.RunOptions{Prompts: runner.Prompts{User: "custom prompt"}})
+	require.NoError(t, err)
+	require.Len(t, plan.Questions(), 1, "questions in the result text are not counted twice")
+	require.Equal(t, "Database", plan.Questions()[0].Header)
+	require.Equal(t, "Before planning:", plan.Text())
+}
+
+func TestPlan_Failures(t *testing.T) {
+	cfg, _ := testutil.RegisterReplay(t, "mock-replay", runner.Event{Type: "result", Data: map[string]any{"is_error": true, "result": "max turns"}})
+	plan, err := runner.Plan(context.Background(), cfg, "spec", runner.RunOptions{})
+	require.EqualError(t, err, "running mock-replay: agent reported an error: max turns")
+	require.Equal(t, err, plan.Err())
+	require.Empty(t, plan.Text())
+	require.Len(t, plan.Events(), 1)
+
+	cfg.Agent = "unknown-agent"
+	plan, err = runner.Plan(context.Background(), cfg, "spec", runner.RunOptions{})
+	require.ErrorContains(t, err, "creating runner")
+	require.Nil(t, plan)
+}
+
+// flakyRunner fails its first run and replays plan on every later one.
+type flakyRunner struct {
+	plan  *testutil.Replay
+	calls int
+}
+
+func (f *flakyRunner) Run(opts runner.RunOptions) (<-chan runner.Event, <-chan error) {
+	f.calls++
+	if f.calls == 1 {
+		events := make(chan runner.Event)
+		close(events)
+		errc := make(chan error, 1)
+		errc <- &runner.ErrRunFailed{Command: "mock", ExitCode: 1, Err: errors.New("exit status 1")}
+		close(errc)
+		return events, errc
+	}
+	return f.plan.Run(opts)
+}
+
+func TestPlan_RetriesFailedRun(t *testing.T) {
+	flaky := &flakyRunner{plan: &testutil.Replay{Events: []runner.Event{
+		{Type: "result", Data: map[string]any{"result": "## Plan\n<!--FINISHED-->"}},
+	}}}
+	cfg := testutil.RegisterRunner(t, "mock-flaky", func() runner.Runner { return flaky })
+	cfg.Run.Retries = 1
+
+	plan, err := runner.Plan(context.Background(), cfg, "spec", runner.RunOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "## Plan", plan.Text())
+	require.Equal(t, 2, flaky.calls)
+
+	flaky.calls = 0
+	_, err = runner.Plan(context.Background(), cfg, "spec", runner.RunOptions{Retries: runner.NoLimit})
+	var failed *runner.ErrRunFailed
+	require.ErrorAs(t, err, &failed, "NoLimit turns the configured retries off")
+	require.Equal(t, 1, flaky.calls)
+}
+
+func TestPlan_AnswersQuestionsFromAnswersFile(t *testing.T) {
+	// The fake claude CLI asks which database to use, and plans once a
+	// resumed session is given the answer.
+	cli := filepath.Join(t.TempDir(), "claude")
+	require.NoError(t, os.WriteFile(cli, []byte(`#!/bin/sh
+for a; do last=$a; [ "$a" = --resume ] && resumed=1; done
+if [ -z "$resumed" ]; then
+	echo '{"type":"system","session_id":"s1"}'
+	echo '{"type":"assistant","message":{"content":[{"type":"text","text":"<!--QUESTION:{\\"questions\\":[{\\"question\\":\\"Which DB?\\",\\"header\\":\\"Database\\"},{\\"question\\":\\"Which cache?\\",\\"header\\":\\"Cache\\"}]}-->"}]}}'
+	echo '{"type":"result","result":"Waiting for answers."}'
+	exit
+fi
+case "$last" in
+*"Database (Which DB?): Postgres"*"Cache (Which cache?): No preference"*) echo '{"type":"result","result":"## Plan on Postgres<!--FINISHED-->"}' ;;
+*) echo '{"type":"result","is_error":true,"result":"unexpected answers"}' ;;
+esac
+`), 0755))
+	cfg := testutil.RegisterRunner(t, "mock-answers", func() runner.Runner { return &claude.Claude{Command: cli} })
+	answersFile := filepath.Join(t.TempDir(), "answers.yaml")
+	require.NoError(t, os.WriteFile(answersFile, []byte("Database: Postgres\nCache: <skip>\n"), 0644))
+	answers, err := runner.LoadAnswers(answersFile)
+	require.NoError(t, err)
+
+	plan, err := runner.Plan(context.Background(), cfg, "spec", runner.RunOptions{Answers: answers})
+	require.NoError(t, err)
+	require.Equal(t, "## Plan on Postgres", plan.Text())
+	require.Empty(t, plan.Questions(), "every question was answered")
+	require.Len(t, plan.Events(), 4, "the events of both runs are kept")
+
+	plan, err = runner.Plan(context.Background(), cfg, "spec", runner.RunOptions{Answers: map[string]string{"Database": "Postgres"}})
+	require.NoError(t, err, "a run is only resumed when the answers cover every question")
+	require.Equal(t, "Waiting for answers.", plan.Text())
+	require.Len(t, plan.Questions(), 2)
+}
@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+)
+
+// ErrEmptySpec is returned by LoadSpec for a spec file with no content.
+var ErrEmptySpec = errors.New("spec file is empty")
+
+// LoadSpec reads the spec at path, rejecting a file that is empty or only
+// whitespace.
+func LoadSpec(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading spec: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", fmt.Errorf("%s: %w", path, ErrEmptySpec)
+	}
+	return string(data), nil
+}
+
+// PlanFile plans the spec at specPath end to end: it loads the spec, builds
+// the planning prompt, runs the agent cfg.Agent names with cfg's run
+// defaults, and returns the final result text with markers stripped, along
+// with the run's usage. Cancelling ctx stops the run.
+func PlanFile(ctx context.Context, cfg config.Config, specPath string) (string, UsageSummary, error) {
+	spec, err := LoadSpec(specPath)
+	if err != nil {
+		return "", UsageSummary{}, fmt.Errorf("loading spec: %w", err)
+	}
+	r, err := NewRunner(cfg.Agent)
+	if err != nil {
+		return "", UsageSummary{}, fmt.Errorf("creating runner: %w", err)
+	}
+	return planSpec(ctx, r, cfg, spec, "")
+}
+
+// planSpec plans spec with r using model, or the agent default when model is
+// empty, and returns the final result text with markers stripped and the
+// run's usage.
+func planSpec(ctx context.Context, r Runner, cfg config.Config, spec, model string) (string, UsageSummary, error) {
+	p := runPlan(ctx, r, cfg, RunOptions{Prompts: Prompts{User: BuildPrompt(spec)}, Model: model})
+	return p.text, p.usage, p.err
+}
@@ -0,0 +1,60 @@
+package runner_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jumppad-labs/spektacular/internal/runner"
+	"github.com/jumppad-labs/spektacular/internal/runner/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSpec(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "spec.md")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestPlanFile_ReturnsPlanAndUsage(t *testing.T) {
+	cfg, replay := testutil.RegisterReplay(t, "mock-plan",
+		testutil.AssistantUsage("claude-sonnet", 100, 50),
+		runner.Event{Type: "result", Data: map[string]any{"result": "## Plan\n<!--FINISHED-->", "total_cost_usd": 0.02}},
+	)
+	cfg.Run.MaxTurns = 7
+
+	plan, usage, err := runner.PlanFile(context.Background(), cfg, writeSpec(t, "# Feature: auth"))
+	require.NoError(t, err)
+	require.Equal(t, "## Plan", plan)
+	require.Equal(t, 0.02, usage.CostUSD)
+	require.Equal(t, 100, usage.InputTokens)
+	require.Equal(t, runner.BuildPrompt("# Feature: auth"), replay.Opts().Prompts.User)
+	require.Equal(t, 7, replay.Opts().MaxTurns, "run defaults come from the config")
+}
+
+func TestPlanFile_WrapsStageErrors(t *testing.T) {
+	cfg, _ := testutil.RegisterReplay(t, "mock-fail",
+		testutil.AssistantUsage("claude-sonnet", 100, 50),
+		runner.Event{Type: "result", Data: map[string]any{"result": "quota exhausted", "is_error": true}},
+	)
+
+	_, _, err := runner.PlanFile(context.Background(), cfg, filepath.Join(t.TempDir(), "missing.md"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.ErrorContains(t, err, "loading spec")
+
+	_, _, err = runner.PlanFile(context.Background(), cfg, writeSpec(t, "  \n"))
+	require.ErrorIs(t, err, runner.ErrEmptySpec)
+
+	cfg.Agent = "nope"
+	_, _, err = runner.PlanFile(context.Background(), cfg, writeSpec(t, "spec"))
+	var unsupported *runner.ErrUnsupportedRunner
+	require.ErrorAs(t, err, &unsupported)
+	require.ErrorContains(t, err, "creating runner")
+
+	cfg.Agent = "mock-fail"
+	_, usage, err := runner.PlanFile(context.Background(), cfg, writeSpec(t, "spec"))
+	require.EqualError(t, err, "running mock-fail: agent reported an error: quota exhausted")
+	require.Equal(t, 150, usage.InputTokens+usage.OutputTokens)
+}
@@ -0,0 +1,584 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+)
+
+// maxLineBytes caps a single stream-json line read from an agent subprocess.
+const maxLineBytes = 1024 * 1024 // 1 MiB lines
+
+// CommandRunner is a Runner backed by a local CLI subprocess. Cmd builds the
+// subprocess for opts without starting it, so wrappers such as Docker can
+// rewrite the command line; the returned cleanup func, if non-nil, is called
+// once the process has exited.
+type CommandRunner interface {
+	Runner
+	Cmd(opts RunOptions) (cmd *exec.Cmd, cleanup func(), err error)
+}
+
+// Exec builds the subprocess from cr and streams it with RunCommand semantics,
+// calling the cleanup func after the process exits. If cr is a
+// CapabilityReporter, a "warning" event for each unsupported option set on
+// opts precedes the agent's events. CommandRunner implementations typically
+// use Exec as their Run method.
+func Exec(cr CommandRunner, opts RunOptions) (<-chan Event, <-chan error) {
+	cmd, cleanup, err := cr.Cmd(opts)
+	if err != nil {
+		return failed(err)
+	}
+	var warnings []Event
+	if rep, ok := cr.(CapabilityReporter); ok {
+		warnings = UnsupportedWarnings(opts, rep.Capabilities(opts))
+	}
+	return start(cmd, cleanup, opts, warnings...)
+}
+
+// RunCommand starts cmd and streams its output as Events. Each stdout line is
+// decoded as a stream-json object; each stderr line is emitted as a "stderr"
+// event carrying the raw text in Data["line"], interleaved with the stdout
+// events in arrival order. Set RunOptions.DiscardStderr to drop stderr instead.
+// If the agent exits cleanly after assistant text but without a result event,
+// a result is synthesized from that text; see Event.IsSynthesized.
+//
+// Events are sent synchronously, so a consumer that falls behind the 64-event
+// channel buffer blocks the readers, the OS pipe fills, and the agent itself
+// stalls on its next write. Memory stays bounded by the channel buffer plus
+// one line buffer per stream, however slow the consumer is.
+// Backends build the agent-specific command line and delegate to RunCommand so
+// every runner shares the same channel semantics.
+func RunCommand(cmd *exec.Cmd, opts RunOptions) (<-chan Event, <-chan error) {
+	return start(cmd, nil, opts)
+}
+
+// start runs cmd under Supervise, sending the leading events before the
+// agent's own and calling cleanup once the process has exited.
+func start(cmd *exec.Cmd, cleanup func(), opts RunOptions, leading ...Event) (<-chan Event, <-chan error) {
+	return Supervise(opts, func(opts RunOptions, events chan<- Event) error {
+		err := runCommand(cmd, opts, events)
+		if cleanup != nil {
+			cleanup()
+		}
+		return err
+	}, leading...)
+}
+
+// failed returns closed channels carrying only err.
+func failed(err error) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errc := make(chan error, 1)
+	close(events)
+	errc <- err
+	close(errc)
+	return events, errc
+}
+
+func runCommand(cmd *exec.Cmd, opts RunOptions, events chan<- Event) error {
+	name := filepath.Base(cmd.Path)
+	fatal, err := compileFatalPatterns(opts.FatalStderrPatterns)
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("creating stdout pipe: %w", err)
+	}
+	var stderr io.ReadCloser
+	if !opts.DiscardStderr || len(fatal) > 0 {
+		stderr, err = cmd.StderrPipe()
+		if err != nil {
+			return fmt.Errorf("creating stderr pipe: %w", err)
+		}
+	}
+	if err := prepareLimits(cmd, opts); err != nil {
+		return err
+	}
+	if opts.Context != nil {
+		if err := opts.Context.Err(); err != nil {
+			return fmt.Errorf("run cancelled: %w", err)
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return &ErrAgentNotFound{Command: name, Err: err}
+	}
+	if opts.Context != nil {
+		// Closing the pipes unblocks the readers even if an orphaned tool
+		// subprocess still holds the write ends open.
+		stop := context.AfterFunc(opts.Context, func() {
+			terminate(cmd)
+			stdout.Close()
+			if stderr != nil {
+				stderr.Close()
+			}
+		})
+		defer stop()
+	}
+
+	var limitErr <-chan error
+	if hasLimits(opts) {
+		done := make(chan struct{})
+		defer close(done)
+		limitErr = watchLimits(cmd.Process.Pid, opts, done)
+	}
+
+	var wg sync.WaitGroup
+	var stderrErr error
+	if stderr != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if stderrErr = scanStderr(stderr, opts, fatal, events); stderrErr != nil {
+				// Closing stdout ends the decoder even if an orphaned tool
+				// subprocess still holds the write end open.
+				terminate(cmd)
+				stdout.Close()
+			}
+		}()
+	}
+
+	var summary streamSummary
+	if stopErr := decodeStream(TeeRawLog(stdout, opts), opts, events, &summary); stopErr != nil {
+		// Stopping early: kill the agent, then Wait so the pipes are closed
+		// even if an orphaned tool subprocess still holds them open.
+		terminate(cmd)
+		cmd.Wait()
+		wg.Wait()
+		if stopErr == errStopAfterResult {
+			return nil
+		}
+		return stopErr
+	}
+
+	// Both pipes must be fully read before Wait, which closes them.
+	wg.Wait()
+	waitErr := cmd.Wait()
+	if opts.Context != nil && opts.Context.Err() != nil {
+		return fmt.Errorf("run cancelled: %w", opts.Context.Err())
+	}
+	if stderrErr != nil {
+		return stderrErr
+	}
+	select {
+	case err := <-limitErr:
+		if err != nil {
+			return err
+		}
+	default:
+	}
+	if waitErr != nil {
+		code := -1
+		var exitErr *exec.ExitError
+		if errors.As(waitErr, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+		return &ErrRunFailed{Command: name, ExitCode: code, Err: waitErr}
+	}
+	if text, ok := summary.textResult(); ok {
+		if err := deliverText(text, opts, events, &summary); err != nil && err != errStopAfterResult {
+			return err
+		}
+		return nil
+	}
+	if e, ok := summary.synthesizeResult(); ok {
+		events <- opts.stamp(e)
+	}
+	return nil
+}
+
+// OutputFormat returns the agent output format opts selects: the configured
+// claude.output_format, or config.OutputFormatStreamJSON when unset. Runners
+// request this format from the agent and the decoder parses accordingly.
+func OutputFormat(opts RunOptions) string {
+	if f := opts.Config.Claude.OutputFormat; f != "" {
+		return f
+	}
+	return config.OutputFormatStreamJSON
+}
+
+// TeeRawLog returns a reader that copies what is read from r, the agent's raw
+// output, to RunOptions.RawLog on a best-effort basis. Without a RawLog it
+// returns r.
+func TeeRawLog(r io.Reader, opts RunOptions) io.Reader {
+	if opts.RawLog == nil {
+		return r
+	}
+	return io.TeeReader(r, &rawLog{w: opts.RawLog})
+}
+
+// rawLog copies output to RunOptions.RawLog on a best-effort basis: it always
+// reports success, so a failing log cannot end decoding, and stops writing
+// after the first error.
+type rawLog struct {
+	w      io.Writer
+	failed bool
+}
+
+func (l *rawLog) Write(p []byte) (int, error) {
+	if !l.failed {
+		if _, err := l.w.Write(p); err != nil {
+			l.failed = true
+		}
+	}
+	return len(p), nil
+}
+
+// decodeStream decodes the agent's stdout in the format OutputFormat selects
+// and sends one Event per decoded object. It returns a non-nil error when an
+// event requires the run to stop early; the event that triggered the stop is
+// still delivered. Delivered events are recorded in summary, which may be nil.
+func decodeStream(r io.Reader, opts RunOptions, events chan<- Event, summary *streamSummary) error {
+	switch f := OutputFormat(opts); f {
+	case config.OutputFormatStreamJSON:
+		return decodeLines(r, opts, events, summary)
+	case config.OutputFormatJSON:
+		return decodeDocument(r, opts, events, summary)
+	case config.OutputFormatText:
+		return decodeText(r, opts, events, summary)
+	default:
+		return fmt.Errorf("unsupported output format %q", f)
+	}
+}
+
+// decodeLines reads newline-delimited JSON objects from r, reading no further
+// ahead than one line buffer while a send on events blocks. Blank lines are
+// skipped. Lines that do not start with "{" are treated as log output from a
+// chatty CLI: they are dropped, emitted as "stdout" events when
+// RunOptions.ForwardNonJSON is set, or otherwise emitted as "stderr" events
+// when RunOptions.Debug is set, alongside the CLI's other diagnostics. Lines that look like JSON but fail to
+// decode are skipped too; if any did, a final "diagnostics" event reports how
+// many.
+//
+// While no line has decoded, the lines not forwarded as events are kept in
+// summary, so that a run which exits cleanly having decoded nothing can be
+// given its output as a text result; see streamSummary.textResult.
+//
+// A line longer than maxLineBytes, or any other read error, ends decoding with
+// an error rather than leaving the rest of the stream unread.
+func decodeLines(r io.Reader, opts RunOptions, events chan<- Event, summary *streamSummary) error {
+	var diag decodeDiagnostics
+	defer diag.report(opts, events)
+	fields := fieldMap(opts)
+	if summary == nil {
+		summary = &streamSummary{}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, maxLineBytes), maxLineBytes)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			summary.keepPlain(line)
+			continue
+		}
+		if trimmed := bytes.TrimLeft(line, " \t"); len(trimmed) == 0 || trimmed[0] != '{' {
+			switch {
+			case opts.ForwardNonJSON:
+				events <- opts.stamp(Event{Type: "stdout", Data: map[string]any{"line": string(line)}})
+			case opts.Debug:
+				events <- opts.stamp(Event{Type: "stderr", Data: map[string]any{"line": string(line)}})
+			default:
+				summary.keepPlain(line)
+			}
+			continue
+		}
+		var data map[string]any
+		if err := json.Unmarshal(line, &data); err != nil {
+			diag.record(err)
+			summary.keepPlain(line)
+			continue
+		}
+		summary.decoded = true
+		remapFields(data, fields)
+		// The scanner reuses its buffer, so the event keeps a copy.
+		if err := deliver(data, bytes.Clone(line), opts, events, summary); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return fmt.Errorf("reading agent output: %w", err)
+	}
+	return nil
+}
+
+// maxTextBytes caps the plain-text output kept for a text result.
+const maxTextBytes = 16 * maxLineBytes
+
+// decodeText reads the text output format: the agent's final response as
+// plain text, delivered as one result event flagged with Data["text_output"]
+// once the run ends. Output beyond maxTextBytes is dropped.
+func decodeText(r io.Reader, opts RunOptions, events chan<- Event, summary *streamSummary) error {
+	text, err := io.ReadAll(io.LimitReader(r, maxTextBytes))
+	if err != nil {
+		return fmt.Errorf("reading text output: %w", err)
+	}
+	// Drain the rest so the agent is not blocked writing it.
+	io.Copy(io.Discard, r)
+	return deliverText(string(text), opts, events, summary)
+}
+
+// deliverText delivers text as a successful result event, unless it is blank.
+func deliverText(text string, opts RunOptions, events chan<- Event, summary *streamSummary) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	e := opts.stamp(Event{Type: "result", Data: map[string]any{
+		"type":        "result",
+		"subtype":     "success",
+		"is_error":    false,
+		"result":      text,
+		"text_output": true,
+	}})
+	return deliver(e.Data, nil, opts, events, summary)
+}
+
+// decodeDocument reads the json output format: a JSON array of event objects,
+// or a single result object when the agent is not verbose. The output only
+// arrives when the run ends, so events are delivered together. Output that is
+// not valid JSON is reported in a "diagnostics" event.
+func decodeDocument(r io.Reader, opts RunOptions, events chan<- Event, summary *streamSummary) error {
+	var diag decodeDiagnostics
+	defer diag.report(opts, events)
+
+	var doc json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		if !errors.Is(err, io.EOF) {
+			diag.record(err)
+		}
+		return nil
+	}
+	fields := fieldMap(opts)
+	items := []json.RawMessage{doc}
+	if doc[0] == '[' {
+		if err := json.Unmarshal(doc, &items); err != nil {
+			diag.record(err)
+			return nil
+		}
+	}
+	for _, item := range items {
+		var data map[string]any
+		if err := json.Unmarshal(item, &data); err != nil || data == nil {
+			diag.record(fmt.Errorf("expected a JSON object, got %s", excerpt(string(item), 40)))
+			continue
+		}
+		remapFields(data, fields)
+		if err := deliver(data, item, opts, events, summary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldMap returns the key renames opts selects: claude.field_map, overridden
+// by RunOptions.FieldMap key by key. It is nil when neither is set.
+func fieldMap(opts RunOptions) map[string]string {
+	if len(opts.Config.Claude.FieldMap) == 0 {
+		return opts.FieldMap
+	}
+	m := maps.Clone(opts.Config.Claude.FieldMap)
+	maps.Copy(m, opts.FieldMap)
+	return m
+}
+
+// remapFields renames the keys of v found in fields, in place, in every
+// object nested within it. A renamed key replaces one already present under
+// the canonical name.
+func remapFields(v any, fields map[string]string) {
+	if len(fields) == 0 {
+		return
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		// Renames are collected first so a value is never moved twice.
+		renamed := map[string]any{}
+		for k, child := range v {
+			remapFields(child, fields)
+			if to, ok := fields[k]; ok && to != k {
+				renamed[to] = child
+				delete(v, k)
+			}
+		}
+		maps.Copy(v, renamed)
+	case []any:
+		for _, child := range v {
+			remapFields(child, fields)
+		}
+	}
+}
+
+// deliver sends the event for one decoded object, carrying the raw JSON it was
+// decoded from, and runs the OnQuestion, StopOnToolError and StopAfterResult
+// hooks.
+func deliver(data map[string]any, raw json.RawMessage, opts RunOptions, events chan<- Event, summary *streamSummary) error {
+	eventType, _ := data["type"].(string)
+	e := Event{Type: eventType, Data: data, Raw: raw}
+	if opts.OnQuestion != nil {
+		for _, q := range detectQuestions(e.TextContent()) {
+			opts.OnQuestion(q)
+		}
+	}
+	summary.observe(e)
+	events <- e
+	if opts.StopOnToolError && e.HasToolError() {
+		return &ToolError{Message: e.toolErrorText()}
+	}
+	if opts.StopAfterResult && e.IsResult() {
+		return errStopAfterResult
+	}
+	return nil
+}
+
+// errStopAfterResult stops decoding once RunOptions.StopAfterResult is
+// satisfied. It ends the run cleanly and is never returned to the caller.
+var errStopAfterResult = errors.New("stopped after result")
+
+// streamSummary records what a decoded stream contained, so a run that exits
+// cleanly without a result event can be given one.
+type streamSummary struct {
+	sawResult bool
+	sessionID string
+	texts     []string
+
+	// decoded reports whether any stream-json line decoded; until one does,
+	// plain holds the lines not forwarded as events, up to maxTextBytes.
+	decoded bool
+	plain   bytes.Buffer
+}
+
+// keepPlain records an undecoded stdout line for textResult.
+func (s *streamSummary) keepPlain(line []byte) {
+	if s.decoded || s.plain.Len() >= maxTextBytes {
+		return
+	}
+	s.plain.Write(line)
+	s.plain.WriteByte('\n')
+}
+
+// textResult returns the kept stdout of a stream-json run in which no line
+// decoded, the CLI having ignored the request for stream-json, so that it can
+// be delivered as a text result as in the text output format. It is only
+// used once the agent has exited cleanly, so a crash or a cancelled run never
+// passes its partial output off as a successful result.
+func (s *streamSummary) textResult() (string, bool) {
+	if s.decoded || s.sawResult || strings.TrimSpace(s.plain.String()) == "" {
+		return "", false
+	}
+	return s.plain.String(), true
+}
+
+// observe records e. It is a no-op on a nil summary.
+func (s *streamSummary) observe(e Event) {
+	if s == nil {
+		return
+	}
+	if id := e.SessionID(); id != "" {
+		s.sessionID = id
+	}
+	if e.IsResult() {
+		s.sawResult = true
+	}
+	if t := e.TextContent(); t != "" {
+		s.texts = append(s.texts, t)
+	}
+}
+
+// synthesizeResult returns a successful result event built from the collected
+// assistant text, flagged with Data["synthesized"], when the stream had
+// assistant text but no result of its own.
+func (s *streamSummary) synthesizeResult() (Event, bool) {
+	if s.sawResult || len(s.texts) == 0 {
+		return Event{}, false
+	}
+	data := map[string]any{
+		"type":        "result",
+		"subtype":     "success",
+		"is_error":    false,
+		"result":      strings.Join(s.texts, "\n"),
+		"synthesized": true,
+	}
+	if s.sessionID != "" {
+		data["session_id"] = s.sessionID
+	}
+	return Event{Type: "result", Data: data}, true
+}
+
+// maxDecodeErrorSamples caps the error messages kept in a diagnostics event.
+const maxDecodeErrorSamples = 5
+
+// decodeDiagnostics counts stdout lines that failed to decode.
+type decodeDiagnostics struct {
+	count   int
+	samples []string
+}
+
+func (d *decodeDiagnostics) record(err error) {
+	d.count++
+	if len(d.samples) < maxDecodeErrorSamples {
+		d.samples = append(d.samples, err.Error())
+	}
+}
+
+// report sends a "diagnostics" event if any line failed to decode.
+func (d *decodeDiagnostics) report(opts RunOptions, events chan<- Event) {
+	if d.count == 0 {
+		return
+	}
+	events <- opts.stamp(Event{Type: "diagnostics", Data: map[string]any{
+		"decode_errors":        d.count,
+		"decode_error_samples": d.samples,
+	}})
+}
+
+// scanStderr sends one "stderr" event per line read from r, unless
+// RunOptions.DiscardStderr is set. It stops at the first line matching one of
+// fatal, after sending its event, and returns an *ErrFatalStderr for it, or at
+// a line longer than maxLineBytes, returning an error.
+func scanStderr(r io.Reader, opts RunOptions, fatal []*regexp.Regexp, events chan<- Event) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, maxLineBytes), maxLineBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !opts.DiscardStderr {
+			events <- opts.stamp(Event{Type: "stderr", Data: map[string]any{"line": line}})
+		}
+		for _, re := range fatal {
+			if re.MatchString(line) {
+				return &ErrFatalStderr{Line: line, Pattern: re.String()}
+			}
+		}
+	}
+	// A closed pipe means the run is being stopped, and whoever closed it
+	// reports why.
+	if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return fmt.Errorf("reading agent stderr: %w", err)
+	}
+	return nil
+}
+
+// compileFatalPatterns compiles RunOptions.FatalStderrPatterns.
+func compileFatalPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("fatal stderr pattern %q: %w", p, err)
+		}
+		res[i] = re
+	}
+	return res, nil
+}
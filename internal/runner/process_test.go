@@ -0,0 +1,654 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProcess returns a command that runs script under sh, standing in for an
+// agent CLI subprocess.
+func fakeProcess(script string) *exec.Cmd {
+	return exec.Command("sh", "-c", script)
+}
+
+// collect drains both channels returned by a Run and returns the events seen
+// and the terminal error, if any.
+func collect(events <-chan Event, errc <-chan error) ([]Event, error) {
+	var seen []Event
+	for e := range events {
+		seen = append(seen, e)
+	}
+	return seen, <-errc
+}
+
+func TestRunCommand_DecodesStdoutEvents(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"system","session_id":"s1"}'; echo ''; echo '{"type":"result","result":"done"}'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{}))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "s1", got[0].SessionID())
+	require.Equal(t, "done", got[1].ResultText())
+}
+
+func TestRunCommand_RawLogTeesStdout(t *testing.T) {
+	stdout := "{\"type\":\"system\",\"session_id\":\"s1\"}\nnot json\n\n{\"type\":\"result\",\"result\":\"done\"}"
+	cmd := fakeProcess(`printf '%s' '` + stdout + `'; echo 'noise' >&2`)
+	var raw strings.Builder
+
+	got, err := collect(RunCommand(cmd, RunOptions{RawLog: &raw, DiscardStderr: true}))
+	require.NoError(t, err)
+	require.Equal(t, stdout, raw.String(), "the log holds stdout byte for byte, even unterminated")
+	require.Len(t, got, 2)
+	require.Equal(t, "done", got[1].ResultText())
+}
+
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	w.writes++
+	return 0, io.ErrShortWrite
+}
+
+func TestRunCommand_FailingRawLogDoesNotAffectRun(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"system"}'; sleep 0.05; echo '{"type":"result","result":"done"}'`)
+	log := &failingWriter{}
+
+	got, err := collect(RunCommand(cmd, RunOptions{RawLog: log}))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, 1, log.writes, "writing stops after the first error")
+}
+
+func TestRunCommand_OnEventSeesEveryEventBeforeChannel(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"system","session_id":"s1"}'; echo 'warming up' >&2; sleep 0.05; echo '{"type":"assistant","message":{"content":[{"type":"text","text":"plan"}]}}'`)
+	var mu sync.Mutex
+	var called []Event
+	opts := RunOptions{OnEvent: func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		called = append(called, e)
+	}}
+
+	events, errc := RunCommand(cmd, opts)
+	var got []Event
+	for e := range events {
+		mu.Lock()
+		require.Greater(t, len(called), len(got), "the callback runs before the event is sent")
+		mu.Unlock()
+		got = append(got, e)
+	}
+	require.NoError(t, <-errc)
+	require.Len(t, got, 4, "system, stderr, assistant and the synthesized result")
+	require.True(t, got[3].IsSynthesized())
+	require.Equal(t, got, called)
+}
+
+func TestRunCommand_EventTypesFilterDelivery(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"system","session_id":"s1"}'; echo 'noise' >&2; echo '{"type":"assistant","message":{"content":[{"type":"text","text":"<!--QUESTION:{\"questions\":[{\"question\":\"Q?\",\"header\":\"H\"}]}-->"}]}}'; echo '{"type":"result","result":"done"}'`)
+	var questions, called int
+	opts := RunOptions{
+		EventTypes: []string{"result", "assistant"},
+		OnQuestion: func(Question) { questions++ },
+		OnEvent:    func(Event) { called++ },
+	}
+
+	got, err := collect(RunCommand(cmd, opts))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "assistant", got[0].Type)
+	require.Equal(t, "result", got[1].Type)
+	require.Equal(t, 2, called, "OnEvent sees only the selected events")
+	require.Equal(t, 1, questions)
+}
+
+func TestRunCommand_EventTypesStillDeliverErrors(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"system"}'; exit 3`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{EventTypes: []string{"result"}}))
+	var runErr *ErrRunFailed
+	require.ErrorAs(t, err, &runErr)
+	require.Equal(t, 3, runErr.ExitCode)
+	require.Empty(t, got)
+}
+
+func TestTap_NilIsIdentity(t *testing.T) {
+	in := streamOf(Event{Type: "a"})
+	require.Equal(t, in, Tap(nil)(in))
+}
+
+func TestRunCommand_EmitsStderrEvents(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"system"}'; echo 'warning: token expires soon' >&2; echo 'hint: run login' >&2; sleep 0.1; echo '{"type":"result","result":"plan"}'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{}))
+	require.NoError(t, err)
+
+	var stderr []string
+	var types []string
+	for _, e := range got {
+		types = append(types, e.Type)
+		if e.Type == "stderr" {
+			stderr = append(stderr, e.Data["line"].(string))
+		}
+	}
+	require.Equal(t, []string{"warning: token expires soon", "hint: run login"}, stderr)
+	require.Contains(t, types, "system")
+	require.Equal(t, "result", types[len(types)-1], "stderr emitted before the sleep should arrive ahead of the result")
+}
+
+func TestRunCommand_DiscardStderr(t *testing.T) {
+	cmd := fakeProcess(`echo 'noise' >&2; echo '{"type":"result","result":"plan"}'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{DiscardStderr: true}))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "result", got[0].Type)
+}
+
+func TestRunCommand_FatalStderrPatternKillsRun(t *testing.T) {
+	cmd := fakeProcess(`echo 'hint: run login' >&2; echo 'error: OAuth token has expired' >&2; sleep 5; echo '{"type":"result","result":"garbage"}'`)
+	opts := RunOptions{FatalStderrPatterns: []string{`(?i)token (has )?expired`}}
+
+	began := time.Now()
+	got, err := collect(RunCommand(cmd, opts))
+	require.Less(t, time.Since(began), 4*time.Second, "the run is killed, not waited out")
+
+	var fatal *ErrFatalStderr
+	require.ErrorAs(t, err, &fatal)
+	require.Equal(t, "error: OAuth token has expired", fatal.Line)
+	require.EqualError(t, err, "stopped on fatal stderr line: error: OAuth token has expired")
+	require.Len(t, got, 2, "both stderr lines are delivered, the result never arrives")
+	require.Equal(t, RunStatusError, FinalStatus(got, err))
+}
+
+func TestRunCommand_NonMatchingStderrIsNotFatal(t *testing.T) {
+	cmd := fakeProcess(`echo 'warning: token expires soon' >&2; echo '{"type":"result","result":"plan"}'`)
+	opts := RunOptions{FatalStderrPatterns: []string{`token (has )?expired`}, DiscardStderr: true}
+
+	got, err := collect(RunCommand(cmd, opts))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "plan", got[0].ResultText())
+
+	got, err = collect(RunCommand(fakeProcess(`echo 'token expired' >&2`), opts))
+	require.ErrorAs(t, err, new(*ErrFatalStderr), "patterns apply even when stderr is discarded")
+	require.Empty(t, got)
+}
+
+func TestRunCommand_InvalidFatalStderrPattern(t *testing.T) {
+	_, err := collect(RunCommand(fakeProcess(`true`), RunOptions{FatalStderrPatterns: []string{"("}}))
+	require.ErrorContains(t, err, `fatal stderr pattern "("`)
+}
+
+func TestRunCommand_StopAfterResult(t *testing.T) {
+	script := `echo '{"type":"system","session_id":"s1"}'
+echo '{"type":"result","result":"plan"}'
+echo '{"type":"system","subtype":"housekeeping"}'
+sleep 5
+echo '{"type":"system","subtype":"late"}'`
+
+	began := time.Now()
+	got, err := collect(RunCommand(fakeProcess(script), RunOptions{StopAfterResult: true}))
+	require.NoError(t, err)
+	require.Less(t, time.Since(began), 4*time.Second, "the agent is killed once the result arrives")
+	require.Len(t, got, 2)
+	require.Equal(t, "plan", got[1].ResultText())
+	require.Equal(t, RunStatusSuccess, FinalStatus(got, err))
+}
+
+func TestDecodeStream_StopAfterResultOffDeliversEverything(t *testing.T) {
+	input := `{"type":"result","result":"plan"}` + "\n" + `{"type":"system","subtype":"housekeeping"}` + "\n"
+
+	events := make(chan Event, 4)
+	require.NoError(t, decodeStream(strings.NewReader(input), RunOptions{}, events, nil))
+	require.Len(t, events, 2)
+
+	events = make(chan Event, 4)
+	require.ErrorIs(t, decodeStream(strings.NewReader(input), RunOptions{StopAfterResult: true}, events, nil), errStopAfterResult)
+	require.Len(t, events, 1)
+}
+
+func TestRunCommand_NonZeroExitReturnsError(t *testing.T) {
+	cmd := fakeProcess(`echo 'boom' >&2; exit 3`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exited with error")
+	require.Len(t, got, 1)
+	require.Equal(t, "boom", got[0].Data["line"])
+}
+
+// decodeAll runs decodeStream over input and returns the events it sent.
+func decodeAll(input string, opts RunOptions) []Event {
+	events := make(chan Event, 64)
+	_ = decodeStream(strings.NewReader(input), opts, events, nil)
+	close(events)
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	return got
+}
+
+func TestDecodeStream_OnQuestionReceivesEachQuestionInOrder(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"<!--QUESTION:{\"questions\":[{\"question\":\"Q1?\",\"header\":\"H1\"},{\"question\":\"Q2?\",\"header\":\"H2\"}]}-->"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"no questions"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"<!--QUESTION:{\"questions\":[{\"question\":\"Q3?\",\"header\":\"H3\"}]}-->"}]}}`,
+	}, "\n")
+
+	var headers []string
+	got := decodeAll(input, RunOptions{OnQuestion: func(q Question) {
+		headers = append(headers, q.Header)
+	}})
+	require.Len(t, got, 3)
+	require.Equal(t, []string{"H1", "H2", "H3"}, headers)
+}
+
+func TestDecodeStream_ReportsDecodeErrors(t *testing.T) {
+	lines := []string{`{"type":"system"}`}
+	for range 7 {
+		lines = append(lines, `{"type":"assistant",`)
+	}
+	lines = append(lines, `not json`, `{"type":"result","result":"done"}`)
+
+	got := decodeAll(strings.Join(lines, "\n"), RunOptions{})
+	require.Len(t, got, 3)
+	require.Equal(t, "result", got[1].Type)
+
+	diag := got[len(got)-1]
+	require.Equal(t, "diagnostics", diag.Type)
+	count, samples := diag.DecodeErrors()
+	require.Equal(t, 7, count, "non-JSON log lines are not decode errors")
+	require.Len(t, samples, maxDecodeErrorSamples)
+	require.Contains(t, samples[0], "unexpected end of JSON input")
+}
+
+func TestDecodeStream_NoDiagnosticsForCleanStream(t *testing.T) {
+	got := decodeAll(`{"type":"result","result":"done"}`, RunOptions{})
+	require.Len(t, got, 1)
+	count, _ := got[0].DecodeErrors()
+	require.Zero(t, count)
+}
+
+func TestDecodeStream_SkipsInterleavedLogLines(t *testing.T) {
+	input := strings.Join([]string{
+		`INFO starting agent v1.2`,
+		`{"type":"system","session_id":"s1"}`,
+		`[debug] tool cache warm`,
+		`  {"type":"assistant","message":{"content":[{"type":"text","text":"hi"}]}}`,
+		`{"type":"result","result":"done"}`,
+	}, "\n")
+
+	got := decodeAll(input, RunOptions{})
+	require.Len(t, got, 3)
+	require.Equal(t, "s1", got[0].SessionID())
+	require.Equal(t, "hi", got[1].TextContent())
+	require.Equal(t, "done", got[2].ResultText())
+}
+
+func TestDecodeStream_ForwardNonJSON(t *testing.T) {
+	input := "INFO starting\n{\"type\":\"result\",\"result\":\"done\"}\nINFO bye"
+
+	got := decodeAll(input, RunOptions{ForwardNonJSON: true})
+	require.Len(t, got, 3)
+	require.Equal(t, Event{Type: "stdout", Data: map[string]any{"line": "INFO starting"}}, got[0])
+	require.Equal(t, "result", got[1].Type)
+	require.Equal(t, "INFO bye", got[2].Data["line"])
+}
+
+func TestRunCommand_ContextCancelKillsAgent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := fakeProcess(`echo '{"type":"system","session_id":"s1"}'; sleep 10`)
+
+	events, errc := RunCommand(cmd, RunOptions{Context: ctx})
+	first := <-events
+	require.Equal(t, "s1", first.SessionID())
+	cancel()
+
+	start := time.Now()
+	_, err := collect(events, errc)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestDecodeStream_PartialMessageEvents(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"stream_event","session_id":"s1","event":{"type":"message_start","message":{"model":"claude-sonnet-4-5"}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"a\""}}}`,
+		`{"type":"assistant","session_id":"s1","message":{"content":[{"type":"text","text":"Hello"}]}}`,
+	}, "\n")
+
+	got := decodeAll(input, RunOptions{})
+	require.Len(t, got, 4)
+	for _, e := range got[:3] {
+		require.True(t, e.IsPartial())
+		require.Equal(t, "s1", e.SessionID())
+		require.Empty(t, e.TextContent())
+		require.Empty(t, e.ToolUses())
+		require.False(t, e.IsResult())
+	}
+	require.Empty(t, got[0].PartialText())
+	require.Equal(t, "Hel", got[1].PartialText())
+	require.Empty(t, got[2].PartialText())
+	require.False(t, got[3].IsPartial())
+	require.Equal(t, "Hello", got[3].TextContent())
+}
+
+func TestDecodeStream_JSONFormat(t *testing.T) {
+	opts := RunOptions{Config: config.Config{Claude: config.ClaudeConfig{OutputFormat: config.OutputFormatJSON}}}
+
+	got := decodeAll(`[{"type":"system","session_id":"s1"},`+"\n"+`{"type":"result","result":"done"}]`, opts)
+	require.Len(t, got, 2)
+	require.Equal(t, "s1", got[0].SessionID())
+	require.Equal(t, "done", got[1].ResultText())
+
+	single := decodeAll(`{"type":"result","result":"done"}`, opts)
+	require.Len(t, single, 1)
+	require.Equal(t, "done", single[0].ResultText())
+}
+
+func TestDecodeStream_JSONFormatReportsInvalidDocument(t *testing.T) {
+	opts := RunOptions{Config: config.Config{Claude: config.ClaudeConfig{OutputFormat: config.OutputFormatJSON}}}
+
+	got := decodeAll("INFO starting\n", opts)
+	require.Len(t, got, 1)
+	count, _ := got[0].DecodeErrors()
+	require.Equal(t, 1, count)
+}
+
+func TestDecodeStream_UnsupportedFormat(t *testing.T) {
+	opts := RunOptions{Config: config.Config{Claude: config.ClaudeConfig{OutputFormat: "xml"}}}
+	err := decodeStream(strings.NewReader("plan"), opts, make(chan Event, 1), nil)
+	require.EqualError(t, err, `unsupported output format "xml"`)
+}
+
+func TestRunCommand_TextOutputFormatWrapsStdout(t *testing.T) {
+	cmd := fakeProcess(`printf '## Plan\n\n1. Add {"auth": true} to config\n\n<!--FINISHED-->\n'`)
+	opts := RunOptions{Config: config.Config{Claude: config.ClaudeConfig{OutputFormat: config.OutputFormatText}}, PinnedSessionID: "s1"}
+
+	got, err := collect(RunCommand(cmd, opts))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.True(t, got[0].IsResult())
+	require.False(t, got[0].IsError())
+	require.Equal(t, "## Plan\n\n1. Add {\"auth\": true} to config\n\n<!--FINISHED-->", got[0].ResultText())
+	require.Equal(t, true, got[0].Data["text_output"])
+	require.Equal(t, "s1", got[0].SessionID())
+	require.Nil(t, got[0].Raw)
+}
+
+func TestRunCommand_PlainTextStdoutFallsBackToTextResult(t *testing.T) {
+	cmd := fakeProcess(`echo 'Here is the plan:'; echo '{not json'; echo '1. Do it'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{}))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	count, _ := got[0].DecodeErrors()
+	require.Equal(t, 1, count, "the malformed line is still reported")
+	require.Equal(t, "Here is the plan:\n{not json\n1. Do it", got[1].ResultText())
+	require.Equal(t, true, got[1].Data["text_output"])
+}
+
+func TestRunCommand_NoTextFallbackAfterFailedExit(t *testing.T) {
+	cmd := fakeProcess(`echo 'Segmentation fault, plan half written'; exit 3`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{}))
+	var runErr *ErrRunFailed
+	require.True(t, errors.As(err, &runErr), "got %v", err)
+	require.Equal(t, 3, runErr.ExitCode)
+	require.Empty(t, got)
+}
+
+func TestRunCommand_NoTextFallbackAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := fakeProcess(`echo 'partial plan'; sleep 10`)
+
+	events, errc := RunCommand(cmd, RunOptions{Context: ctx})
+	time.AfterFunc(200*time.Millisecond, cancel)
+	got, err := collect(events, errc)
+	require.ErrorIs(t, err, context.Canceled)
+	for _, e := range got {
+		require.False(t, e.IsResult())
+	}
+}
+
+func TestRunCommand_TextFallbackSkipsForwardedLines(t *testing.T) {
+	cmd := fakeProcess(`echo 'INFO starting'; echo '  '; echo '{not json'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{ForwardNonJSON: true}))
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	for i, want := range []string{"stdout", "stdout", "diagnostics", "result"} {
+		require.Equal(t, want, got[i].Type)
+	}
+	require.Equal(t, "{not json", got[3].ResultText())
+}
+
+func TestRunCommand_OverlongStdoutLineFailsRun(t *testing.T) {
+	cmd := fakeProcess(`echo 'Plan:'; head -c 1100000 /dev/zero | tr '\0' x; echo; echo '1. Do it'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{}))
+	require.ErrorIs(t, err, bufio.ErrTooLong)
+	require.Empty(t, got, "the truncated stream is not passed off as a result")
+}
+
+func TestRunCommand_OverlongStderrLineFailsRun(t *testing.T) {
+	cmd := fakeProcess(`head -c 1100000 /dev/zero | tr '\0' x >&2; echo >&2; echo '{"type":"result","result":"done"}'`)
+
+	_, err := collect(RunCommand(cmd, RunOptions{}))
+	require.ErrorIs(t, err, bufio.ErrTooLong)
+}
+
+func TestRunCommand_NoFallbackOnceJSONDecodes(t *testing.T) {
+	cmd := fakeProcess(`echo 'banner'; echo '{"type":"system","session_id":"s1"}'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{}))
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "system", got[0].Type)
+}
+
+func TestRunCommand_SynthesizesMissingResult(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"system","session_id":"s1"}'; echo '{"type":"assistant","message":{"content":[{"type":"text","text":"## Plan"}]}}'; echo '{"type":"assistant","message":{"content":[{"type":"text","text":"<!--FINISHED-->"}]}}'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{}))
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+
+	last := got[3]
+	require.True(t, last.IsResult())
+	require.True(t, last.IsSynthesized())
+	require.False(t, last.IsError())
+	require.Equal(t, "## Plan\n<!--FINISHED-->", last.ResultText())
+	require.Equal(t, "s1", last.SessionID())
+	require.Equal(t, RunStatusSuccess, FinalStatus(got, err))
+}
+
+func TestRunCommand_DoesNotSynthesizeWhenResultPresentOrExitFails(t *testing.T) {
+	got, err := collect(RunCommand(fakeProcess(`echo '{"type":"assistant","message":{"content":[{"type":"text","text":"hi"}]}}'; echo '{"type":"result","result":"done"}'`), RunOptions{}))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.False(t, got[1].IsSynthesized())
+
+	got, err = collect(RunCommand(fakeProcess(`echo '{"type":"assistant","message":{"content":[{"type":"text","text":"hi"}]}}'; exit 1`), RunOptions{}))
+	require.Error(t, err)
+	require.Len(t, got, 1)
+}
+
+func TestRunCommand_PinnedSessionIDStampsSynthesizedEvents(t *testing.T) {
+	const pinned = "0b6f7c1e-4d2a-4f7e-9c3b-5a8d2e1f0c9a"
+	cmd := fakeProcess(`echo 'warming up' >&2; echo 'log line'; echo '{bad'; echo '{"type":"assistant","message":{"content":[{"type":"text","text":"## Plan"}]}}'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{PinnedSessionID: pinned, ForwardNonJSON: true}))
+	require.NoError(t, err)
+	byType := map[string]Event{}
+	for _, e := range got {
+		byType[e.Type] = e
+	}
+	for _, typ := range []string{"stderr", "stdout", "diagnostics", "result"} {
+		require.Contains(t, byType, typ)
+		require.Equal(t, pinned, byType[typ].SessionID(), typ)
+	}
+	require.True(t, byType["result"].IsSynthesized())
+	require.Empty(t, byType["assistant"].SessionID(), "decoded agent events are left as emitted")
+
+	warnings := UnsupportedWarnings(RunOptions{PinnedSessionID: pinned, TextOnly: true}, Capabilities{})
+	require.Equal(t, pinned, warnings[0].SessionID())
+}
+
+func TestRunCommand_UnpinnedKeepsAgentSessionID(t *testing.T) {
+	got, err := collect(RunCommand(fakeProcess(`echo 'warming up' >&2; echo '{"type":"assistant","session_id":"agent-1","message":{"content":[{"type":"text","text":"hi"}]}}'`), RunOptions{}))
+	require.NoError(t, err)
+	for _, e := range got {
+		switch e.Type {
+		case "stderr":
+			require.Empty(t, e.SessionID())
+		case "result":
+			require.Equal(t, "agent-1", e.SessionID())
+		}
+	}
+}
+
+// endlessLines is a reader producing the same line forever, counting the bytes
+// it has handed out.
+type endlessLines struct {
+	line []byte
+	read atomic.Int64
+	stop atomic.Bool
+}
+
+func (r *endlessLines) Read(p []byte) (int, error) {
+	if r.stop.Load() {
+		return 0, io.EOF
+	}
+	n := 0
+	for n+len(r.line) <= len(p) {
+		n += copy(p[n:], r.line)
+	}
+	r.read.Add(int64(n))
+	return n, nil
+}
+
+func TestDecodeStream_SlowConsumerAppliesBackpressure(t *testing.T) {
+	src := &endlessLines{line: []byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"chunk"}]}}` + "\n")}
+	events := make(chan Event, 8)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = decodeStream(src, RunOptions{}, events, nil)
+		close(events)
+	}()
+
+	// Nobody reads events: the decoder fills the channel buffer and then
+	// blocks instead of reading on.
+	require.Eventually(t, func() bool { return len(events) == cap(events) }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	stalled := src.read.Load()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, stalled, src.read.Load(), "reads must stop while the consumer is stalled")
+	require.LessOrEqual(t, stalled, int64(maxLineBytes+(cap(events)+2)*len(src.line)))
+
+	// The line buffer holds thousands of lines; draining them lets the
+	// decoder read again.
+	for i := 0; src.read.Load() == stalled && i < 100000; i++ {
+		<-events
+	}
+	require.Greater(t, src.read.Load(), stalled, "reading resumes once the consumer catches up")
+
+	src.stop.Store(true)
+	for range events {
+	}
+	<-done
+}
+
+func TestDecodeStream_RawMatchesInputLine(t *testing.T) {
+	lines := []string{
+		`{"type":"system","session_id":"s1"}`,
+		`  {"type": "assistant", "message": {"content": [{"type": "text", "text": "café"}]}, "extra": 1.50}`,
+		`{"type":"result","result":"done"}`,
+	}
+
+	got := decodeAll(strings.Join(lines, "\n"), RunOptions{})
+	require.Len(t, got, 3)
+	for i, e := range got {
+		require.Equal(t, lines[i], string(e.Raw))
+	}
+	require.Equal(t, "café", got[1].TextContent(), "accessors still read the decoded data")
+
+	encoded, err := json.Marshal(got[0])
+	require.NoError(t, err)
+	require.NotContains(t, string(encoded), "Raw")
+}
+
+func TestDecodeStream_JSONFormatRawPerItem(t *testing.T) {
+	opts := RunOptions{Config: config.Config{Claude: config.ClaudeConfig{OutputFormat: config.OutputFormatJSON}}}
+
+	got := decodeAll(`[{"type":"system","session_id":"s1"}, {"type": "result", "result": "done"}]`, opts)
+	require.Len(t, got, 2)
+	require.Equal(t, `{"type":"system","session_id":"s1"}`, string(got[0].Raw))
+	require.Equal(t, `{"type": "result", "result": "done"}`, string(got[1].Raw))
+}
+
+func TestDecodeStream_DebugRoutesNonJSONToStderr(t *testing.T) {
+	got := decodeAll("[DEBUG] hook fired\n{\"type\":\"result\",\"result\":\"done\"}", RunOptions{Debug: true})
+	require.Len(t, got, 2)
+	require.Equal(t, Event{Type: "stderr", Data: map[string]any{"line": "[DEBUG] hook fired"}}, got[0])
+
+	forwarded := decodeAll("[DEBUG] hook fired", RunOptions{Debug: true, ForwardNonJSON: true})
+	require.Equal(t, "stdout", forwarded[0].Type, "ForwardNonJSON takes precedence")
+}
+
+func TestDecodeStream_FieldMapRemapsKeys(t *testing.T) {
+	lines := []string{
+		`{"kind":"system","sid":"s1"}`,
+		`{"kind":"assistant","msg":{"content":[{"kind":"text","text":"hello"},{"kind":"tool_use","id":"t1","name":"Read","input":{}}]}}`,
+		`{"kind":"result","result":"done","sid":"s1"}`,
+	}
+	opts := RunOptions{FieldMap: map[string]string{"kind": "type", "msg": "message", "sid": "session_id"}}
+
+	got := decodeAll(strings.Join(lines, "\n"), opts)
+	require.Len(t, got, 3)
+	require.Equal(t, "system", got[0].Type)
+	require.Equal(t, "s1", got[0].SessionID())
+	require.Equal(t, "hello", got[1].TextContent())
+	require.Len(t, got[1].ToolUses(), 1)
+	require.True(t, got[2].IsResult())
+	require.Equal(t, "done", got[2].ResultText())
+	require.Equal(t, lines[1], string(got[1].Raw), "Raw keeps the agent's own keys")
+}
+
+func TestDecodeStream_FieldMapFromConfig(t *testing.T) {
+	opts := RunOptions{
+		Config: config.Config{Claude: config.ClaudeConfig{
+			OutputFormat: config.OutputFormatJSON,
+			FieldMap:     map[string]string{"kind": "type", "output": "text"},
+		}},
+		FieldMap: map[string]string{"output": "result"},
+	}
+
+	got := decodeAll(`[{"kind":"result","output":"done"}]`, opts)
+	require.Len(t, got, 1)
+	require.Equal(t, "done", got[0].ResultText(), "RunOptions.FieldMap overrides the configured entry")
+}
+
+func TestRemapFields_ReplacesAndSwaps(t *testing.T) {
+	data := map[string]any{"type": "stale", "kind": "result", "a": 1, "b": 2}
+
+	remapFields(data, map[string]string{"kind": "type", "a": "b", "b": "a"})
+
+	require.Equal(t, map[string]any{"type": "result", "a": 2, "b": 1}, data)
+}
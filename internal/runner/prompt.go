@@ -0,0 +1,197 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jumppad-labs/spektacular/internal/git"
+)
+
+// BuildPrompt assembles the planner's user prompt: knowledge hint + spec content.
+func BuildPrompt(specContent string) string {
+	return BuildPromptWithHeader(specContent, "Specification to Plan")
+}
+
+// BuildPromptWithHeader assembles the user prompt with a custom content section header.
+func BuildPromptWithHeader(content, header string) string {
+	return fmt.Sprintf(PromptWithHeader, header, content)
+}
+
+// BuildPromptWithHeaderFooter is BuildPromptWithHeader followed by a delimited footer
+// instruction, using the PromptFooter template. An empty footer yields exactly
+// BuildPromptWithHeader's output.
+func BuildPromptWithHeaderFooter(content, header, footer string) string {
+	return BuildPromptWithOptions(content, header, PromptOptions{Footer: footer})
+}
+
+// PromptOptions holds the optional parts of a user prompt.
+type PromptOptions struct {
+	// Footer is appended with the PromptFooter template when non-empty.
+	Footer string
+	// KnowledgeHints are listed after the hint pointing at KnowledgeDir, e.g. org-wide sources plus those named in a spec's frontmatter.
+	// Blank and repeated hints are dropped; the first occurrence keeps its place.
+	KnowledgeHints []string
+	// MaxTokens caps the prompt's estimated size (see EstimateTokens). A
+	// prompt over the cap has its content truncated, with a marker noting the
+	// cut. Zero means no cap.
+	MaxTokens int
+	// PreferSpec, with MaxTokens, drops the KnowledgeHints before truncating
+	// any content, leaving a marker in their place, so a large spec keeps as
+	// much of itself as possible.
+	PreferSpec bool
+	// IncludeGitContext prepends the summary of git.GitContext for RepoDir
+	// to the content, so the agent knows the branch, recent commits and
+	// uncommitted changes it is planning against. Outside a git repository,
+	// or if git cannot be run, nothing is added.
+	IncludeGitContext bool
+	// RepoDir is the repository IncludeGitContext reads. Empty uses the
+	// current directory.
+	RepoDir string
+	// KnowledgeDir replaces DefaultKnowledgeDir in the knowledge hint, for
+	// projects that keep their knowledge elsewhere. Empty uses the default.
+	KnowledgeDir string
+	// Locale selects the language of the prompt's fixed text, such as the
+	// knowledge hint and the default header labels, e.g. "de" or "es-MX".
+	// Empty or unknown locales use English. User content is never translated.
+	Locale string
+}
+
+const (
+	// knowledgeOmittedMarker replaces KnowledgeHints dropped to fit MaxTokens.
+	knowledgeOmittedMarker = "(Additional knowledge sources were omitted to fit the prompt size limit.)"
+	// contentTruncatedMarker ends content cut to fit MaxTokens. Args: the
+	// number of characters removed.
+	contentTruncatedMarker = "\n\n[... truncated: %d characters omitted to fit the prompt size limit ...]"
+)
+
+// BuildPromptWithOptions assembles the user prompt with a custom content section
+// header and the optional parts in opts. Zero options yield exactly
+// BuildPromptWithHeader's output.
+func BuildPromptWithOptions(content, header string, opts PromptOptions) string {
+	text := localeStrings(opts.Locale).withKnowledgeDir(opts.KnowledgeDir)
+	if opts.IncludeGitContext {
+		if gitContext, err := git.GitContext(opts.RepoDir); err == nil && gitContext != "" {
+			content = gitContext + "\n" + content
+		}
+	}
+	hints := dedupeHints(opts.KnowledgeHints)
+	prompt := assemblePrompt(text, content, header, opts.Footer, hints, "")
+	if opts.MaxTokens <= 0 || EstimateTokens(prompt) <= opts.MaxTokens {
+		return prompt
+	}
+
+	hintNote := ""
+	if opts.PreferSpec && len(hints) > 0 {
+		hints, hintNote = nil, text.knowledgeOmitted
+		prompt = assemblePrompt(text, content, header, opts.Footer, nil, hintNote)
+		if EstimateTokens(prompt) <= opts.MaxTokens {
+			return prompt
+		}
+	}
+
+	// Everything but the content is kept, so the content gets whatever room
+	// the rest of the prompt and the marker leave.
+	overhead := len(prompt) - len(content) + len(fmt.Sprintf(text.truncated, len(content)))
+	keep := max(opts.MaxTokens*charsPerToken-overhead, 0)
+	for keep > 0 && !utf8.RuneStart(content[keep]) {
+		keep--
+	}
+	truncated := content[:keep] + fmt.Sprintf(text.truncated, len(content)-keep)
+	return assemblePrompt(text, truncated, header, opts.Footer, hints, hintNote)
+}
+
+// assemblePrompt renders the user prompt in text's language. hints are listed
+// after the default knowledge hint, or note is placed there when there are
+// none.
+func assemblePrompt(text promptStrings, content, header, footer string, hints []string, note string) string {
+	prompt := expandKnowledgeHint(fmt.Sprintf(text.withHeader, text.header(header), content), text, hints, note)
+	if footer != "" {
+		prompt += fmt.Sprintf(text.footer, strings.TrimSpace(footer))
+	}
+	return prompt
+}
+
+// expandKnowledgeHint lists hints after text's knowledge hint in prompt, or
+// places note there when there are none.
+func expandKnowledgeHint(prompt string, text promptStrings, hints []string, note string) string {
+	switch {
+	case len(hints) > 0:
+		return strings.Replace(prompt, text.knowledgeHint, text.knowledgeHint+"\n\n"+text.extraSources+"\n- "+strings.Join(hints, "\n- "), 1)
+	case note != "":
+		return strings.Replace(prompt, text.knowledgeHint, text.knowledgeHint+"\n\n"+note, 1)
+	}
+	return prompt
+}
+
+// withKnowledge points the English knowledge hint opening prompt, a rendered
+// template built on knowledgeHint, at opts.KnowledgeDir and lists
+// opts.KnowledgeHints after it.
+func withKnowledge(prompt string, opts PromptOptions) string {
+	text := localeStrings("").withKnowledgeDir(opts.KnowledgeDir)
+	if opts.KnowledgeDir != "" {
+		prompt = strings.Replace(prompt, knowledgeHint, text.knowledgeHint, 1)
+	}
+	return expandKnowledgeHint(prompt, text, dedupeHints(opts.KnowledgeHints), "")
+}
+
+// withKnowledgeDir returns text with its knowledge hint pointing at dir
+// rather than DefaultKnowledgeDir. dir gains a trailing slash like the
+// default's; an empty dir leaves text unchanged.
+func (s promptStrings) withKnowledgeDir(dir string) promptStrings {
+	if dir == "" {
+		return s
+	}
+	quoted := "'" + strings.TrimSuffix(dir, "/") + "/'"
+	s.knowledgeHint = strings.ReplaceAll(s.knowledgeHint, "'"+DefaultKnowledgeDir+"'", quoted)
+	s.withHeader = strings.ReplaceAll(s.withHeader, "'"+DefaultKnowledgeDir+"'", quoted)
+	return s
+}
+
+// dedupeHints trims hints and drops blank and repeated ones, keeping the
+// first occurrence of each in order.
+func dedupeHints(hints []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, h := range hints {
+		h = strings.TrimSpace(h)
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		out = append(out, h)
+	}
+	return out
+}
+
+var promptRegistry = map[string]func(spec string) string{}
+
+// RegisterPrompt adds a named prompt builder. Registering an existing name
+// replaces its builder.
+func RegisterPrompt(name string, fn func(spec string) string) {
+	promptRegistry[name] = fn
+}
+
+// BuildPromptNamed builds the prompt for spec with the builder registered
+// under name.
+func BuildPromptNamed(name, spec string) (string, error) {
+	fn, ok := promptRegistry[name]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt profile: %q (available: %v)", name, registeredPromptNames())
+	}
+	return fn(spec), nil
+}
+
+func registeredPromptNames() []string {
+	names := make([]string, 0, len(promptRegistry))
+	for k := range promptRegistry {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterPrompt("plan", BuildPrompt)
+}
@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildPrompt_ContainsSpecAndKnowledgeHint(t *testing.T) {
+	prompt := BuildPrompt("my spec")
+	require.Contains(t, prompt, "# Specification to Plan\n\nmy spec")
+	require.Contains(t, prompt, ".spektacular/knowledge/")
+}
+
+func TestBuildPromptWithHeader_UsesCustomHeader(t *testing.T) {
+	prompt := BuildPromptWithHeader("plan content", "Implementation Plan")
+	require.Contains(t, prompt, "# Implementation Plan\n\nplan content")
+	require.NotContains(t, prompt, "Specification to Plan")
+}
+
+func TestBuildPromptWithHeaderFooter_AppendsDelimitedFooter(t *testing.T) {
+	prompt := BuildPromptWithHeaderFooter("plan content", "Implementation Plan", "- [ ] Tests added\n")
+	require.True(t, strings.HasPrefix(prompt, BuildPromptWithHeader("plan content", "Implementation Plan")))
+	require.True(t, strings.HasSuffix(prompt, "<required-footer>\n- [ ] Tests added\n</required-footer>"))
+	require.Less(t, strings.Index(prompt, "plan content"), strings.Index(prompt, "# Required Footer"))
+}
+
+func TestBuildPromptWithHeaderFooter_EmptyFooterMatchesHeaderOnly(t *testing.T) {
+	require.Equal(t,
+		BuildPromptWithHeader("plan content", "Implementation Plan"),
+		BuildPromptWithHeaderFooter("plan content", "Implementation Plan", ""))
+}
+
+func TestBuildPromptNamed_DefaultPlanProfile(t *testing.T) {
+	prompt, err := BuildPromptNamed("plan", "my spec")
+	require.NoError(t, err)
+	require.Equal(t, BuildPrompt("my spec"), prompt)
+}
+
+func TestBuildPromptNamed_RegisteredProfile(t *testing.T) {
+	RegisterPrompt("review", func(spec string) string { return BuildPromptWithHeader(spec, "Specification to Review") })
+	defer delete(promptRegistry, "review")
+
+	prompt, err := BuildPromptNamed("review", "my spec")
+	require.NoError(t, err)
+	require.Contains(t, prompt, "# Specification to Review\n\nmy spec")
+}
+
+func TestBuildPromptNamed_UnknownName(t *testing.T) {
+	prompt, err := BuildPromptNamed("refactor", "my spec")
+	require.Error(t, err)
+	require.Empty(t, prompt)
+	require.Contains(t, err.Error(), `unknown prompt profile: "refactor"`)
+	require.Contains(t, err.Error(), "plan")
+}
+
+func TestBuildPromptWithOptions_NoHintsMatchesHeaderOnly(t *testing.T) {
+	require.Equal(t,
+		BuildPromptWithHeader("plan content", "Implementation Plan"),
+		BuildPromptWithOptions("plan content", "Implementation Plan", PromptOptions{KnowledgeHints: []string{}}))
+}
+
+func TestBuildPromptWithOptions_AppendsDedupedHintsInOrder(t *testing.T) {
+	prompt := BuildPromptWithOptions("my spec", "Specification to Plan", PromptOptions{
+		KnowledgeHints: []string{"docs/adr/", "https://wiki.example.com/eng", " docs/adr/ ", "", "specs/auth/notes.md", "https://wiki.example.com/eng"},
+	})
+
+	require.True(t, strings.HasPrefix(prompt, knowledgeHint+"\n\nAlso consult these knowledge sources:\n- docs/adr/\n- https://wiki.example.com/eng\n- specs/auth/notes.md\n\n---"))
+	require.Equal(t, 1, strings.Count(prompt, "docs/adr/"))
+	require.Contains(t, prompt, "# Specification to Plan\n\nmy spec")
+}
+
+func TestBuildPromptWithOptions_PreferSpecDropsKnowledgeFirst(t *testing.T) {
+	spec := strings.Repeat("requirement. ", 300)
+	hints := []string{strings.Repeat("docs/very/long/knowledge/path/", 40)}
+	opts := PromptOptions{KnowledgeHints: hints, MaxTokens: EstimateTokens(BuildPromptWithHeader(spec, "Spec")) + 20, PreferSpec: true}
+
+	prompt := BuildPromptWithOptions(spec, "Spec", opts)
+	require.LessOrEqual(t, EstimateTokens(prompt), opts.MaxTokens)
+	require.Contains(t, prompt, knowledgeOmittedMarker)
+	require.NotContains(t, prompt, hints[0])
+	require.Contains(t, prompt, "# Spec\n\n"+spec, "the spec is kept whole")
+	require.NotContains(t, prompt, "truncated")
+}
+
+func TestBuildPromptWithOptions_TruncatesEnormousSpec(t *testing.T) {
+	spec := strings.Repeat("é requirement. ", 2000)
+	opts := PromptOptions{KnowledgeHints: []string{"docs/adr/"}, MaxTokens: 500, PreferSpec: true, Footer: "- [ ] done"}
+
+	prompt := BuildPromptWithOptions(spec, "Spec", opts)
+	require.LessOrEqual(t, EstimateTokens(prompt), opts.MaxTokens)
+	require.True(t, utf8.ValidString(prompt))
+	require.Contains(t, prompt, knowledgeOmittedMarker)
+	require.Regexp(t, `\[\.\.\. truncated: \d+ characters omitted to fit the prompt size limit \.\.\.\]`, prompt)
+	require.True(t, strings.HasSuffix(prompt, "<required-footer>\n- [ ] done\n</required-footer>"), "the footer survives truncation")
+}
+
+func TestBuildPromptWithOptions_WithinLimitUnchanged(t *testing.T) {
+	opts := PromptOptions{KnowledgeHints: []string{"docs/adr/"}}
+	want := BuildPromptWithOptions("small spec", "Spec", opts)
+	opts.MaxTokens, opts.PreferSpec = 10000, true
+	require.Equal(t, want, BuildPromptWithOptions("small spec", "Spec", opts))
+}
+
+func TestBuildPromptWithOptions_EnglishLocale(t *testing.T) {
+	opts := PromptOptions{KnowledgeHints: []string{"docs/adr/"}, Footer: "- [ ] done"}
+	want := BuildPromptWithOptions("my spec", "Specification to Plan", opts)
+	opts.Locale = "en-GB"
+	require.Equal(t, want, BuildPromptWithOptions("my spec", "Specification to Plan", opts))
+}
+
+func TestBuildPromptWithOptions_TranslatesFixedText(t *testing.T) {
+	prompt := BuildPromptWithOptions("Specification to Plan: my spec", "Specification to Plan", PromptOptions{
+		Locale:         "de_AT",
+		KnowledgeHints: []string{"docs/adr/"},
+		Footer:         "- [ ] Tests added",
+	})
+
+	require.True(t, strings.HasPrefix(prompt, promptLocales["de"].knowledgeHint+"\n\nZiehen Sie außerdem diese Wissensquellen heran:\n- docs/adr/"))
+	require.Contains(t, prompt, "# Zu planende Spezifikation\n\nSpecification to Plan: my spec", "the spec itself is not translated")
+	require.Contains(t, prompt, "# Erforderlicher Abschluss")
+	require.Contains(t, prompt, "<required-footer>\n- [ ] Tests added\n</required-footer>")
+	require.NotContains(t, prompt, knowledgeHint)
+
+	custom := BuildPromptWithOptions("plan", "Implementation Plan", PromptOptions{Locale: "es"})
+	require.Contains(t, custom, "# Implementation Plan\n\nplan", "caller-provided headers are kept")
+}
+
+func TestBuildPromptWithOptions_CustomKnowledgeDir(t *testing.T) {
+	prompt := BuildPromptWithOptions("my spec", "Specification to Plan", PromptOptions{
+		KnowledgeDir:   "docs/knowledge",
+		KnowledgeHints: []string{"docs/adr/"},
+	})
+	require.Contains(t, prompt, "can be found in 'docs/knowledge/'.")
+	require.Contains(t, prompt, "\n\nAlso consult these knowledge sources:\n- docs/adr/")
+	require.NotContains(t, prompt, DefaultKnowledgeDir)
+
+	german := BuildPromptWithOptions("my spec", "Specification to Plan", PromptOptions{KnowledgeDir: "docs/knowledge/", Locale: "de"})
+	require.Contains(t, german, "finden Sie in 'docs/knowledge/'.")
+
+	require.Equal(t,
+		BuildPromptWithHeader("my spec", "Specification to Plan"),
+		BuildPromptWithOptions("my spec", "Specification to Plan", PromptOptions{KnowledgeDir: DefaultKnowledgeDir}))
+}
+
+func TestBuildPromptWithOptions_UnknownLocaleFallsBackToEnglish(t *testing.T) {
+	require.Equal(t,
+		BuildPromptWithHeader("my spec", "Specification to Plan"),
+		BuildPromptWithOptions("my spec", "Specification to Plan", PromptOptions{Locale: "tlh"}))
+}
+
+func TestBuildPromptWithOptions_IncludeGitContext(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "--quiet", "--initial-branch=plans/auth"},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "--quiet", "--allow-empty", "-m", "Add login form"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	prompt := BuildPromptWithOptions("# Feature: auth", "Specification to Plan", PromptOptions{IncludeGitContext: true, RepoDir: dir})
+	require.Contains(t, prompt, "## Git Context\n\nBranch: plans/auth\n\nRecent commits:\n- Add login form\n")
+	require.Less(t, strings.Index(prompt, "## Git Context"), strings.Index(prompt, "# Feature: auth"), "the git context precedes the spec")
+
+	outside := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(outside))
+	require.Equal(t, BuildPrompt("# Feature: auth"),
+		BuildPromptWithOptions("# Feature: auth", "Specification to Plan", PromptOptions{IncludeGitContext: true, RepoDir: outside}))
+}
@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultQuestionPrefix is the marker prefix of the built-in question
+// protocol, as in <!--QUESTION:{...}-->.
+const DefaultQuestionPrefix = "QUESTION"
+
+// questionSpan returns a pattern matching a marker with any of prefixes
+// together with the horizontal whitespace around it, so removing it does not
+// leave a double space.
+func questionSpan(prefixes []string) *regexp.Regexp {
+	quoted := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		quoted[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile(`[ \t]*<!--(?:` + strings.Join(quoted, "|") + `):[\s\S]*?-->[ \t]*`)
+}
+
+var defaultQuestionSpan = questionSpan([]string{DefaultQuestionPrefix})
+
+var (
+	trailingBlanks = regexp.MustCompile(`[ \t]+\n`)
+	blankLineRuns  = regexp.MustCompile(`\n{3,}`)
+)
+
+// StripQuestionMarkers removes every <!--QUESTION:...--> marker from text,
+// and every marker with one of the custom prefixes configured for an agent
+// that asks its questions under another name, such as "ASK" for
+// <!--ASK:...-->. Markers whose JSON payload spans several lines are removed
+// whole, and the whitespace the markers leave behind is tidied. Other markers
+// and all surrounding text are kept; use StripMarkers to remove FINISHED and
+// GOTO markers as well.
+func StripQuestionMarkers(text string, prefixes ...string) string {
+	span := defaultQuestionSpan
+	if len(prefixes) > 0 {
+		span = questionSpan(append([]string{DefaultQuestionPrefix}, prefixes...))
+	}
+	if !span.MatchString(text) {
+		return text
+	}
+	text = span.ReplaceAllString(text, " ")
+	text = trailingBlanks.ReplaceAllString(text, "\n")
+	text = blankLineRuns.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// OptionLabels returns the label of each of q's options, in order. Options
+// without a string label yield an empty entry.
+func (q Question) OptionLabels() []string {
+	labels := make([]string, len(q.Options))
+	for i, opt := range q.Options {
+		labels[i], _ = opt["label"].(string)
+	}
+	return labels
+}
+
+// CheckAnswer reports whether answer is acceptable for q: it returns an
+// *ErrAnswerMismatch if q has a Pattern the answer does not match in full.
+// SkipAnswer is always accepted, as is any answer to a question without a
+// Pattern.
+func (q Question) CheckAnswer(answer string) error {
+	if q.Pattern == "" || answer == SkipAnswer {
+		return nil
+	}
+	re, err := compileAnswerPattern(q.Pattern)
+	if err != nil {
+		return fmt.Errorf("question %q: invalid pattern: %w", q.Header, err)
+	}
+	if !re.MatchString(answer) {
+		return &ErrAnswerMismatch{Header: q.Header, Answer: answer, Pattern: q.Pattern}
+	}
+	return nil
+}
+
+// compileAnswerPattern compiles pattern anchored at both ends, so it must
+// match a whole answer.
+func compileAnswerPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// ValidateQuestion checks q against the QUESTION marker invariants: the
+// question text is non-empty, a pattern, when present, compiles, a choice
+// question offers at least one option and every option has a label, and a
+// default, when present on a choice question, names one of its options.
+func ValidateQuestion(q Question) error {
+	if strings.TrimSpace(q.Question) == "" {
+		return fmt.Errorf("question %q: question text must not be empty", q.Header)
+	}
+	if q.Pattern != "" {
+		if _, err := compileAnswerPattern(q.Pattern); err != nil {
+			return fmt.Errorf("question %q: invalid pattern: %w", q.Header, err)
+		}
+	}
+	if q.Type != QuestionTypeChoice {
+		return nil
+	}
+	labels := q.OptionLabels()
+	if len(labels) == 0 {
+		return fmt.Errorf("question %q: choice question must have at least one option", q.Header)
+	}
+	for i, label := range labels {
+		if strings.TrimSpace(label) == "" {
+			return fmt.Errorf("question %q: option %d must have a label", q.Header, i)
+		}
+	}
+	if q.Default != "" && !slices.Contains(labels, q.Default) {
+		return fmt.Errorf("question %q: default %q does not match any option (options: %s)", q.Header, q.Default, strings.Join(labels, ", "))
+	}
+	return nil
+}
+
+// ValidateQuestions validates every question and returns all violations
+// joined, or nil when each question is valid.
+func ValidateQuestions(qs []Question) error {
+	var errs []error
+	for i, q := range qs {
+		if err := ValidateQuestion(q); err != nil {
+			errs = append(errs, fmt.Errorf("questions[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
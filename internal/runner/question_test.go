@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func choiceQuestion(def string, labels ...string) Question {
+	q := Question{Question: "Which approach?", Header: "Approach", Type: QuestionTypeChoice, Default: def}
+	for _, l := range labels {
+		q.Options = append(q.Options, map[string]any{"label": l})
+	}
+	return q
+}
+
+func TestValidateQuestion_Valid(t *testing.T) {
+	require.NoError(t, ValidateQuestion(choiceQuestion("B", "A", "B")))
+	require.NoError(t, ValidateQuestion(Question{Question: "Branch name?", Header: "Branch", Type: QuestionTypeText, Default: "main"}))
+}
+
+func TestValidateQuestion_EmptyText(t *testing.T) {
+	q := choiceQuestion("", "A")
+	q.Question = "  "
+	require.ErrorContains(t, ValidateQuestion(q), "question text must not be empty")
+}
+
+func TestValidateQuestion_ChoiceWithoutOptions(t *testing.T) {
+	require.ErrorContains(t, ValidateQuestion(choiceQuestion("")), "at least one option")
+}
+
+func TestValidateQuestion_OptionWithoutLabel(t *testing.T) {
+	q := choiceQuestion("", "A")
+	q.Options = append(q.Options, map[string]any{"description": "no label"})
+	require.ErrorContains(t, ValidateQuestion(q), "option 1 must have a label")
+}
+
+func TestValidateQuestion_DefaultNotAnOption(t *testing.T) {
+	err := ValidateQuestion(choiceQuestion("C", "A", "B"))
+	require.ErrorContains(t, err, `default "C" does not match any option`)
+}
+
+func TestValidateQuestions_CollectsEveryViolation(t *testing.T) {
+	err := ValidateQuestions([]Question{
+		choiceQuestion("A", "A"),
+		choiceQuestion(""),
+		{Header: "Empty"},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "questions[1]")
+	require.Contains(t, err.Error(), "questions[2]")
+	require.NotContains(t, err.Error(), "questions[0]")
+
+	require.NoError(t, ValidateQuestions([]Question{choiceQuestion("", "A")}))
+	require.NoError(t, ValidateQuestions(nil))
+}
+
+func TestDetectQuestions_ParsesDefault(t *testing.T) {
+	text := `<!--QUESTION:{"questions":[{"question":"Q?","header":"H","type":"choice","default":"B","options":[{"label":"A"},{"label":"B"}]}]}-->`
+	qs := detectQuestions(text)
+	require.Len(t, qs, 1)
+	require.Equal(t, "B", qs[0].Default)
+	require.NoError(t, ValidateQuestions(qs))
+}
+
+func TestDetectQuestions_ParsesPattern(t *testing.T) {
+	text := `<!--QUESTION:{"questions":[{"question":"Branch?","header":"Branch","pattern":"feature/[a-z-]+"},` +
+		`{"question":"Q?","header":"H","type":"choice","pattern":"x","options":[{"label":"A"}]}]}-->`
+	qs := detectQuestions(text)
+	require.Equal(t, "feature/[a-z-]+", qs[0].Pattern)
+	require.Empty(t, qs[1].Pattern, "patterns only apply to text questions")
+}
+
+// branchQuestion is a free-text question whose answer must be a feature
+// branch name.
+func branchQuestion() Question {
+	return Question{Question: "Which branch?", Header: "Branch", Type: QuestionTypeText, Pattern: `feature/[a-z-]+`}
+}
+
+func TestQuestion_CheckAnswer(t *testing.T) {
+	q := branchQuestion()
+	require.NoError(t, q.CheckAnswer("feature/auth-rollout"))
+	require.NoError(t, q.CheckAnswer(SkipAnswer))
+
+	err := q.CheckAnswer("main feature/auth")
+	var mismatch *ErrAnswerMismatch
+	require.ErrorAs(t, err, &mismatch, "the pattern must match the whole answer")
+	require.EqualError(t, err, `question "Branch": answer "main feature/auth" does not match the required pattern feature/[a-z-]+`)
+
+	require.NoError(t, Question{Question: "Name?", Header: "Name", Type: QuestionTypeText}.CheckAnswer("anything at all"))
+}
+
+func TestValidateQuestion_InvalidPattern(t *testing.T) {
+	q := branchQuestion()
+	q.Pattern = "feature/("
+	require.ErrorContains(t, ValidateQuestion(q), `question "Branch": invalid pattern`)
+	require.NoError(t, ValidateQuestion(branchQuestion()))
+}
+
+func TestDetectQuestions_DocumentOrder(t *testing.T) {
+	text := "Intro.\n" +
+		`<!--QUESTION:{"questions":[{"question":"Q1?","header":"H1"},{"question":"Q2?","header":"H2"}]}-->` +
+		"\nSome analysis between markers.\n" +
+		`<!--QUESTION:{"questions":[{"question":"Q3?","header":"H3"}]}-->` +
+		"\n<!--QUESTION:not json-->\nMore text.\n" +
+		`<!--QUESTION:{"questions":[{"question":"Q4?","header":"H4"},{"question":"Q5?","header":"H5"},{"question":"Q6?","header":"H6"}]}-->`
+
+	for range 10 {
+		var headers []string
+		for _, q := range detectQuestions(text) {
+			headers = append(headers, q.Header)
+		}
+		require.Equal(t, []string{"H1", "H2", "H3", "H4", "H5", "H6"}, headers)
+	}
+}
+
+func TestStripQuestionMarkers_Single(t *testing.T) {
+	text := `Before we start <!--QUESTION:{"questions":[{"question":"Q?","header":"H"}]}--> I need one answer.`
+	require.Equal(t, "Before we start I need one answer.", StripQuestionMarkers(text))
+}
+
+func TestStripQuestionMarkers_MultipleAndMultiline(t *testing.T) {
+	text := "Intro paragraph.\n\n<!--QUESTION:{\n  \"questions\": [\n    {\"question\": \"A?\", \"header\": \"A\"}\n  ]\n}-->\n\nMiddle.\n<!--QUESTION:{\"questions\":[{\"question\":\"B?\",\"header\":\"B\"}]}-->\n\n\nEnd. <!-- FINISHED -->"
+
+	got := StripQuestionMarkers(text)
+	require.Equal(t, "Intro paragraph.\n\nMiddle.\n\nEnd. <!-- FINISHED -->", got)
+	require.NotContains(t, got, "QUESTION")
+}
+
+func TestStripQuestionMarkers_CustomPrefix(t *testing.T) {
+	text := "Before we start <!--ASK:{\n\"questions\":[{\"question\":\"Q?\",\"header\":\"H\"}]\n}--> I need <!--QUESTION:{}-->one answer. <!--GOTO:{}-->"
+
+	require.Equal(t, "Before we start I need one answer. <!--GOTO:{}-->", StripQuestionMarkers(text, "ASK"))
+	require.Contains(t, StripQuestionMarkers(text), "<!--ASK:", "without the prefix only QUESTION markers go")
+	require.Equal(t, "a b", StripQuestionMarkers("a <!--A.K:x--> b", "A.K"), "prefixes match literally")
+	require.Equal(t, "a <!--ABK:x--> b", StripQuestionMarkers("a <!--ABK:x--> b", "A.K"))
+}
+
+func TestStripQuestionMarkers_NoMarker(t *testing.T) {
+	text := "  keep   this\n\n\n exactly  "
+	require.Equal(t, text, StripQuestionMarkers(text))
+}
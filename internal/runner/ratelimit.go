@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket pacing run launches to a provider's
+// requests-per-minute limit. Tokens accrue at RPM per minute up to Burst, and
+// each launch takes one. A RateLimiter is safe for concurrent use, so one
+// instance can be shared by every runner that draws on the same quota.
+type RateLimiter struct {
+	rpm   int
+	burst int
+
+	clock clock // nil uses the real clock
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time // when tokens was last refilled; zero before first use
+}
+
+// NewRateLimiter returns a RateLimiter allowing rpm launches per minute, with
+// bursts of up to burst launches. The bucket starts full. A burst below one
+// is treated as one; an rpm of zero or less means no limit.
+func NewRateLimiter(rpm, burst int) *RateLimiter {
+	burst = max(burst, 1)
+	return &RateLimiter{rpm: rpm, burst: burst, tokens: float64(burst)}
+}
+
+// Wait blocks until a token is available and takes it, or returns ctx's error
+// if ctx is done first.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l.rpm <= 0 {
+		return ctx.Err()
+	}
+	c := clockOrReal(l.clock)
+	for {
+		delay := l.reserve(c.Now())
+		if delay == 0 {
+			return nil
+		}
+		t := c.NewTimer(delay)
+		select {
+		case <-t.C():
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve takes a token if one is available at now and returns zero, or
+// returns how long until one will be.
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	interval := time.Minute / time.Duration(l.rpm)
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens = min(l.tokens+float64(now.Sub(l.last))/float64(interval), float64(l.burst))
+	}
+	if l.last.IsZero() || now.After(l.last) {
+		l.last = now
+	}
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return max(time.Duration((1-l.tokens)*float64(interval)), time.Nanosecond)
+}
+
+// RateLimited wraps a Runner so each run waits on Limiter before it is
+// launched. Cancelling the run's context while it waits ends it with the
+// context's error and without ever starting the inner runner.
+type RateLimited struct {
+	Inner   Runner
+	Limiter *RateLimiter
+}
+
+// NewRateLimited returns a RateLimited around inner drawing on limiter.
+func NewRateLimited(inner Runner, limiter *RateLimiter) *RateLimited {
+	return &RateLimited{Inner: inner, Limiter: limiter}
+}
+
+// Run waits for a token, then runs the inner runner, forwarding its events.
+func (r *RateLimited) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	events := make(chan Event, 64)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		if err := r.run(opts, events); err != nil {
+			errc <- err
+		}
+		close(errc)
+	}()
+
+	return events, errc
+}
+
+func (r *RateLimited) run(opts RunOptions, events chan<- Event) error {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := r.Limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("run cancelled waiting for rate limit: %w", err)
+	}
+	inner, innerErrc := r.Inner.Run(opts)
+	return Drain(context.Background(), inner, innerErrc, func(e Event) { events <- e })
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_PacesLaunchesUnderTightRPM(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(60, 2) // one token a second, bursts of two
+	l.clock = clock
+
+	done := make(chan time.Time, 4)
+	for range 4 {
+		go func() {
+			require.NoError(t, l.Wait(context.Background()))
+			done <- clock.Now()
+		}()
+	}
+	start := clock.Now()
+	require.Equal(t, start, <-done)
+	require.Equal(t, start, <-done, "the burst launches at once")
+
+	clock.BlockUntil(t, 2)
+	clock.Advance(999 * time.Millisecond)
+	require.Empty(t, done)
+	clock.Advance(time.Millisecond)
+	require.Equal(t, start.Add(time.Second), <-done)
+	require.Empty(t, done, "one token has accrued, for one launch")
+
+	clock.BlockUntil(t, 1)
+	clock.Advance(time.Second)
+	require.Equal(t, start.Add(2*time.Second), <-done)
+}
+
+func TestRateLimiter_RefillsUpToBurst(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(120, 3)
+	l.clock = clock
+	for range 3 {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+
+	clock.Advance(time.Hour)
+	for range 3 {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, l.Wait(ctx), context.Canceled, "an hour's idling refills no more than the burst")
+}
+
+func TestRateLimiter_ZeroRPMIsUnlimited(t *testing.T) {
+	l := NewRateLimiter(0, 0)
+	for range 100 {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+}
+
+func TestRateLimited_CancelWhileWaitingNeverLaunches(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	l.clock = newFakeClock()
+	r := NewRateLimited(shellRunner{}, l)
+
+	got, err := collect(r.Run(RunOptions{Prompts: Prompts{User: `echo '{"type":"result","result":"first"}'`}}))
+	require.NoError(t, err)
+	require.Equal(t, "first", got[0].ResultText())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errc := r.Run(RunOptions{Context: ctx, Prompts: Prompts{User: `echo '{"type":"result","result":"second"}'`}})
+	cancel()
+	got, err = collect(events, errc)
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorContains(t, err, "waiting for rate limit")
+	require.Empty(t, got)
+}
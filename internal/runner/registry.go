@@ -1,28 +1,54 @@
 package runner
 
 import (
-	"fmt"
 	"sort"
+	"sync"
 )
 
-var registry = map[string]func() Runner{}
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]func() Runner{}
+)
 
-// Register adds a runner constructor for a given command name.
-// It is typically called from an init() function in the runner's package.
+// Register adds a runner constructor for a given command name, replacing any
+// existing one. It is typically called from an init() function in the
+// runner's package.
 func Register(name string, constructor func() Runner) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
 	registry[name] = constructor
 }
 
+// Unregister removes the runner constructor registered under name, so tests
+// can undo a Register.
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, name)
+}
+
+// Registered returns the runner constructor registered under name and true,
+// or nil and false if there is none, so tests can restore it after
+// registering their own.
+func Registered(name string) (func() Runner, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	constructor, ok := registry[name]
+	return constructor, ok
+}
+
 // NewRunner returns a Runner for the given command name.
 func NewRunner(command string) (Runner, error) {
-	constructor, ok := registry[command]
+	constructor, ok := Registered(command)
 	if !ok {
-		return nil, fmt.Errorf("unsupported runner: %q (available: %v)", command, registeredNames())
+		return nil, &ErrUnsupportedRunner{Command: command, Available: registeredNames()}
 	}
 	return constructor(), nil
 }
 
 func registeredNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 	names := make([]string, 0, len(registry))
 	for k := range registry {
 		names = append(names, k)
@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Retry wraps a Runner so a failed run is attempted again, up to
+// RunOptions.Retries more times. Every attempt's events are forwarded as they
+// arrive; the terminal error is that of the last attempt. Errors that
+// ClassifyError reports as fatal or aborted, such as a missing agent, an
+// authentication failure or a cancelled run, would only fail the same way
+// again, so they end the run at once.
+//
+// When RunOptions.MaxCostUSD is set, all attempts share one cost budget. Each
+// attempt runs with MaxCostUSD set to what is left, and its spend is metered
+// from its events as it streams, so an attempt that uses up the rest of the
+// budget is cancelled mid-run, whether or not the inner runner enforces
+// MaxCostUSD itself. A retry is only started while budget remains and covers
+// the cost of the attempt before it. Otherwise the run stops with an error
+// wrapping both the last attempt's failure and ErrBudgetExceeded, even if
+// retries remain.
+type Retry struct {
+	Inner Runner
+}
+
+// NewRetry returns a Retry around inner.
+func NewRetry(inner Runner) *Retry {
+	return &Retry{Inner: inner}
+}
+
+// Run runs the inner runner until an attempt succeeds, the retries or the
+// budget run out, or the run is cancelled.
+func (r *Retry) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	events := make(chan Event, 64)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		if err := r.run(opts, events); err != nil {
+			errc <- err
+		}
+		close(errc)
+	}()
+
+	return events, errc
+}
+
+func (r *Retry) run(opts RunOptions, events chan<- Event) error {
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	budget := costBudget{limit: opts.MaxCostUSD}
+	for attempt := 1; ; attempt++ {
+		attemptOpts := opts
+		ctx, cancel := context.WithCancel(parent)
+		attemptOpts.Context = ctx
+		left := budget.remaining()
+		if budget.limited() {
+			attemptOpts.MaxCostUSD = left
+		}
+
+		var seen []Event
+		var meter costMeter
+		var overspent bool
+		inner, innerErrc := r.Inner.Run(attemptOpts)
+		err := Drain(context.Background(), inner, innerErrc, func(e Event) {
+			seen = append(seen, e)
+			events <- e
+			meter.observe(e)
+			if budget.limited() && !overspent && meter.spent() >= left {
+				overspent = true
+				cancel()
+			}
+		})
+		cancel()
+		cost := meter.spent()
+		budget.charge(cost)
+		if overspent {
+			return fmt.Errorf("attempt %d spent $%.4f, using up the $%.4f budget: %w",
+				attempt, budget.spent, budget.limit, ErrBudgetExceeded)
+		}
+
+		status := FinalStatus(seen, err)
+		if status == RunStatusSuccess || status == RunStatusCancelled || status == RunStatusBudgetExceeded {
+			return err
+		}
+		if class := ClassifyError(err); class == ErrorClassFatal || class == ErrorClassAborted {
+			return err
+		}
+		if err == nil {
+			err = errors.New("run ended without a successful result")
+		}
+		if attempt > opts.Retries {
+			return err
+		}
+		if budget.limited() && (budget.remaining() == 0 || budget.remaining() < cost) {
+			return fmt.Errorf("attempt %d failed: %w; not retrying: $%.4f of $%.4f budget left: %w",
+				attempt, err, budget.remaining(), budget.limit, ErrBudgetExceeded)
+		}
+	}
+}
+
+// costBudget tracks spend against a limit shared by every attempt of a run.
+// A zero limit is unlimited.
+type costBudget struct {
+	limit float64
+	spent float64
+}
+
+func (b *costBudget) limited() bool { return b.limit > 0 }
+
+func (b *costBudget) charge(cost float64) { b.spent += cost }
+
+// remaining returns the unspent budget, never below zero.
+func (b *costBudget) remaining() float64 {
+	return max(b.limit-b.spent, 0)
+}
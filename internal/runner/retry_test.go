@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// flakyRunner fails every attempt before the succeedOn'th (1-based; zero
+// never succeeds), each costing cost, and records the options of every call.
+type flakyRunner struct {
+	cost      float64
+	succeedOn int
+	calls     []RunOptions
+}
+
+func (f *flakyRunner) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	f.calls = append(f.calls, opts)
+	events := make(chan Event, 1)
+	errc := make(chan error)
+	failed := len(f.calls) != f.succeedOn
+	events <- Event{Type: "result", Data: map[string]any{"result": "attempt", "is_error": failed, "total_cost_usd": f.cost}}
+	close(events)
+	close(errc)
+	return events, errc
+}
+
+func TestRetry_RetriesUntilSuccess(t *testing.T) {
+	inner := &flakyRunner{cost: 0.10, succeedOn: 3}
+
+	events, err := collect(NewRetry(inner).Run(RunOptions{Retries: 3, MaxCostUSD: 1}))
+	require.NoError(t, err)
+	require.Len(t, inner.calls, 3)
+	require.Len(t, events, 3, "every attempt's events are forwarded")
+	require.InDelta(t, 1.0, inner.calls[0].MaxCostUSD, 1e-9)
+	require.InDelta(t, 0.8, inner.calls[2].MaxCostUSD, 1e-9, "later attempts get what is left of the shared budget")
+}
+
+func TestRetry_StopsWhenBudgetCannotCoverRetry(t *testing.T) {
+	inner := &flakyRunner{cost: 0.60}
+
+	events, err := collect(NewRetry(inner).Run(RunOptions{Retries: 3, MaxCostUSD: 1}))
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	require.Contains(t, err.Error(), "attempt 1 failed")
+	require.Len(t, inner.calls, 1, "$0.40 left cannot cover another $0.60 attempt")
+	require.Equal(t, RunStatusBudgetExceeded, FinalStatus(events, err))
+}
+
+func TestRetry_UnlimitedBudgetUsesAllRetries(t *testing.T) {
+	inner := &flakyRunner{cost: 5}
+
+	events, err := collect(NewRetry(inner).Run(RunOptions{Retries: 2}))
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrBudgetExceeded))
+	require.Len(t, inner.calls, 3)
+	require.Equal(t, RunStatusError, FinalStatus(events, err))
+}
+
+func TestRetry_NoRetryOnSuccess(t *testing.T) {
+	inner := &flakyRunner{succeedOn: 1}
+
+	_, err := collect(NewRetry(inner).Run(RunOptions{Retries: 5}))
+	require.NoError(t, err)
+	require.Len(t, inner.calls, 1)
+}
+
+// spendingRunner streams one assistant message priced at $3, then blocks
+// until its run is cancelled, recording whether it was.
+type spendingRunner struct {
+	cancelled bool
+}
+
+func (s *spendingRunner) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	events := make(chan Event, 1)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(events)
+		e := assistantWithUsage("claude-sonnet-4-5", 1_000_000, 0, 0, 0)
+		e.Data["message"].(map[string]any)["id"] = "m1"
+		events <- e
+		<-opts.Context.Done()
+		s.cancelled = true
+		errc <- fmt.Errorf("run cancelled: %w", opts.Context.Err())
+		close(errc)
+	}()
+	return events, errc
+}
+
+func TestRetry_CancelsAttemptThatOverspendsBudget(t *testing.T) {
+	inner := &spendingRunner{}
+
+	events, err := collect(NewRetry(inner).Run(RunOptions{Retries: 3, MaxCostUSD: 1}))
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	require.Contains(t, err.Error(), "attempt 1 spent $3.0000")
+	require.True(t, inner.cancelled, "the attempt is stopped mid-run")
+	require.Len(t, events, 1)
+}
+
+// failingRunner fails every run with err, counting the calls.
+type failingRunner struct {
+	err   error
+	calls int
+}
+
+func (f *failingRunner) Run(RunOptions) (<-chan Event, <-chan error) {
+	f.calls++
+	return failed(f.err)
+}
+
+func TestRetry_NoRetryOnFatalOrAbortedError(t *testing.T) {
+	for _, err := range []error{
+		&ErrAgentNotFound{Command: "claude", Err: exec.ErrNotFound},
+		errors.New("Invalid API key · Please run /login"),
+		ErrPlanRejected,
+	} {
+		inner := &failingRunner{err: err}
+
+		_, got := collect(NewRetry(inner).Run(RunOptions{Retries: 3}))
+		require.ErrorIs(t, got, err)
+		require.Equal(t, 1, inner.calls, "%v is not retried", err)
+	}
+}
+
+func TestRetry_RetriesTransientError(t *testing.T) {
+	inner := &failingRunner{err: &ErrRunFailed{Command: "claude", ExitCode: 1, Err: errors.New("exit status 1")}}
+
+	_, err := collect(NewRetry(inner).Run(RunOptions{Retries: 2}))
+	require.Error(t, err)
+	require.Equal(t, 3, inner.calls)
+}
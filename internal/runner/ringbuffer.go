@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"context"
+	"sync"
+)
+
+// RingBuffer retains the last events of a run so consumers that attach late,
+// such as a client connecting after the run started, still see its recent
+// history. Each subscriber is replayed the retained events and then receives
+// live ones, in order, much like a Broadcast consumer added after the fact.
+type RingBuffer struct {
+	mu     sync.Mutex
+	opts   BroadcastOptions
+	recent []Event // ring of retained events; next is the oldest once full
+	next   int
+	full   bool
+	subs   map[*ringSub]struct{}
+	done   bool
+}
+
+type ringSub struct {
+	ch   chan Event
+	ctx  context.Context
+	stop func() bool // unregisters the cancellation hook
+}
+
+// NewRingBuffer consumes in, retaining its last size events, with
+// DefaultBroadcastBuffer of live buffering per subscriber and
+// SlowConsumerBlock. A size of zero or less retains nothing.
+func NewRingBuffer(in <-chan Event, size int) *RingBuffer {
+	return NewRingBufferWithOptions(in, size, BroadcastOptions{Buffer: DefaultBroadcastBuffer})
+}
+
+// NewRingBufferWithOptions is NewRingBuffer with explicit buffering and
+// slow-consumer policy, which apply to live events as in BroadcastWithOptions.
+// With SlowConsumerBlock a stalled subscriber also holds up Subscribe.
+func NewRingBufferWithOptions(in <-chan Event, size int, opts BroadcastOptions) *RingBuffer {
+	b := &RingBuffer{
+		opts:   opts,
+		recent: make([]Event, max(size, 0)),
+		subs:   map[*ringSub]struct{}{},
+	}
+	go b.consume(in)
+	return b
+}
+
+// Subscribe returns a channel carrying the retained events, oldest first,
+// followed by every later event. The channel closes once the run ends, or
+// once ctx is done, after which the subscriber is dropped. Subscribing after
+// the run has ended replays the retained events and closes.
+func (b *RingBuffer) Subscribe(ctx context.Context) <-chan Event {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Room for the whole replay means it never blocks while holding the lock.
+	backlog := b.snapshot()
+	ch := make(chan Event, len(b.recent)+max(b.opts.Buffer, 0))
+	for _, e := range backlog {
+		ch <- e
+	}
+	if b.done {
+		close(ch)
+		return ch
+	}
+	s := &ringSub{ch: ch, ctx: ctx}
+	// The hook takes the lock, so it cannot run before s is registered.
+	s.stop = context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[s]; ok {
+			b.drop(s)
+		}
+	})
+	b.subs[s] = struct{}{}
+	return ch
+}
+
+// Recent returns a copy of the retained events, oldest first.
+func (b *RingBuffer) Recent() []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.snapshot()
+}
+
+// consume retains and fans out each event of in, then closes every
+// subscriber. Events are sent under the lock so a subscriber joining midway
+// sees each event exactly once: in its replay or live.
+func (b *RingBuffer) consume(in <-chan Event) {
+	for e := range in {
+		b.mu.Lock()
+		b.retain(e)
+		for s := range b.subs {
+			b.send(s, e)
+		}
+		b.mu.Unlock()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done = true
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+// send delivers e to s under the policy, dropping s if its context ends
+// while it is being waited on.
+func (b *RingBuffer) send(s *ringSub, e Event) {
+	if b.opts.Policy == SlowConsumerDrop {
+		select {
+		case s.ch <- e:
+		case <-s.ctx.Done():
+			b.drop(s)
+		default:
+		}
+		return
+	}
+	select {
+	case s.ch <- e:
+	case <-s.ctx.Done():
+		b.drop(s)
+	}
+}
+
+// drop unregisters s and closes its channel. The caller holds the lock.
+func (b *RingBuffer) drop(s *ringSub) {
+	delete(b.subs, s)
+	s.stop()
+	close(s.ch)
+}
+
+// retain stores e, overwriting the oldest event once the ring is full.
+func (b *RingBuffer) retain(e Event) {
+	if len(b.recent) == 0 {
+		return
+	}
+	b.recent[b.next] = e
+	b.next = (b.next + 1) % len(b.recent)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// snapshot returns the retained events, oldest first. The caller holds the
+// lock.
+func (b *RingBuffer) snapshot() []Event {
+	if !b.full {
+		return append([]Event(nil), b.recent[:b.next]...)
+	}
+	return append(append([]Event(nil), b.recent[b.next:]...), b.recent[:b.next]...)
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func types(ch <-chan Event) []string {
+	var got []string
+	for e := range ch {
+		got = append(got, e.Type)
+	}
+	return got
+}
+
+func TestRingBuffer_LateSubscriberGetsRecentThenLive(t *testing.T) {
+	in := make(chan Event)
+	b := NewRingBuffer(in, 3)
+
+	// The early subscriber paces the source so each event is retained
+	// before the next is sent.
+	early := b.Subscribe(context.Background())
+	for _, typ := range []string{"a", "b", "c", "d", "e"} {
+		in <- Event{Type: typ}
+		require.Equal(t, typ, (<-early).Type)
+	}
+
+	late := b.Subscribe(context.Background())
+	require.Equal(t, []string{"c", "d", "e"}, []string{(<-late).Type, (<-late).Type, (<-late).Type}, "events older than the last 3 are dropped")
+	in <- Event{Type: "f"}
+	in <- Event{Type: "g"}
+	close(in)
+
+	require.Equal(t, []string{"f", "g"}, types(late))
+	require.Equal(t, []string{"f", "g"}, types(early))
+}
+
+func TestRingBuffer_SubscribeAfterRunReplaysAndCloses(t *testing.T) {
+	b := NewRingBuffer(feed(5), 2)
+	early := b.Subscribe(context.Background())
+	for range early {
+	}
+
+	require.Equal(t, []Event{{Type: "assistant", Data: map[string]any{"i": 3}}, {Type: "assistant", Data: map[string]any{"i": 4}}}, b.Recent())
+	var got []int
+	for e := range b.Subscribe(context.Background()) {
+		got = append(got, e.Data["i"].(int))
+	}
+	require.Equal(t, []int{3, 4}, got)
+}
+
+func TestRingBuffer_CancelledSubscriberIsDropped(t *testing.T) {
+	in := make(chan Event)
+	b := NewRingBufferWithOptions(in, 0, BroadcastOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	stalled := b.Subscribe(ctx)
+	live := b.Subscribe(context.Background())
+
+	in <- Event{Type: "a"} // the stalled subscriber holds this send until cancelled
+	cancel()
+	require.Equal(t, "a", (<-live).Type)
+	for range stalled {
+	}
+
+	in <- Event{Type: "b"}
+	close(in)
+	require.Equal(t, []string{"b"}, types(live))
+}
+
+func TestRingBuffer_ZeroSizeRetainsNothing(t *testing.T) {
+	in := make(chan Event)
+	b := NewRingBuffer(in, 0)
+	early := b.Subscribe(context.Background())
+	in <- Event{Type: "a"}
+	<-early
+
+	late := b.Subscribe(context.Background())
+	in <- Event{Type: "b"}
+	close(in)
+	require.Equal(t, []string{"b"}, types(late))
+	require.Empty(t, b.Recent())
+}
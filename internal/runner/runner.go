@@ -2,10 +2,13 @@
 package runner
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/jumppad-labs/spektacular/internal/config"
 )
@@ -24,6 +27,10 @@ type Runner interface {
 type Event struct {
 	Type string
 	Data map[string]any
+	// Raw is the exact JSON the decoder parsed Data from, for forensic
+	// debugging. It is nil for events the runner makes up itself, and is left
+	// out when an Event is marshalled; marshal Raw itself to keep it.
+	Raw json.RawMessage `json:"-"`
 }
 
 // SessionID returns the session_id field if present.
@@ -32,9 +39,27 @@ func (e Event) SessionID() string {
 	return v
 }
 
+// CWD returns the working directory the agent reports in a system event,
+// such as the init event, or empty string for any other event.
+func (e Event) CWD() string {
+	if e.Type != "system" {
+		return ""
+	}
+	v, _ := e.Data["cwd"].(string)
+	return v
+}
+
 // IsResult reports whether this is a terminal result event.
 func (e Event) IsResult() bool { return e.Type == "result" }
 
+// IsSynthesized reports whether this result event was made up by the runner
+// because the agent exited cleanly without emitting one. Its text is the
+// run's collected assistant text.
+func (e Event) IsSynthesized() bool {
+	v, _ := e.Data["synthesized"].(bool)
+	return e.IsResult() && v
+}
+
 // IsError reports whether this is an error result.
 func (e Event) IsError() bool {
 	if !e.IsResult() {
@@ -53,16 +78,85 @@ func (e Event) ResultText() string {
 	return v
 }
 
+// ResultTitle returns the text of the first Markdown H1 heading in a result
+// event's text, such as the title an agent opens its plan with, or empty
+// string if there is none or e is not a result. Headings inside fenced code
+// blocks are ignored.
+func (e Event) ResultTitle() string {
+	inFence := false
+	for _, line := range strings.Split(e.ResultText(), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		// An H1 may be indented by up to three spaces.
+		if inFence || len(line)-len(strings.TrimLeft(line, " ")) > 3 {
+			continue
+		}
+		if title, ok := strings.CutPrefix(trimmed, "# "); ok {
+			// A closing sequence of #s, set off by a space, is not part of
+			// the title.
+			if closed := strings.TrimRight(title, "#"); closed == "" || strings.HasSuffix(closed, " ") {
+				title = closed
+			}
+			return strings.TrimSpace(title)
+		}
+	}
+	return ""
+}
+
+// IsPartial reports whether e is a partial-message "stream_event", emitted
+// when RunOptions.PartialMessages is set. Partial events never carry complete
+// content: the full text still arrives in the following assistant event.
+func (e Event) IsPartial() bool { return e.Type == "stream_event" }
+
+// PartialText returns the text delta carried by a partial-message event, or
+// empty string for any other event.
+func (e Event) PartialText() string {
+	if !e.IsPartial() {
+		return ""
+	}
+	inner, _ := e.Data["event"].(map[string]any)
+	delta, _ := inner["delta"].(map[string]any)
+	if delta["type"] != "text_delta" {
+		return ""
+	}
+	text, _ := delta["text"].(string)
+	return text
+}
+
+// DecodeErrors returns the number of undecodable stdout lines reported by a
+// "diagnostics" event and the first few decode error messages.
+func (e Event) DecodeErrors() (int, []string) {
+	if e.Type != "diagnostics" {
+		return 0, nil
+	}
+	n, _ := e.Data["decode_errors"].(int)
+	samples, _ := e.Data["decode_error_samples"].([]string)
+	return n, samples
+}
+
+// contentBlocks returns the message.content blocks of an event.
+func (e Event) contentBlocks() []map[string]any {
+	msg, _ := e.Data["message"].(map[string]any)
+	content, _ := msg["content"].([]any)
+	blocks := make([]map[string]any, 0, len(content))
+	for _, item := range content {
+		if block, ok := item.(map[string]any); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
 // TextContent extracts concatenated text blocks from an assistant event.
 func (e Event) TextContent() string {
 	if e.Type != "assistant" {
 		return ""
 	}
-	msg, _ := e.Data["message"].(map[string]any)
-	content, _ := msg["content"].([]any)
 	var texts []string
-	for _, item := range content {
-		block, _ := item.(map[string]any)
+	for _, block := range e.contentBlocks() {
 		if block["type"] == "text" {
 			if t, ok := block["text"].(string); ok {
 				texts = append(texts, t)
@@ -77,11 +171,8 @@ func (e Event) ToolUses() []map[string]any {
 	if e.Type != "assistant" {
 		return nil
 	}
-	msg, _ := e.Data["message"].(map[string]any)
-	content, _ := msg["content"].([]any)
 	var tools []map[string]any
-	for _, item := range content {
-		block, _ := item.(map[string]any)
+	for _, block := range e.contentBlocks() {
 		if block["type"] == "tool_use" {
 			tools = append(tools, block)
 		}
@@ -89,6 +180,37 @@ func (e Event) ToolUses() []map[string]any {
 	return tools
 }
 
+// Attachment is an image or document content block carried by an assistant event.
+type Attachment struct {
+	Kind       string // content block type: "image" or "document"
+	SourceType string // "base64" or "url"
+	MediaType  string // e.g. "image/png"; empty for url sources that omit it
+	Data       string // base64 payload when SourceType is "base64"
+	URL        string // location when SourceType is "url"
+}
+
+// Attachments extracts image and document blocks from an assistant event.
+func (e Event) Attachments() []Attachment {
+	if e.Type != "assistant" {
+		return nil
+	}
+	var attachments []Attachment
+	for _, block := range e.contentBlocks() {
+		kind, _ := block["type"].(string)
+		if kind != "image" && kind != "document" {
+			continue
+		}
+		source, _ := block["source"].(map[string]any)
+		a := Attachment{Kind: kind}
+		a.SourceType, _ = source["type"].(string)
+		a.MediaType, _ = source["media_type"].(string)
+		a.Data, _ = source["data"].(string)
+		a.URL, _ = source["url"].(string)
+		attachments = append(attachments, a)
+	}
+	return attachments
+}
+
 // QuestionType controls how the TUI renders a question.
 // "text" shows a free-text textarea. "choice" shows numbered options with an automatic "Other" entry.
 // Defaults to "text" when not specified or when no options are provided.
@@ -105,21 +227,35 @@ type Question struct {
 	Header   string
 	Type     QuestionType
 	Options  []map[string]any
+	Default  string // label of the option to use when no answer is given; may be empty
+	// MultiSelect lets a choice question take several options, answered as a
+	// comma-separated list of labels; see AnswerAll and AnswerNone.
+	MultiSelect bool
+	// Pattern is a regular expression a text question's answer must match in
+	// full, such as a branch naming rule; see CheckAnswer. Empty accepts
+	// any answer.
+	Pattern string
 }
 
 // detectQuestions finds <!--QUESTION:{...}--> markers in text and returns parsed questions.
+// Questions are returned in document order: markers in the order they appear in
+// text, and the questions within each marker in the order they are listed.
+// Markers whose payload fails to parse are skipped.
 func detectQuestions(text string) []Question {
 	var questions []Question
-	for _, match := range questionPattern.FindAllStringSubmatch(text, -1) {
+	for _, raw := range DetectMarkers(text, "QUESTION")["QUESTION"] {
 		var payload struct {
 			Questions []struct {
 				Question string           `json:"question"`
 				Header   string           `json:"header"`
 				Type     string           `json:"type"`
 				Options  []map[string]any `json:"options"`
+				Default  string           `json:"default"`
+				Multi    bool             `json:"multiSelect"`
+				Pattern  string           `json:"pattern"`
 			} `json:"questions"`
 		}
-		if err := json.Unmarshal([]byte(match[1]), &payload); err != nil {
+		if err := json.Unmarshal(raw, &payload); err != nil {
 			continue
 		}
 		for _, q := range payload.Questions {
@@ -127,12 +263,18 @@ func detectQuestions(text string) []Question {
 			if q.Type == string(QuestionTypeChoice) && len(q.Options) > 0 {
 				qt = QuestionTypeChoice
 			}
-			questions = append(questions, Question{
-				Question: q.Question,
-				Header:   q.Header,
-				Type:     qt,
-				Options:  q.Options,
-			})
+			question := Question{
+				Question:    q.Question,
+				Header:      q.Header,
+				Type:        qt,
+				Options:     q.Options,
+				Default:     q.Default,
+				MultiSelect: q.Multi && qt == QuestionTypeChoice,
+			}
+			if qt == QuestionTypeText {
+				question.Pattern = q.Pattern
+			}
+			questions = append(questions, question)
 		}
 	}
 	return questions
@@ -174,6 +316,9 @@ func StripMarkers(text string) string {
 }
 
 // Prompts bundles the user prompt and system prompt for an agent invocation.
+// Runners pass both to the agent verbatim and never rebuild them, so a prompt
+// assembled with BuildPrompt, BuildPromptNamed, or by hand reaches the agent
+// unchanged. RunOptions.Prompt, when set, takes the place of User.
 type Prompts struct {
 	User   string // initial user message
 	System string // system prompt; empty uses the agent's default
@@ -196,36 +341,57 @@ func RunSteps(
 	onText func(string),
 	onQuestion func([]Question) string,
 ) error {
-	for _, step := range steps {
-		if err := runStep(r, step, cfg, cwd, onText, onQuestion); err != nil {
+	return RunStepsWithOptions(r, steps, RunOptions{Config: cfg, CWD: cwd}, onText, onQuestion)
+}
+
+// RunStepsWithOptions is RunSteps with every step run from a copy of base, so
+// options such as Model, PermissionMode, and ApprovalFunc apply to the whole
+// pipeline. Each step's prompts and log file replace base's. When
+// base.ApprovalFunc is set it is called with a step's result text before the
+// next step starts; a rejection stops the pipeline with ErrPlanRejected. The
+// last step has nothing after it to gate, so approval is never asked for a
+// single planning step.
+func RunStepsWithOptions(
+	r Runner,
+	steps []Step,
+	base RunOptions,
+	onText func(string),
+	onQuestion func([]Question) string,
+) error {
+	for i, step := range steps {
+		plan, err := runStep(r, step, base, onText, onQuestion)
+		if err != nil {
 			return err
 		}
+		if i < len(steps)-1 && base.ApprovalFunc != nil && !base.ApprovalFunc(plan) {
+			return ErrPlanRejected
+		}
 	}
 	return nil
 }
 
+// runStep runs one step to completion and returns its final result text.
 func runStep(
 	r Runner,
 	step Step,
-	cfg config.Config,
-	cwd string,
+	base RunOptions,
 	onText func(string),
 	onQuestion func([]Question) string,
-) error {
+) (string, error) {
 	sessionID := ""
 	currentUser := step.Prompts.User
+	var result string
 
 	for {
 		var questionsFound []Question
 		var stepDone bool
 
-		events, errc := r.Run(RunOptions{
-			Prompts:   Prompts{User: currentUser, System: step.Prompts.System},
-			Config:    cfg,
-			SessionID: sessionID,
-			CWD:       cwd,
-			LogFile:   step.LogFile,
-		})
+		opts := base
+		opts.Prompts = Prompts{User: currentUser, System: step.Prompts.System}
+		opts.Prompt = ""
+		opts.SessionID = sessionID
+		opts.LogFile = step.LogFile
+		events, errc := r.Run(opts)
 
 		for event := range events {
 			if id := event.SessionID(); id != "" {
@@ -243,29 +409,41 @@ func runStep(
 			}
 			if event.IsResult() {
 				if event.IsError() {
-					return fmt.Errorf("agent error: %s", event.ResultText())
+					return "", fmt.Errorf("agent error: %s", event.ResultText())
 				}
+				result = event.ResultText()
 				stepDone = true
 			}
 		}
 
 		if err := <-errc; err != nil {
-			return fmt.Errorf("runner error: %w", err)
+			return "", fmt.Errorf("runner error: %w", err)
 		}
 
-		if !stepDone && len(questionsFound) > 0 && onQuestion != nil {
-			answer := onQuestion(questionsFound)
-			currentUser = answer
+		answer := onQuestion
+		if base.Answers != nil {
+			answer = PredefinedAnswers(base.Answers, onQuestion)
+		}
+		if !stepDone && len(questionsFound) > 0 && answer != nil {
+			currentUser = answer(questionsFound)
 			continue
 		}
 
-		return nil
+		return result, nil
 	}
 }
 
+// DefaultKnowledgeDir is the knowledge directory the prompt templates point
+// the agent at; PromptOptions.KnowledgeDir replaces it.
+const DefaultKnowledgeDir = ".spektacular/knowledge/"
+
+// knowledgeHint is the default hint pointing the agent at the project's
+// knowledge directory. It opens each user prompt template.
+const knowledgeHint = "Additional project knowledge, architectural context, and past learnings can be found in '" + DefaultKnowledgeDir + "'. Use your available tools to explore this directory as needed."
+
 // PromptWithHeader is the user prompt template with a custom content section header.
 // Args: header, content.
-var PromptWithHeader = `Additional project knowledge, architectural context, and past learnings can be found in '.spektacular/knowledge/'. Use your available tools to explore this directory as needed.
+var PromptWithHeader = knowledgeHint + `
 
 ---
 
@@ -273,9 +451,24 @@ var PromptWithHeader = `Additional project knowledge, architectural context, and
 
 %s`
 
+// PromptFooter is appended by BuildPromptWithHeaderFooter. The footer is fenced in
+// <required-footer> tags so the agent reproduces it in full rather than trimming it.
+// Args: footer.
+var PromptFooter = `
+
+---
+
+# Required Footer
+
+End your output with the content between the <required-footer> tags, reproduced exactly and in full.
+
+<required-footer>
+%s
+</required-footer>`
+
 // PromptPlan is the user prompt template for the planner, including the plan directory.
 // Args: planDir, specContent.
-var PromptPlan = `Additional project knowledge, architectural context, and past learnings can be found in '.spektacular/knowledge/'. Use your available tools to explore this directory as needed.
+var PromptPlan = knowledgeHint + `
 
 Write all plan output files to this exact directory: '%s'
 
@@ -285,13 +478,280 @@ Write all plan output files to this exact directory: '%s'
 
 %s`
 
+// PromptFollowup is the user prompt template for refining a previously generated plan.
+// The prior plan is fenced in <previous-plan> tags so the agent reads it as context rather
+// than output to reproduce. Args: previousPlan, instruction.
+var PromptFollowup = knowledgeHint + `
+
+---
+
+# Previous Plan
+
+The plan between the <previous-plan> tags was produced by an earlier run. Treat it as context for the follow-up instruction below: revise it as instructed, and do not re-output it unchanged.
+
+<previous-plan>
+%s
+</previous-plan>
+
+---
+
+# Follow-up Instruction
+
+%s`
+
+// BuildFollowupPrompt assembles a prompt that continues from previousPlan with a new
+// instruction, using the PromptFollowup template.
+func BuildFollowupPrompt(previousPlan, instruction string) string {
+	return BuildFollowupPromptWithOptions(previousPlan, instruction, PromptOptions{})
+}
+
+// BuildFollowupPromptWithOptions is BuildFollowupPrompt with the knowledge hint
+// pointing at opts.KnowledgeDir and followed by opts.KnowledgeHints, as in
+// BuildPromptWithOptions. Other options do not apply.
+func BuildFollowupPromptWithOptions(previousPlan, instruction string, opts PromptOptions) string {
+	return withKnowledge(fmt.Sprintf(PromptFollowup, strings.TrimSpace(previousPlan), instruction), opts)
+}
+
+// BuildPlanPrompt assembles the planner's user prompt with the PromptPlan
+// template, directing output to planDir, with the knowledge hint pointing at
+// opts.KnowledgeDir and followed by opts.KnowledgeHints. Other options do not
+// apply.
+func BuildPlanPrompt(planDir, specContent string, opts PromptOptions) string {
+	return withKnowledge(fmt.Sprintf(PromptPlan, planDir, specContent), opts)
+}
+
 // RunOptions holds parameters for running an agent.
 type RunOptions struct {
-	Prompts   Prompts
+	Prompts Prompts
+
+	// Prompt, if set, is sent to the agent verbatim as its input in place of
+	// Prompts.User, so a caller can pass an already-built prompt, such as
+	// one variant of an A/B comparison, without it being rebuilt.
+	Prompt string
+
 	Config    config.Config
-	SessionID string
+	SessionID string // session to resume; empty starts a new one
 	CWD       string
 	LogFile   string // path to debug log file; empty disables logging
 	Model     string // model override; empty uses the agent default
+
+	// PinnedSessionID, if set, fixes the id of a new session instead of
+	// letting the agent generate one, so recorded runs diff cleanly. It is
+	// passed to agents that accept one and stamped as session_id onto the
+	// events the runner synthesizes itself, such as warnings, stderr lines
+	// and synthesized results. It cannot be combined with SessionID.
+	PinnedSessionID string
+
+	// Context, if set, cancels the run when done: the agent is killed and
+	// the error channel carries an error wrapping the context's error.
+	Context context.Context
+
+	// PermissionMode selects how the agent handles tool permission prompts
+	// (e.g. "acceptEdits", "plan"). Empty uses the agent default.
+	PermissionMode string
+
+	// Temperature and Seed request more reproducible output from models that
+	// support them. Nil uses the agent default. Runners that cannot honour
+	// one emit a "warning" event instead; see Capabilities.
+	Temperature *float64
+	Seed        *int
+
+	// Steerable keeps the agent's input open for messages injected with
+	// RunHandle.Inject while the run streams. It only takes effect for runs
+	// started with Start, and needs a runner that supports Input.
+	Steerable bool
+
+	// Input, if set, keeps the agent's stdin open for further user messages,
+	// each a UserMessage line, read until EOF. Runners that support it send
+	// the prompt as the first message and end the run's input at EOF, so
+	// Input must be closed for the agent to exit. Start sets it for
+	// Steerable runs; runners without support ignore it.
+	Input io.Reader
+
+	// PartialMessages asks the agent to stream partial-message events as the
+	// model generates, for smoother UIs. They arrive as "stream_event" events;
+	// see Event.PartialText.
+	PartialMessages bool
+
+	// AllowedTools and DisallowedTools restrict which tools the agent may
+	// call (e.g. "Read", "Bash(git:*)"). Empty leaves the agent default.
+	AllowedTools    []string
+	DisallowedTools []string
+
+	// TextOnly runs the agent with every tool disabled, so it can only answer
+	// in text. It cannot be combined with AllowedTools.
+	TextOnly bool
+
+	// AddDirs are directories outside CWD the agent may also access. Relative
+	// paths are resolved against CWD; each must exist.
+	AddDirs []string
+
+	// ExtraArgs are appended verbatim to the agent command line, after the
+	// flags derived from the other options. Use them for CLI flags that have
+	// no dedicated option.
+	ExtraArgs []string
+
+	// OnQuestion, if set, is called with each question detected in the
+	// agent's streamed text, in order and before the event carrying it is
+	// delivered. It only observes: answering is still done by the caller.
+	// It runs on the runner's goroutine, so it must not block for long.
+	OnQuestion func(Question)
+
+	// Answers, if set, answers the agent's questions without asking anyone,
+	// for reproducible non-interactive runs: it maps question headers to
+	// answers, as LoadAnswers reads them, and falls back to each question's
+	// Default, as PredefinedAnswers does. RunStepsWithOptions answers from it
+	// before calling its onQuestion, and Plan resumes the session with it
+	// when a run ends on questions it answers in full.
+	Answers map[string]string
+
+	// StopOnToolError ends the run as soon as a tool result reports an
+	// error, killing the agent and returning a *ToolError.
+	StopOnToolError bool
+
+	// StopAfterResult ends the run as soon as the first result event has
+	// been delivered, killing the agent so the housekeeping events some
+	// agents stream afterwards do not delay the consumer. The run ends
+	// without error. No decoded events follow the result, though stderr
+	// lines already read may still arrive. By default the full stream is
+	// delivered.
+	StopAfterResult bool
+
+	// DiscardStderr drops the agent's stderr instead of emitting it as
+	// "stderr" events.
+	DiscardStderr bool
+
+	// FatalStderrPatterns are regular expressions matched against each line
+	// the agent writes to stderr, for CLIs that warn there and then carry on
+	// producing garbage. The first matching line kills the run, which ends
+	// with an *ErrFatalStderr quoting it. Matching applies even with
+	// DiscardStderr. Empty means no stderr line is fatal.
+	FatalStderrPatterns []string
+
+	// Debug turns on the agent CLI's own debug output. Its diagnostic lines
+	// arrive as "stderr" events, including any the CLI interleaves with the
+	// JSON on stdout, so Debug cannot be combined with DiscardStderr.
+	Debug bool
+
+	// ForwardNonJSON emits stdout lines that are not JSON objects, such as
+	// log lines from CLIs that interleave them with events, as "stdout"
+	// events carrying the raw text in Data["line"]. By default they are
+	// dropped.
+	ForwardNonJSON bool
+
+	// RawLog receives a copy of the agent's stdout, byte for byte, as it is
+	// read for decoding, or for runners that reach their agent some other
+	// way, the raw response they decode. Write errors are ignored after the
+	// first and never affect the run. Nil disables it.
+	RawLog io.Writer
+
+	// FieldMap renames keys in the agent's JSON output before events are
+	// built, for agent CLIs that name fields differently (e.g. "msg" for
+	// "message", "kind" for "type"), so the Event accessors work unchanged.
+	// Each entry maps an incoming key to its canonical name, at every level
+	// of nesting, and overrides claude.field_map for the same key. Event.Raw
+	// keeps the output as the agent wrote it.
+	FieldMap map[string]string
+
+	// Run limits. Zero means no limit; WithDefaults fills zero values from
+	// the configured config.RunDefaults, and NoLimit turns a configured
+	// default off for this run. Supervise enforces Timeout and
+	// IdleTimeout by killing the run with an *ErrTimeout, and MaxCostUSD by
+	// killing it with an error wrapping ErrBudgetExceeded once the spend
+	// metered from its events reaches the cap. MaxTurns is passed to the
+	// agent.
+	Timeout     time.Duration // wall-clock limit for the whole run
+	IdleTimeout time.Duration // limit on the gap between agent events
+	MaxTurns    int           // maximum agentic turns
+	MaxCostUSD  float64       // spend cap for the run
+	Retries     int           // attempts after the first failed one
+	clock       clock         // times Timeout and IdleTimeout; nil uses the real clock
+
+	// Resource limits on the agent process tree, enforced on Linux. Zero
+	// means no limit; exceeding a limit kills the run with a
+	// *ResourceLimitError. Enforcement is best effort: the agent's process
+	// group is sampled from /proc every 100ms, CPU is averaged over a
+	// second, usage between samples goes unchecked, and a child that leaves
+	// the group is not counted.
+	MaxMemoryBytes int64   // resident memory cap
+	MaxCPUPercent  float64 // CPU cap as a percentage of one core
+
+	// IdempotencyKey identifies the logical request so a backend can dedupe
+	// retried attempts. Empty derives a key from the prompts; see
+	// ResolveIdempotencyKey.
+	IdempotencyKey string
+
+	// ApprovalFunc, if set, gates a multi-step pipeline: RunStepsWithOptions
+	// calls it with each step's plan text before running the next step, and
+	// a false return aborts the pipeline with ErrPlanRejected.
+	ApprovalFunc func(plan string) bool
+
+	// Notifier, if set, is sent a Notification when the run ends. It is
+	// called on its own goroutine so a slow receiver never delays the run.
+	Notifier Notifier
+
+	// OnEvent, if set, is called with every event the run emits, in order,
+	// just before the event is sent on the events channel, for callers that
+	// prefer a callback. It runs on a goroutine behind the runner's buffer,
+	// so a brief stall does not hold up the agent, but each event reaches
+	// the channel only once OnEvent returns. It should return quickly and
+	// must not wait on the events channel, which is waiting on it.
+	OnEvent func(Event)
+
+	// EventTypes, if set, limits the events the run emits, on the channel
+	// and to OnEvent, to those whose Type is listed, e.g. "result" and
+	// "assistant" for a monitor watching for results and questions. Hooks
+	// such as OnQuestion still see every event, and errors are always
+	// delivered on the error channel. Empty emits every event.
+	EventTypes []string
+}
+
+// UserPrompt returns the input the agent is sent: o.Prompt when set,
+// otherwise o.Prompts.User.
+func (o RunOptions) UserPrompt() string {
+	if o.Prompt != "" {
+		return o.Prompt
+	}
+	return o.Prompts.User
 }
 
+// stamp returns e with session_id set to o.PinnedSessionID, for events the
+// runner synthesizes rather than decodes from the agent. Events that already
+// carry a session id, and all events when no id is pinned, are unchanged.
+func (o RunOptions) stamp(e Event) Event {
+	if o.PinnedSessionID == "" || e.SessionID() != "" {
+		return e
+	}
+	if e.Data == nil {
+		e.Data = map[string]any{}
+	}
+	e.Data["session_id"] = o.PinnedSessionID
+	return e
+}
+
+// NoLimit, set as a RunOptions run limit, disables that limit (or, for
+// Retries, retrying) for the run, even when the configured defaults set one.
+const NoLimit = -1
+
+// WithDefaults returns a copy of o with every zero-valued run limit taken from
+// d. Limits set explicitly on o are kept, so per-run options override the
+// configured baseline; limits set to NoLimit are reset to zero.
+func (o RunOptions) WithDefaults(d config.RunDefaults) RunOptions {
+	o.Timeout = withDefault(o.Timeout, d.Timeout)
+	o.IdleTimeout = withDefault(o.IdleTimeout, d.IdleTimeout)
+	o.MaxTurns = withDefault(o.MaxTurns, d.MaxTurns)
+	o.MaxCostUSD = withDefault(o.MaxCostUSD, d.MaxCostUSD)
+	o.Retries = withDefault(o.Retries, d.Retries)
+	return o
+}
+
+// withDefault returns v, d when v is unset, or zero when v is negative.
+func withDefault[T int | float64 | time.Duration](v, d T) T {
+	switch {
+	case v < 0:
+		return 0
+	case v == 0:
+		return d
+	}
+	return v
+}
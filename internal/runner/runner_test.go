@@ -2,8 +2,12 @@ package runner
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/jumppad-labs/spektacular/internal/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,6 +25,16 @@ func TestEvent_SessionID_Missing(t *testing.T) {
 	require.Equal(t, "", e.SessionID())
 }
 
+func TestEvent_CWD(t *testing.T) {
+	e := Event{Type: "system", Data: map[string]any{"subtype": "init", "cwd": "/home/dev/repo"}}
+	require.Equal(t, "/home/dev/repo", e.CWD())
+}
+
+func TestEvent_CWD_Missing(t *testing.T) {
+	require.Equal(t, "", Event{Type: "system", Data: map[string]any{"subtype": "init"}}.CWD())
+	require.Equal(t, "", Event{Type: "assistant", Data: map[string]any{"cwd": "/tmp"}}.CWD(), "only system events report it")
+}
+
 func TestEvent_IsResult_True(t *testing.T) {
 	e := Event{Type: "result"}
 	require.True(t, e.IsResult())
@@ -51,6 +65,22 @@ func TestEvent_ResultText_EmptyWhenNotResult(t *testing.T) {
 	require.Equal(t, "", e.ResultText())
 }
 
+func TestEvent_ResultTitle(t *testing.T) {
+	text := "```md\n# Not a title\n```\n\n#Also not\n## Overview\n# Auth Rollout Plan #\n\n# Second H1\n"
+	require.Equal(t, "Auth Rollout Plan", Event{Type: "result", Data: map[string]any{"result": text}}.ResultTitle())
+	require.Equal(t, "Port to C#", Event{Type: "result", Data: map[string]any{"result": "# Port to C#"}}.ResultTitle())
+}
+
+func TestEvent_ResultTitle_EmptyWithoutH1(t *testing.T) {
+	e := Event{Type: "result", Data: map[string]any{"result": "## Overview\n\nplan text"}}
+	require.Equal(t, "", e.ResultTitle())
+}
+
+func TestEvent_ResultTitle_EmptyWhenNotResult(t *testing.T) {
+	e := Event{Type: "assistant", Data: map[string]any{"result": "# Plan"}}
+	require.Equal(t, "", e.ResultTitle())
+}
+
 func TestEvent_TextContent_ExtractsTextBlocks(t *testing.T) {
 	e := Event{
 		Type: "assistant",
@@ -89,6 +119,42 @@ func TestEvent_ToolUses(t *testing.T) {
 	require.Equal(t, "Bash", tools[0]["name"])
 }
 
+func TestEvent_Attachments_ImageAlongsideText(t *testing.T) {
+	e := Event{
+		Type: "assistant",
+		Data: map[string]any{
+			"message": map[string]any{
+				"content": []any{
+					map[string]any{"type": "text", "text": "here is the diagram"},
+					map[string]any{"type": "image", "source": map[string]any{
+						"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo=",
+					}},
+					map[string]any{"type": "document", "source": map[string]any{
+						"type": "url", "url": "https://example.com/spec.pdf",
+					}},
+				},
+			},
+		},
+	}
+
+	require.Equal(t, "here is the diagram", e.TextContent())
+	require.Equal(t, []Attachment{
+		{Kind: "image", SourceType: "base64", MediaType: "image/png", Data: "iVBORw0KGgo="},
+		{Kind: "document", SourceType: "url", URL: "https://example.com/spec.pdf"},
+	}, e.Attachments())
+}
+
+func TestEvent_Attachments_EmptyForTextOnlyAndNonAssistant(t *testing.T) {
+	text := Event{
+		Type: "assistant",
+		Data: map[string]any{"message": map[string]any{"content": []any{
+			map[string]any{"type": "text", "text": "hello"},
+		}}},
+	}
+	require.Empty(t, text.Attachments())
+	require.Empty(t, Event{Type: "result"}.Attachments())
+}
+
 // ---------------------------------------------------------------------------
 // detectQuestions tests
 // ---------------------------------------------------------------------------
@@ -136,6 +202,84 @@ func TestPromptWithHeader_UsesCustomHeader(t *testing.T) {
 	require.NotContains(t, prompt, "Specification to Plan")
 }
 
+func TestBuildFollowupPromptWithOptions_ConfiguredKnowledge(t *testing.T) {
+	prompt := BuildFollowupPromptWithOptions("# Plan", "tighten it", PromptOptions{
+		KnowledgeDir:   "docs/kb",
+		KnowledgeHints: []string{"org wiki: https://wiki.example/eng"},
+	})
+
+	require.Contains(t, prompt, "'docs/kb/'")
+	require.NotContains(t, prompt, DefaultKnowledgeDir)
+	require.Contains(t, prompt, "Also consult these knowledge sources:\n- org wiki: https://wiki.example/eng\n\n---\n\n# Previous Plan")
+}
+
+func TestBuildPlanPrompt_UsesKnowledgeHint(t *testing.T) {
+	prompt := BuildPlanPrompt(".spektacular/plans/auth", "# Spec", PromptOptions{})
+	require.True(t, strings.HasPrefix(prompt, knowledgeHint))
+	require.Contains(t, prompt, "'.spektacular/plans/auth'")
+	require.True(t, strings.HasSuffix(prompt, "# Specification to Plan\n\n# Spec"))
+
+	custom := BuildPlanPrompt("plans", "# Spec", PromptOptions{KnowledgeDir: "kb"})
+	require.Contains(t, custom, "'kb/'")
+	require.NotContains(t, custom, DefaultKnowledgeDir)
+}
+
+func TestBuildFollowupPrompt_FramesPlanAndInstruction(t *testing.T) {
+	prompt := BuildFollowupPrompt("# Plan\n\n1. do the thing\n", "split phase 1 in two")
+
+	require.Contains(t, prompt, ".spektacular/knowledge/")
+	require.Contains(t, prompt, "# Previous Plan")
+	require.Contains(t, prompt, "<previous-plan>\n# Plan\n\n1. do the thing\n</previous-plan>")
+	require.Contains(t, prompt, "do not re-output it unchanged")
+	require.Contains(t, prompt, "# Follow-up Instruction\n\nsplit phase 1 in two")
+
+	// The instruction must follow the delimited plan so the agent reads the
+	// plan as context for it.
+	require.Less(t, strings.Index(prompt, "</previous-plan>"), strings.Index(prompt, "split phase 1 in two"))
+}
+
+// ---------------------------------------------------------------------------
+// RunOptions defaults tests
+// ---------------------------------------------------------------------------
+
+func TestRunOptions_WithDefaults_FillsZeroValues(t *testing.T) {
+	defaults := config.RunDefaults{
+		Timeout:     30 * time.Minute,
+		IdleTimeout: 2 * time.Minute,
+		MaxTurns:    40,
+		MaxCostUSD:  5,
+		Retries:     2,
+	}
+
+	opts := RunOptions{CWD: "/repo"}.WithDefaults(defaults)
+	require.Equal(t, "/repo", opts.CWD)
+	require.Equal(t, 30*time.Minute, opts.Timeout)
+	require.Equal(t, 2*time.Minute, opts.IdleTimeout)
+	require.Equal(t, 40, opts.MaxTurns)
+	require.Equal(t, 5.0, opts.MaxCostUSD)
+	require.Equal(t, 2, opts.Retries)
+}
+
+func TestRunOptions_WithDefaults_ExplicitOptionsWin(t *testing.T) {
+	defaults := config.RunDefaults{Timeout: 30 * time.Minute, MaxTurns: 40, Retries: 2}
+
+	opts := RunOptions{Timeout: time.Minute, MaxTurns: 3}.WithDefaults(defaults)
+	require.Equal(t, time.Minute, opts.Timeout)
+	require.Equal(t, 3, opts.MaxTurns)
+	require.Equal(t, 2, opts.Retries)
+}
+
+func TestRunOptions_WithDefaults_NoLimitDisablesDefaults(t *testing.T) {
+	defaults := config.RunDefaults{Timeout: 30 * time.Minute, IdleTimeout: time.Minute, MaxTurns: 40, MaxCostUSD: 5, Retries: 2}
+
+	opts := RunOptions{Timeout: NoLimit, IdleTimeout: NoLimit, MaxTurns: NoLimit, MaxCostUSD: NoLimit, Retries: NoLimit}.WithDefaults(defaults)
+	require.Zero(t, opts.Timeout)
+	require.Zero(t, opts.IdleTimeout)
+	require.Zero(t, opts.MaxTurns)
+	require.Zero(t, opts.MaxCostUSD)
+	require.Zero(t, opts.Retries)
+}
+
 // ---------------------------------------------------------------------------
 // NewRunner factory tests
 // ---------------------------------------------------------------------------
@@ -152,15 +296,35 @@ func TestNewRunner_ReturnsRunnerForRegisteredCommand(t *testing.T) {
 	Register("test-runner", func() Runner {
 		return &stubRunner{}
 	})
-	defer func() {
-		delete(registry, "test-runner")
-	}()
+	defer Unregister("test-runner")
 
 	r, err := NewRunner("test-runner")
 	require.NoError(t, err)
 	require.NotNil(t, r)
 }
 
+func TestRegistry_ConcurrentUse(t *testing.T) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range 8 {
+		name := fmt.Sprintf("concurrent-runner-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer Unregister(name)
+			Register(name, func() Runner { return &stubRunner{} })
+			_, err := NewRunner(name)
+			errs <- err
+			NewRunner("unknown-agent")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
 // stubRunner is a minimal runner for testing the registry.
 type stubRunner struct{}
 
@@ -171,3 +335,57 @@ func (s *stubRunner) Run(_ RunOptions) (<-chan Event, <-chan error) {
 	close(errc)
 	return events, errc
 }
+
+// scriptedRunner answers each Run with a result event whose text is the
+// user prompt it was given, and records the options of every call.
+type scriptedRunner struct {
+	calls []RunOptions
+}
+
+func (s *scriptedRunner) Run(opts RunOptions) (<-chan Event, <-chan error) {
+	s.calls = append(s.calls, opts)
+	events := make(chan Event, 1)
+	errc := make(chan error)
+	events <- Event{Type: "result", Data: map[string]any{"result": "plan for " + opts.Prompts.User}}
+	close(events)
+	close(errc)
+	return events, errc
+}
+
+func TestRunStepsWithOptions_ApprovedPlanContinues(t *testing.T) {
+	r := &scriptedRunner{}
+	var reviewed []string
+	base := RunOptions{Model: "opus", CWD: "/repo", ApprovalFunc: func(plan string) bool {
+		reviewed = append(reviewed, plan)
+		return true
+	}}
+	steps := []Step{{Prompts: Prompts{User: "plan"}}, {Prompts: Prompts{User: "implement"}}}
+
+	require.NoError(t, RunStepsWithOptions(r, steps, base, nil, nil))
+	require.Equal(t, []string{"plan for plan"}, reviewed, "the last step has nothing after it to gate")
+	require.Len(t, r.calls, 2)
+	require.Equal(t, "opus", r.calls[1].Model)
+	require.Equal(t, "/repo", r.calls[1].CWD)
+	require.Equal(t, "implement", r.calls[1].Prompts.User)
+}
+
+func TestRunStepsWithOptions_RejectedPlanStops(t *testing.T) {
+	r := &scriptedRunner{}
+	base := RunOptions{ApprovalFunc: func(string) bool { return false }}
+	steps := []Step{{Prompts: Prompts{User: "plan"}}, {Prompts: Prompts{User: "implement"}}}
+
+	err := RunStepsWithOptions(r, steps, base, nil, nil)
+	require.ErrorIs(t, err, ErrPlanRejected)
+	require.Equal(t, RunStatusRejected, FinalStatus(nil, err))
+	require.Len(t, r.calls, 1, "the edit step must not run")
+}
+
+func TestRunStepsWithOptions_SinglePlanningStepSkipsApproval(t *testing.T) {
+	r := &scriptedRunner{}
+	base := RunOptions{ApprovalFunc: func(string) bool {
+		t.Fatal("approval asked for a pure planning run")
+		return false
+	}}
+
+	require.NoError(t, RunStepsWithOptions(r, []Step{{Prompts: Prompts{User: "plan"}}}, base, nil, nil))
+}
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonSchemaDialect is the JSON Schema draft QuestionsToJSONSchema targets.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// questionSchema is the JSON Schema object QuestionsToJSONSchema renders.
+type questionSchema struct {
+	Schema               string                    `json:"$schema"`
+	Type                 string                    `json:"type"`
+	Properties           map[string]schemaProperty `json:"properties"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties bool                      `json:"additionalProperties"`
+}
+
+// schemaProperty is the schema of one answer, or of the items of a
+// multi-select answer.
+type schemaProperty struct {
+	Type        string          `json:"type"`
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Enum        []string        `json:"enum,omitempty"`
+	Pattern     string          `json:"pattern,omitempty"`
+	Items       *schemaProperty `json:"items,omitempty"`
+	UniqueItems bool            `json:"uniqueItems,omitempty"`
+	Default     any             `json:"default,omitempty"`
+}
+
+// QuestionsToJSONSchema renders qs as a JSON Schema object describing their
+// answers, for handing the questions to a form generator or another model.
+// Each question is a property keyed by its header: a choice question is a
+// string enum of its option labels, a multi-select question an array of them,
+// and a text question a string, constrained by its Pattern when it has one.
+// Questions without a Default are required. The questions must pass
+// ValidateQuestions and have distinct, non-empty headers.
+func QuestionsToJSONSchema(qs []Question) ([]byte, error) {
+	if err := ValidateQuestions(qs); err != nil {
+		return nil, err
+	}
+	schema := questionSchema{
+		Schema:     jsonSchemaDialect,
+		Type:       "object",
+		Properties: make(map[string]schemaProperty, len(qs)),
+	}
+	for i, q := range qs {
+		if q.Header == "" {
+			return nil, fmt.Errorf("questions[%d]: header must not be empty", i)
+		}
+		if _, ok := schema.Properties[q.Header]; ok {
+			return nil, fmt.Errorf("questions[%d]: duplicate header %q", i, q.Header)
+		}
+		schema.Properties[q.Header] = questionProperty(q)
+		if q.Default == "" {
+			schema.Required = append(schema.Required, q.Header)
+		}
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// questionProperty returns the schema of q's answer.
+func questionProperty(q Question) schemaProperty {
+	p := schemaProperty{Type: "string", Title: q.Header, Description: q.Question}
+	if q.Default != "" {
+		p.Default = q.Default
+	}
+	switch {
+	case q.Type == QuestionTypeChoice && q.MultiSelect:
+		p.Type = "array"
+		p.Items = &schemaProperty{Type: "string", Enum: q.OptionLabels()}
+		p.UniqueItems = true
+		if q.Default != "" {
+			p.Default = []string{q.Default}
+		}
+	case q.Type == QuestionTypeChoice:
+		p.Enum = q.OptionLabels()
+	case q.Pattern != "":
+		// JSON Schema patterns are unanchored; CheckAnswer matches whole answers.
+		p.Pattern = `^(?:` + q.Pattern + `)$`
+	}
+	return p
+}
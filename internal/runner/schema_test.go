@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaProperties renders qs and returns the schema with each property
+// re-marshalled on its own, for comparing one question kind at a time.
+func schemaProperties(t *testing.T, qs ...Question) (map[string]any, map[string]string) {
+	t.Helper()
+	raw, err := QuestionsToJSONSchema(qs)
+	require.NoError(t, err)
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(raw, &schema))
+	props := map[string]string{}
+	for name, p := range schema["properties"].(map[string]any) {
+		b, err := json.Marshal(p)
+		require.NoError(t, err)
+		props[name] = string(b)
+	}
+	return schema, props
+}
+
+func TestQuestionsToJSONSchema(t *testing.T) {
+	multi := multiSelectQuestion("Auth", "Billing")
+	multi.Default = "Auth"
+	schema, props := schemaProperties(t, choiceQuestion("", "A", "B"), multi, branchQuestion(),
+		Question{Question: "Anything else?", Header: "Notes", Type: QuestionTypeText, Default: "no"})
+
+	require.Equal(t, jsonSchemaDialect, schema["$schema"])
+	require.Equal(t, "object", schema["type"])
+	require.Equal(t, false, schema["additionalProperties"])
+	require.Equal(t, []any{"Approach", "Branch"}, schema["required"], "questions with a default are optional")
+
+	require.JSONEq(t, `{"type":"string","title":"Approach","description":"Which approach?","enum":["A","B"]}`, props["Approach"])
+	require.JSONEq(t, `{"type":"array","title":"Features","description":"Which features?","items":{"type":"string","enum":["Auth","Billing"]},"uniqueItems":true,"default":["Auth"]}`, props["Features"])
+	require.JSONEq(t, `{"type":"string","title":"Branch","description":"Which branch?","pattern":"^(?:feature/[a-z-]+)$"}`, props["Branch"])
+	require.JSONEq(t, `{"type":"string","title":"Notes","description":"Anything else?","default":"no"}`, props["Notes"])
+}
+
+// compileSchema renders qs and compiles the schema with a JSON Schema
+// validator, failing t if it is not a valid schema.
+func compileSchema(t *testing.T, qs ...Question) *jsonschema.Schema {
+	t.Helper()
+	raw, err := QuestionsToJSONSchema(qs)
+	require.NoError(t, err)
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	require.NoError(t, err)
+	c := jsonschema.NewCompiler()
+	require.NoError(t, c.AddResource("questions.json", doc))
+	schema, err := c.Compile("questions.json")
+	require.NoError(t, err)
+	return schema
+}
+
+func TestQuestionsToJSONSchema_ValidatesAnswers(t *testing.T) {
+	multi := multiSelectQuestion("Auth", "Billing")
+	multi.Default = "Auth"
+	notes := Question{Question: "Anything else?", Header: "Notes", Type: QuestionTypeText, Default: "no"}
+
+	for _, tc := range []struct {
+		name      string
+		question  Question
+		good, bad []string
+	}{
+		{"choice", choiceQuestion("", "A", "B"), []string{`{"Approach":"A"}`}, []string{`{}`, `{"Approach":"C"}`, `{"Approach":1}`}},
+		{"multi-select", multi, []string{`{}`, `{"Features":["Auth","Billing"]}`, `{"Features":[]}`}, []string{`{"Features":"Auth"}`, `{"Features":["Search"]}`, `{"Features":["Auth","Auth"]}`}},
+		{"pattern", branchQuestion(), []string{`{"Branch":"feature/auth-rollout"}`}, []string{`{}`, `{"Branch":"main"}`, `{"Branch":"feature/auth rollout"}`}},
+		{"text", notes, []string{`{}`, `{"Notes":"ship it"}`}, []string{`{"Notes":["ship it"]}`, `{"Notes":"x","Other":"y"}`}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			schema := compileSchema(t, tc.question)
+			for _, answer := range tc.good {
+				inst, err := jsonschema.UnmarshalJSON(bytes.NewReader([]byte(answer)))
+				require.NoError(t, err)
+				require.NoError(t, schema.Validate(inst), answer)
+			}
+			for _, answer := range tc.bad {
+				inst, err := jsonschema.UnmarshalJSON(bytes.NewReader([]byte(answer)))
+				require.NoError(t, err)
+				require.Error(t, schema.Validate(inst), answer)
+			}
+		})
+	}
+}
+
+func TestQuestionsToJSONSchema_RejectsUnkeyableQuestions(t *testing.T) {
+	_, err := QuestionsToJSONSchema([]Question{branchQuestion(), branchQuestion()})
+	require.EqualError(t, err, `questions[1]: duplicate header "Branch"`)
+
+	_, err = QuestionsToJSONSchema([]Question{{Question: "Name?", Type: QuestionTypeText}})
+	require.EqualError(t, err, "questions[0]: header must not be empty")
+
+	_, err = QuestionsToJSONSchema([]Question{choiceQuestion("C", "A")})
+	require.ErrorContains(t, err, `default "C" does not match any option`)
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SSEOptions controls WriteSSEWithOptions.
+type SSEOptions struct {
+	// Context, if set, stops the stream when done, typically the request's
+	// context so a disconnected client is noticed promptly.
+	Context context.Context
+}
+
+// sseDone is the frame that ends a stream once events closes. Browsers only
+// dispatch events with data, so it carries an empty object.
+const sseDone = "event: done\ndata: {}\n\n"
+
+// WriteSSE streams events to w as server-sent events. See
+// WriteSSEWithOptions.
+func WriteSSE(events <-chan Event, w http.ResponseWriter) error {
+	return WriteSSEWithOptions(events, w, SSEOptions{})
+}
+
+// WriteSSEWithOptions streams events to w as server-sent events: each event,
+// marshalled as JSON, in a "data:" frame flushed as soon as it is written,
+// then a final "event: done" frame once events closes. It sets the
+// event-stream headers before the first write. If opts.Context is done first
+// it returns the context's error without writing the done frame; the caller
+// still owns events and should cancel the run and drain it. Write and flush
+// failures are returned too.
+func WriteSSEWithOptions(events <-chan Event, w http.ResponseWriter, opts SSEOptions) error {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+
+	rc := http.NewResponseController(w)
+	send := func(frame []byte) error {
+		if _, err := w.Write(frame); err != nil {
+			return fmt.Errorf("writing sse frame: %w", err)
+		}
+		if err := rc.Flush(); err != nil {
+			return fmt.Errorf("flushing sse frame: %w", err)
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return send([]byte(sseDone))
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("encoding %s event: %w", e.Type, err)
+			}
+			frame := append([]byte("data: "), data...)
+			if err := send(append(frame, '\n', '\n')); err != nil {
+				return err
+			}
+		}
+	}
+}
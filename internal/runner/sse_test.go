@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sseFrame is one parsed server-sent event.
+type sseFrame struct {
+	event string
+	data  string
+}
+
+// parseSSE splits an event stream into frames.
+func parseSSE(t *testing.T, body string) []sseFrame {
+	t.Helper()
+	var frames []sseFrame
+	var cur sseFrame
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			frames = append(frames, cur)
+			cur = sseFrame{}
+		case strings.HasPrefix(line, "event: "):
+			cur.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			cur.data = strings.TrimPrefix(line, "data: ")
+		default:
+			t.Fatalf("unexpected sse line %q", line)
+		}
+	}
+	require.Equal(t, sseFrame{}, cur, "stream ends with a complete frame")
+	return frames
+}
+
+func TestWriteSSE_FramesEachEventThenDone(t *testing.T) {
+	events := make(chan Event, 2)
+	events <- Event{Type: "assistant", Data: map[string]any{"type": "assistant", "session_id": "s1"}}
+	events <- Event{Type: "result", Data: map[string]any{"type": "result", "result": "multi\nline plan"}}
+	close(events)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, WriteSSE(events, rec))
+	require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	require.True(t, rec.Flushed)
+
+	frames := parseSSE(t, rec.Body.String())
+	require.Len(t, frames, 3)
+	var first Event
+	require.NoError(t, json.Unmarshal([]byte(frames[0].data), &first))
+	require.Equal(t, "s1", first.SessionID())
+	var last Event
+	require.NoError(t, json.Unmarshal([]byte(frames[1].data), &last))
+	require.Equal(t, "multi\nline plan", last.ResultText())
+	require.Equal(t, sseFrame{event: "done", data: "{}"}, frames[2])
+}
+
+func TestWriteSSE_ReturnsOnClientDisconnect(t *testing.T) {
+	events := make(chan Event, 1)
+	events <- Event{Type: "system", Data: map[string]any{"type": "system"}}
+	returned := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		returned <- WriteSSEWithOptions(events, w, SSEOptions{Context: r.Context()})
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	require.Contains(t, line, `"Type":"system"`, "the first frame is flushed before the stream ends")
+
+	cancel()
+	resp.Body.Close()
+	select {
+	case err := <-returned:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("WriteSSE did not return after the client disconnected")
+	}
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrBudgetExceeded reports that a run was aborted because its spend reached
+// RunOptions.MaxCostUSD. Budget-enforcing wrappers return it, possibly
+// wrapped, as the run's terminal error.
+var ErrBudgetExceeded = errors.New("cost budget exceeded")
+
+// ErrPlanRejected reports that an ApprovalFunc declined a plan, so the steps
+// after it were not run.
+var ErrPlanRejected = errors.New("plan rejected")
+
+// RunStatus is the terminal status of a run, suitable for mapping to an exit code.
+type RunStatus string
+
+const (
+	RunStatusSuccess        RunStatus = "success"
+	RunStatusError          RunStatus = "error"
+	RunStatusCancelled      RunStatus = "cancelled"
+	RunStatusBudgetExceeded RunStatus = "budget_exceeded"
+	RunStatusRejected       RunStatus = "rejected"
+)
+
+// budgetSubtype is the result subtype the CLI reports when it stops a run at
+// its own spend cap.
+const budgetSubtype = "error_max_budget_usd"
+
+// FinalStatus derives the terminal status of a run from its collected events
+// and the error received from the runner's error channel. Cancellation
+// (context.Canceled or context.DeadlineExceeded) takes precedence, then budget
+// exhaustion, then plan rejection, then any runner error. Otherwise the last result event decides:
+// an error result is RunStatusError and a clean one RunStatusSuccess. A run
+// that produced no result at all is RunStatusError.
+func FinalStatus(events []Event, runErr error) RunStatus {
+	switch {
+	case errors.Is(runErr, context.Canceled), errors.Is(runErr, context.DeadlineExceeded):
+		return RunStatusCancelled
+	case errors.Is(runErr, ErrBudgetExceeded):
+		return RunStatusBudgetExceeded
+	case errors.Is(runErr, ErrPlanRejected):
+		return RunStatusRejected
+	case runErr != nil:
+		return RunStatusError
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		if !e.IsResult() {
+			continue
+		}
+		if subtype, _ := e.Data["subtype"].(string); subtype == budgetSubtype {
+			return RunStatusBudgetExceeded
+		}
+		if e.IsError() {
+			return RunStatusError
+		}
+		return RunStatusSuccess
+	}
+	return RunStatusError
+}
@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFinalStatus(t *testing.T) {
+	success := Event{Type: "result", Data: map[string]any{"subtype": "success", "result": "plan"}}
+	failure := Event{Type: "result", Data: map[string]any{"subtype": "error_during_execution", "is_error": true}}
+	budget := Event{Type: "result", Data: map[string]any{"subtype": "error_max_budget_usd", "is_error": true}}
+	assistant := Event{Type: "assistant"}
+
+	tests := []struct {
+		name   string
+		events []Event
+		err    error
+		want   RunStatus
+	}{
+		{"success", []Event{assistant, success}, nil, RunStatusSuccess},
+		{"error result", []Event{assistant, failure}, nil, RunStatusError},
+		{"runner error", []Event{assistant}, errors.New("claude process exited with error"), RunStatusError},
+		{"cancelled", []Event{assistant}, fmt.Errorf("run aborted: %w", context.Canceled), RunStatusCancelled},
+		{"deadline", nil, context.DeadlineExceeded, RunStatusCancelled},
+		{"budget abort", []Event{assistant}, fmt.Errorf("stopping: %w", ErrBudgetExceeded), RunStatusBudgetExceeded},
+		{"budget result", []Event{assistant, budget}, nil, RunStatusBudgetExceeded},
+		{"plan rejected", []Event{assistant, success}, ErrPlanRejected, RunStatusRejected},
+		{"last result wins", []Event{failure, success}, nil, RunStatusSuccess},
+		{"no result", []Event{assistant}, nil, RunStatusError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, FinalStatus(tt.events, tt.err))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// summaryResultRunes caps the result text quoted in a Summarize paragraph.
+	summaryResultRunes = 200
+	// summaryMaxNames caps the tool and file names listed by Summarize.
+	summaryMaxNames = 5
+)
+
+// Summarize describes a completed run in one plain-language paragraph: how it
+// ended, an excerpt of its result, the tools it used, the files it touched and
+// what it cost. It works purely from the collected events and makes no agent
+// call.
+func Summarize(events []Event) string {
+	if len(events) == 0 {
+		return "The run produced no output."
+	}
+
+	var result string
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].IsResult() {
+			result = strings.Join(strings.Fields(StripMarkers(events[i].ResultText())), " ")
+			break
+		}
+	}
+
+	var parts []string
+	switch FinalStatus(events, nil) {
+	case RunStatusSuccess:
+		if result == "" {
+			parts = append(parts, "The run completed successfully.")
+		} else {
+			parts = append(parts, fmt.Sprintf("The run completed successfully: %q.", excerpt(result, summaryResultRunes)))
+		}
+	case RunStatusBudgetExceeded:
+		parts = append(parts, "The run stopped after reaching its cost budget.")
+	default:
+		if result == "" {
+			parts = append(parts, "The run failed without reporting a result.")
+		} else {
+			parts = append(parts, fmt.Sprintf("The run failed: %q.", excerpt(result, summaryResultRunes)))
+		}
+	}
+
+	if tools := ToolNamesUsed(events); len(tools) > 0 {
+		parts = append(parts, fmt.Sprintf("It used %s (%s).", plural(len(tools), "tool"), listNames(tools)))
+	} else {
+		parts = append(parts, "It used no tools.")
+	}
+	if files := FilesTouched(events); len(files) > 0 {
+		parts = append(parts, fmt.Sprintf("It touched %s (%s).", plural(len(files), "file"), listNames(files)))
+	}
+	if cost := SummarizeUsage(events).CostUSD; cost > 0 {
+		parts = append(parts, fmt.Sprintf("It cost $%.4f.", cost))
+	}
+	return strings.Join(parts, " ")
+}
+
+// plural formats n with noun, adding an "s" unless n is one.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// listNames joins up to summaryMaxNames names, noting how many were left out.
+func listNames(names []string) string {
+	if len(names) <= summaryMaxNames {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:summaryMaxNames], ", "), len(names)-summaryMaxNames)
+}
@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize_SuccessfulRun(t *testing.T) {
+	events := []Event{
+		{Type: "system", Data: map[string]any{"subtype": "init"}},
+		assistantBlocks(
+			toolUse("1", "Read", map[string]any{"file_path": "spec.md"}),
+			toolUse("2", "Bash", map[string]any{"command": "go test ./..."}),
+		),
+		assistantBlocks(toolUse("3", "Write", map[string]any{"file_path": "plan.md"})),
+		{Type: "result", Data: map[string]any{"result": "Wrote the plan\nfor auth. <!--FINISHED-->", "total_cost_usd": 0.0421}},
+	}
+
+	require.Equal(t,
+		`The run completed successfully: "Wrote the plan for auth.". It used 3 tools (Bash, Read, Write). It touched 2 files (plan.md, spec.md). It cost $0.0421.`,
+		Summarize(events))
+}
+
+func TestSummarize_FailedRun(t *testing.T) {
+	events := []Event{
+		assistantBlocks(toolUse("1", "Read", map[string]any{"file_path": "spec.md"})),
+		{Type: "result", Data: map[string]any{"result": "spec.md not found", "is_error": true}},
+	}
+
+	require.Equal(t, `The run failed: "spec.md not found". It used 1 tool (Read). It touched 1 file (spec.md).`, Summarize(events))
+}
+
+func TestSummarize_EmptyAndResultlessRuns(t *testing.T) {
+	require.Equal(t, "The run produced no output.", Summarize(nil))
+	require.Equal(t, "The run failed without reporting a result. It used no tools.",
+		Summarize([]Event{{Type: "system", Data: map[string]any{}}}))
+}
+
+func TestListNames_Caps(t *testing.T) {
+	require.Equal(t, "a, b, c, d, e and 2 more", listNames([]string{"a", "b", "c", "d", "e", "f", "g"}))
+}
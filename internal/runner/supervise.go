@@ -0,0 +1,230 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jumppad-labs/spektacular/internal/pricing"
+)
+
+// Supervise runs fn on a new goroutine and returns the events it sends and its
+// terminal error, adding the per-run behaviour every runner shares, so that
+// choosing one backend over another does not change what a run does:
+//
+//   - the leading events, such as unsupported-option warnings, come first;
+//   - RunOptions.Timeout, IdleTimeout and MaxCostUSD are enforced by
+//     cancelling the context fn receives in its opts, after which the run
+//     ends with an *ErrTimeout or an error wrapping ErrBudgetExceeded;
+//   - RunOptions.Notifier is told when the run ends;
+//   - OutputMiddleware is applied to the returned channel.
+//
+// fn must stop promptly once its opts.Context is done. Exec and RunCommand run
+// subprocesses through Supervise; runners that reach their agent some other
+// way should use it too.
+func Supervise(opts RunOptions, fn func(opts RunOptions, events chan<- Event) error, leading ...Event) (<-chan Event, <-chan error) {
+	events := make(chan Event, 64)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		for _, e := range leading {
+			events <- e
+		}
+		c := clockOrReal(opts.clock)
+		began := c.Now()
+		limits := newRunLimits(opts)
+
+		// sink is unbuffered so a slow consumer still holds up fn.
+		sink := make(chan Event)
+		done := make(chan struct{})
+		var last *Event
+		go func() {
+			defer close(done)
+			for e := range sink {
+				if e.IsResult() {
+					last = &e
+				}
+				limits.observe(e)
+				events <- e
+				limits.resume()
+			}
+		}()
+		err := fn(limits.opts, sink)
+		close(sink)
+		<-done
+		err = limits.finish(err)
+
+		if opts.Notifier != nil {
+			go notify(opts.Notifier, last, err, c.Now().Sub(began))
+		}
+		if err != nil {
+			errc <- err
+		}
+		close(errc)
+	}()
+
+	return OutputMiddleware(opts)(events), errc
+}
+
+// runLimits enforces a run's Timeout, IdleTimeout and MaxCostUSD. opts is the
+// run's options with Context replaced by one the limits cancel. Both timeouts
+// are timed on opts.clock.
+type runLimits struct {
+	opts   RunOptions
+	parent context.Context
+	cancel context.CancelCauseFunc
+	done   chan struct{} // closed by finish to stop watch; nil without a timeout
+
+	deadline timer // nil without a Timeout
+	idle     timer // nil without an IdleTimeout
+	cost     costMeter
+	over     error // set once the cost meter reaches MaxCostUSD
+}
+
+func newRunLimits(opts RunOptions) *runLimits {
+	l := &runLimits{opts: opts}
+	if opts.Timeout <= 0 && opts.IdleTimeout <= 0 && opts.MaxCostUSD <= 0 {
+		l.cancel = func(error) {}
+		return l
+	}
+	l.parent = opts.Context
+	if l.parent == nil {
+		l.parent = context.Background()
+	}
+	ctx, cancel := context.WithCancelCause(l.parent)
+	l.cancel = cancel
+	l.opts.Context = ctx
+	c := clockOrReal(opts.clock)
+	if opts.Timeout > 0 {
+		l.deadline = c.NewTimer(opts.Timeout)
+	}
+	if opts.IdleTimeout > 0 {
+		l.idle = c.NewTimer(opts.IdleTimeout)
+	}
+	if l.deadline != nil || l.idle != nil {
+		l.done = make(chan struct{})
+		go l.watch()
+	}
+	return l
+}
+
+// watch cancels the run with an *ErrTimeout when either timer fires, until
+// finish closes l.done.
+func (l *runLimits) watch() {
+	var deadline, idle <-chan time.Time
+	if l.deadline != nil {
+		deadline = l.deadline.C()
+	}
+	if l.idle != nil {
+		idle = l.idle.C()
+	}
+	select {
+	case <-deadline:
+		l.cancel(&ErrTimeout{Limit: l.opts.Timeout})
+	case <-idle:
+		l.cancel(&ErrTimeout{Idle: true, Limit: l.opts.IdleTimeout})
+	case <-l.done:
+	}
+}
+
+// observe records an event from the agent: the idle timer is paused while the
+// event is delivered, and its cost is metered against the budget.
+func (l *runLimits) observe(e Event) {
+	if l.idle != nil {
+		l.idle.Stop()
+	}
+	l.cost.observe(e)
+	if limit := l.opts.MaxCostUSD; limit > 0 && l.over == nil && l.cost.spent() >= limit {
+		l.over = fmt.Errorf("run spent $%.4f of its $%.4f budget: %w", l.cost.spent(), limit, ErrBudgetExceeded)
+		l.cancel(l.over)
+	}
+}
+
+// resume restarts the idle timer once an event has been delivered, so time
+// spent waiting on a slow consumer never counts as the agent being idle.
+func (l *runLimits) resume() {
+	if l.idle != nil {
+		l.idle.Reset(l.opts.IdleTimeout)
+	}
+}
+
+// finish releases the limits and returns the run's terminal error: the budget
+// error once the budget has been reached, the *ErrTimeout of a run that failed
+// after a timeout killed it, and otherwise runErr.
+func (l *runLimits) finish(runErr error) error {
+	var cause error
+	if l.parent != nil && l.parent.Err() == nil {
+		cause = context.Cause(l.opts.Context)
+	}
+	if l.deadline != nil {
+		l.deadline.Stop()
+	}
+	if l.idle != nil {
+		l.idle.Stop()
+	}
+	if l.done != nil {
+		close(l.done)
+	}
+	l.cancel(nil)
+
+	if l.over != nil {
+		return l.over
+	}
+	var timeout *ErrTimeout
+	if runErr != nil && errors.As(cause, &timeout) {
+		return timeout
+	}
+	return runErr
+}
+
+// costMeter tracks what a run has spent so far from its events: the
+// total_cost_usd of the latest result event plus the cost of each assistant
+// message since, priced by the pricing package for its model. Messages from
+// models the pricing package does not know count as free, so for them only
+// result events move the meter.
+type costMeter struct {
+	base     float64            // total_cost_usd of the latest result
+	messages map[string]float64 // cost of each message since, by message.id
+	unnamed  float64            // cost of messages without an id since
+}
+
+func (m *costMeter) observe(e Event) {
+	if e.IsResult() {
+		if cost, ok := e.Data["total_cost_usd"].(float64); ok {
+			m.base, m.unnamed = cost, 0
+			clear(m.messages)
+		}
+		return
+	}
+	u, ok := e.Usage()
+	if !ok {
+		return
+	}
+	price, ok := pricing.Lookup(e.Model())
+	if !ok {
+		return
+	}
+	cost := price.Cost(u.InputTokens, u.OutputTokens, u.CacheCreationInputTokens, u.CacheReadInputTokens)
+	// Every event of a message repeats its usage, so the latest replaces
+	// the ones before it.
+	id := e.MessageID()
+	if id == "" {
+		m.unnamed += cost
+		return
+	}
+	if m.messages == nil {
+		m.messages = map[string]float64{}
+	}
+	m.messages[id] = cost
+}
+
+// spent returns the cost metered so far.
+func (m *costMeter) spent() float64 {
+	total := m.base + m.unnamed
+	for _, cost := range m.messages {
+		total += cost
+	}
+	return total
+}
@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// feedRun returns a Supervise fn forwarding each event sent on feed until feed
+// is closed or the run's context is done.
+func feedRun(feed <-chan Event) func(RunOptions, chan<- Event) error {
+	return func(opts RunOptions, events chan<- Event) error {
+		for {
+			select {
+			case e, ok := <-feed:
+				if !ok {
+					return nil
+				}
+				events <- e
+			case <-opts.Context.Done():
+				return opts.Context.Err()
+			}
+		}
+	}
+}
+
+func TestRunCommand_TimeoutKillsRun(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"system","session_id":"s1"}'; sleep 10`)
+	fc := newFakeClock()
+
+	began := time.Now()
+	events, errc := RunCommand(cmd, RunOptions{Timeout: time.Minute, clock: fc})
+	<-events
+	fc.BlockUntil(t, 1)
+	fc.Advance(time.Minute)
+	got, err := collect(events, errc)
+	var timeout *ErrTimeout
+	require.True(t, errors.As(err, &timeout), "got %v", err)
+	require.False(t, timeout.Idle)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(began), 5*time.Second, "the agent is killed, not waited for")
+	require.Empty(t, got)
+}
+
+func TestSupervise_TimeoutCancelsRun(t *testing.T) {
+	fc := newFakeClock()
+	feed := make(chan Event)
+	events, errc := Supervise(RunOptions{Timeout: time.Minute, clock: fc}, feedRun(feed))
+
+	feed <- Event{Type: "system"}
+	<-events
+	fc.BlockUntil(t, 1)
+	fc.Advance(time.Minute - time.Second)
+	feed <- Event{Type: "system"}
+	<-events
+	fc.Advance(time.Second)
+
+	_, err := collect(events, errc)
+	var timeout *ErrTimeout
+	require.True(t, errors.As(err, &timeout), "got %v", err)
+	require.False(t, timeout.Idle)
+	require.Equal(t, time.Minute, timeout.Limit)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSupervise_IdleTimeoutCancelsQuietRun(t *testing.T) {
+	fc := newFakeClock()
+	feed := make(chan Event)
+	events, errc := Supervise(RunOptions{IdleTimeout: time.Minute, clock: fc}, feedRun(feed))
+
+	feed <- Event{Type: "system"}
+	<-events
+	fc.BlockUntil(t, 1)
+	fc.Advance(time.Minute)
+
+	_, err := collect(events, errc)
+	var timeout *ErrTimeout
+	require.True(t, errors.As(err, &timeout), "got %v", err)
+	require.True(t, timeout.Idle)
+	require.Equal(t, RunStatusCancelled, FinalStatus(nil, err))
+}
+
+func TestSupervise_IdleTimeoutResetByEvents(t *testing.T) {
+	fc := newFakeClock()
+	feed := make(chan Event)
+	events, errc := Supervise(RunOptions{IdleTimeout: time.Minute, clock: fc}, feedRun(feed))
+
+	for range 5 {
+		feed <- Event{Type: "system"}
+		<-events
+		// The idle timer is pending again once the event has been delivered.
+		fc.BlockUntil(t, 1)
+		fc.Advance(50 * time.Second)
+	}
+	close(feed)
+
+	got, err := collect(events, errc)
+	require.NoError(t, err, "the run outlasts the idle timeout but is never idle that long")
+	require.Empty(t, got)
+}
+
+func TestSupervise_IdleTimeoutIgnoresSlowConsumer(t *testing.T) {
+	fc := newFakeClock()
+	events, errc := Supervise(RunOptions{IdleTimeout: time.Minute, clock: fc}, func(_ RunOptions, events chan<- Event) error {
+		for range 200 {
+			events <- Event{Type: "system"}
+		}
+		return nil
+	})
+
+	// Once the buffer is full and no timer is pending, the run is blocked on
+	// the consumer with its idle timer paused.
+	require.Eventually(t, func() bool {
+		fc.mu.Lock()
+		defer fc.mu.Unlock()
+		return len(events) == cap(events) && len(fc.timers) == 0
+	}, time.Second, time.Millisecond)
+	fc.Advance(time.Hour)
+
+	got, err := collect(events, errc)
+	require.NoError(t, err)
+	require.Len(t, got, 200)
+}
+
+func TestRunCommand_MaxCostKillsRunOnMeteredUsage(t *testing.T) {
+	// 1M input tokens of claude-sonnet-4-5 cost $3.
+	cmd := fakeProcess(`echo '{"type":"assistant","message":{"id":"m1","model":"claude-sonnet-4-5","usage":{"input_tokens":1000000}}}'; sleep 10; echo '{"type":"result","result":"done"}'`)
+
+	began := time.Now()
+	got, err := collect(RunCommand(cmd, RunOptions{MaxCostUSD: 1}))
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	require.Less(t, time.Since(began), 5*time.Second, "the agent is killed, not waited for")
+	require.Len(t, got, 1)
+	require.Equal(t, RunStatusBudgetExceeded, FinalStatus(got, err))
+}
+
+func TestRunCommand_MaxCostChecksResultCost(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"result","result":"done","total_cost_usd":0.6}'`)
+
+	_, err := collect(RunCommand(cmd, RunOptions{MaxCostUSD: 0.5}))
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+
+	cmd = fakeProcess(`echo '{"type":"result","result":"done","total_cost_usd":0.4}'`)
+	_, err = collect(RunCommand(cmd, RunOptions{MaxCostUSD: 0.5}))
+	require.NoError(t, err)
+}
+
+func TestCostMeter_CountsMessagesOnceAndRebasesOnResult(t *testing.T) {
+	msg := func(id string, in int) Event {
+		e := assistantWithUsage("claude-sonnet-4-5", in, 0, 0, 0)
+		e.Data["message"].(map[string]any)["id"] = id
+		return e
+	}
+	var m costMeter
+	m.observe(msg("m1", 1_000_000))
+	m.observe(msg("m1", 1_000_000))
+	require.InDelta(t, 3.0, m.spent(), 1e-9)
+
+	m.observe(Event{Type: "result", Data: map[string]any{"total_cost_usd": 2.5}})
+	require.InDelta(t, 2.5, m.spent(), 1e-9, "the result's reported cost replaces the estimate")
+
+	m.observe(msg("m2", 1_000_000))
+	m.observe(assistantWithUsage("unpriced-model", 1_000_000, 0, 0, 0))
+	require.InDelta(t, 5.5, m.spent(), 1e-9)
+}
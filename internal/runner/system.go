@@ -0,0 +1,67 @@
+package runner
+
+// MCPServer is an MCP server listed in the system init event.
+type MCPServer struct {
+	Name   string
+	Status string // e.g. "connected", "failed"
+}
+
+// SystemInit is the session metadata the agent reports in its system/init
+// event at the start of a run.
+type SystemInit struct {
+	Model          string
+	CWD            string
+	Tools          []string
+	MCPServers     []MCPServer
+	PermissionMode string
+}
+
+// SystemInit decodes a system event with subtype "init". It returns false for
+// any other event.
+func (e Event) SystemInit() (SystemInit, bool) {
+	if e.Type != "system" {
+		return SystemInit{}, false
+	}
+	if subtype, _ := e.Data["subtype"].(string); subtype != "init" {
+		return SystemInit{}, false
+	}
+
+	si := SystemInit{Model: e.Model()}
+	si.CWD, _ = e.Data["cwd"].(string)
+	si.PermissionMode, _ = e.Data["permissionMode"].(string)
+	tools, _ := e.Data["tools"].([]any)
+	for _, t := range tools {
+		if name, ok := t.(string); ok {
+			si.Tools = append(si.Tools, name)
+		}
+	}
+	servers, _ := e.Data["mcp_servers"].([]any)
+	for _, item := range servers {
+		s, _ := item.(map[string]any)
+		name, _ := s["name"].(string)
+		status, _ := s["status"].(string)
+		if name != "" {
+			si.MCPServers = append(si.MCPServers, MCPServer{Name: name, Status: status})
+		}
+	}
+	return si, true
+}
+
+// compactionSubtypes are the system event subtypes the agent emits when it
+// summarizes earlier conversation history to free context, whether triggered
+// automatically or by /compact, and when it carries a session on from a
+// summary.
+var compactionSubtypes = map[string]bool{
+	"compact_boundary": true,
+	"continuation":     true,
+}
+
+// IsCompaction reports whether e marks a context compaction, after which the
+// agent only has a summary of the history before it.
+func (e Event) IsCompaction() bool {
+	if e.Type != "system" {
+		return false
+	}
+	subtype, _ := e.Data["subtype"].(string)
+	return compactionSubtypes[subtype]
+}
@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvent_SystemInit(t *testing.T) {
+	raw := `{"type":"system","subtype":"init","session_id":"s1","cwd":"/repo","model":"claude-sonnet-4-5",
+		"permissionMode":"plan","tools":["Read","Grep","Bash"],
+		"mcp_servers":[{"name":"github","status":"connected"},{"name":"jira","status":"failed"}]}`
+	var data map[string]any
+	require.NoError(t, json.Unmarshal([]byte(raw), &data))
+
+	got, ok := Event{Type: "system", Data: data}.SystemInit()
+	require.True(t, ok)
+	require.Equal(t, SystemInit{
+		Model:          "claude-sonnet-4-5",
+		CWD:            "/repo",
+		Tools:          []string{"Read", "Grep", "Bash"},
+		MCPServers:     []MCPServer{{Name: "github", Status: "connected"}, {Name: "jira", Status: "failed"}},
+		PermissionMode: "plan",
+	}, got)
+}
+
+func TestEvent_SystemInit_OtherEvents(t *testing.T) {
+	for _, e := range []Event{
+		{Type: "system", Data: map[string]any{"subtype": "compact_boundary"}},
+		{Type: "assistant", Data: map[string]any{"subtype": "init"}},
+		{Type: "result", Data: map[string]any{}},
+	} {
+		_, ok := e.SystemInit()
+		require.False(t, ok, e.Type)
+	}
+}
+
+func TestEvent_IsCompaction(t *testing.T) {
+	compact := Event{Type: "system", Data: map[string]any{
+		"subtype":          "compact_boundary",
+		"session_id":       "s1",
+		"compact_metadata": map[string]any{"trigger": "auto", "pre_tokens": 180000.0},
+	}}
+	require.True(t, compact.IsCompaction())
+	require.True(t, Event{Type: "system", Data: map[string]any{"subtype": "continuation"}}.IsCompaction())
+
+	require.False(t, Event{Type: "system", Data: map[string]any{"subtype": "init"}}.IsCompaction())
+	require.False(t, Event{Type: "system", Data: map[string]any{}}.IsCompaction())
+	require.False(t, Event{Type: "result", Data: map[string]any{"subtype": "compact_boundary"}}.IsCompaction())
+}
@@ -0,0 +1,102 @@
+// Package testutil holds helpers for tests that drive a runner.Runner.
+package testutil
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/jumppad-labs/spektacular/internal/config"
+	"github.com/jumppad-labs/spektacular/internal/runner"
+)
+
+// RequireSuccess drains both channels returned by Runner.Run and returns the
+// text of the final result event. It fails t immediately if the run reported
+// an error, produced an error result, or produced no result at all.
+func RequireSuccess(t testing.TB, events <-chan runner.Event, errc <-chan error) string {
+	t.Helper()
+	var last *runner.Event
+	var seen int
+	for e := range events {
+		seen++
+		if e.IsResult() {
+			last = &e
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if last == nil {
+		t.Fatalf("run produced no result event (%d events)", seen)
+	}
+	if last.IsError() {
+		t.Fatalf("run ended with an error result: %s", last.ResultText())
+	}
+	return last.ResultText()
+}
+
+// RegisterRunner registers constructor under name for the duration of t and
+// returns a default config selecting it as the agent. Any constructor already
+// registered under name is restored when t ends.
+func RegisterRunner(t testing.TB, name string, constructor func() runner.Runner) config.Config {
+	t.Helper()
+	prev, existed := runner.Registered(name)
+	runner.Register(name, constructor)
+	t.Cleanup(func() {
+		if existed {
+			runner.Register(name, prev)
+			return
+		}
+		runner.Unregister(name)
+	})
+	cfg := config.NewDefault()
+	cfg.Agent = name
+	return cfg
+}
+
+// Replay is a runner.Runner that answers every run with the same events and
+// records the options of the last run.
+type Replay struct {
+	Events []runner.Event
+
+	mu   sync.Mutex
+	opts runner.RunOptions
+}
+
+// Run implements runner.Runner.
+func (r *Replay) Run(opts runner.RunOptions) (<-chan runner.Event, <-chan error) {
+	r.mu.Lock()
+	r.opts = opts
+	r.mu.Unlock()
+	events := make(chan runner.Event, len(r.Events))
+	for _, e := range r.Events {
+		events <- e
+	}
+	close(events)
+	errc := make(chan error)
+	close(errc)
+	return events, errc
+}
+
+// Opts returns the options of the last run.
+func (r *Replay) Opts() runner.RunOptions {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.opts
+}
+
+// RegisterReplay registers a Replay of events under name for the duration of t
+// and returns a default config selecting it along with the Replay.
+func RegisterReplay(t testing.TB, name string, events ...runner.Event) (config.Config, *Replay) {
+	t.Helper()
+	r := &Replay{Events: events}
+	return RegisterRunner(t, name, func() runner.Runner { return r }), r
+}
+
+// AssistantUsage builds an assistant event from model reporting in input and
+// out output tokens.
+func AssistantUsage(model string, in, out int) runner.Event {
+	return runner.Event{Type: "assistant", Data: map[string]any{"message": map[string]any{
+		"model": model,
+		"usage": map[string]any{"input_tokens": float64(in), "output_tokens": float64(out)},
+	}}}
+}
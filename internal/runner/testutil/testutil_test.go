@@ -0,0 +1,124 @@
+package testutil
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/jumppad-labs/spektacular/internal/runner"
+	"github.com/stretchr/testify/require"
+)
+
+// channels returns closed run channels carrying events and err.
+func channels(err error, events ...runner.Event) (<-chan runner.Event, <-chan error) {
+	ec := make(chan runner.Event, len(events))
+	for _, e := range events {
+		ec <- e
+	}
+	close(ec)
+	errc := make(chan error, 1)
+	if err != nil {
+		errc <- err
+	}
+	close(errc)
+	return ec, errc
+}
+
+// recordingTB is a testing.TB whose Fatalf records the failure and stops the
+// calling goroutine, so a helper's failure path can be asserted on.
+type recordingTB struct {
+	testing.TB
+	failure string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// expectFailure runs fn against a recordingTB and returns the failure message.
+func expectFailure(t *testing.T, fn func(tb testing.TB)) string {
+	rec := &recordingTB{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(rec)
+	}()
+	<-done
+	return rec.failure
+}
+
+func result(text string, isError bool) runner.Event {
+	return runner.Event{Type: "result", Data: map[string]any{"result": text, "is_error": isError}}
+}
+
+func TestRequireSuccess_ReturnsFinalPlanText(t *testing.T) {
+	events, errc := channels(nil,
+		runner.Event{Type: "system", Data: map[string]any{"session_id": "s1"}},
+		result("## Plan", false),
+	)
+	text := RequireSuccess(t, events, errc)
+	require.Equal(t, "## Plan", text)
+}
+
+func TestRequireSuccess_Failures(t *testing.T) {
+	t.Run("run error", func(t *testing.T) {
+		msg := expectFailure(t, func(tb testing.TB) {
+			events, errc := channels(errors.New("exit status 1"), result("partial", false))
+			RequireSuccess(tb, events, errc)
+		})
+		require.Equal(t, "run failed: exit status 1", msg)
+	})
+	t.Run("error result", func(t *testing.T) {
+		msg := expectFailure(t, func(tb testing.TB) {
+			events, errc := channels(nil, result("boom", true))
+			RequireSuccess(tb, events, errc)
+		})
+		require.Equal(t, "run ended with an error result: boom", msg)
+	})
+	t.Run("no result", func(t *testing.T) {
+		msg := expectFailure(t, func(tb testing.TB) {
+			events, errc := channels(nil, runner.Event{Type: "system"})
+			RequireSuccess(tb, events, errc)
+		})
+		require.Equal(t, "run produced no result event (1 events)", msg)
+	})
+}
+
+func TestRegisterReplay_RegistersForTheTest(t *testing.T) {
+	t.Run("registered", func(t *testing.T) {
+		cfg, replay := RegisterReplay(t, "mock-testutil", result("## Plan", false))
+		require.Equal(t, "mock-testutil", cfg.Agent)
+
+		r, err := runner.NewRunner(cfg.Agent)
+		require.NoError(t, err)
+		events, errc := r.Run(runner.RunOptions{Model: "opus"})
+		require.Equal(t, "## Plan", RequireSuccess(t, events, errc))
+		require.Equal(t, "opus", replay.Opts().Model)
+	})
+
+	_, err := runner.NewRunner("mock-testutil")
+	var unsupported *runner.ErrUnsupportedRunner
+	require.ErrorAs(t, err, &unsupported, "the runner is unregistered when the test ends")
+}
+
+func TestRegisterRunner_RestoresPreviousConstructor(t *testing.T) {
+	original := &Replay{Events: []runner.Event{result("original", false)}}
+	runner.Register("mock-shadowed", func() runner.Runner { return original })
+	t.Cleanup(func() { runner.Unregister("mock-shadowed") })
+
+	t.Run("shadowed", func(t *testing.T) {
+		RegisterReplay(t, "mock-shadowed", result("shadow", false))
+		r, err := runner.NewRunner("mock-shadowed")
+		require.NoError(t, err)
+		events, errc := r.Run(runner.RunOptions{})
+		require.Equal(t, "shadow", RequireSuccess(t, events, errc))
+	})
+
+	r, err := runner.NewRunner("mock-shadowed")
+	require.NoError(t, err)
+	require.Same(t, original, r, "the constructor registered before the test is back")
+}
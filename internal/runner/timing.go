@@ -0,0 +1,34 @@
+package runner
+
+import "time"
+
+// Timestamp returns the time the agent stamped on e, read from an RFC 3339
+// Data["timestamp"], or the zero time if e carries none or it does not parse.
+func (e Event) Timestamp() time.Time {
+	v, _ := e.Data["timestamp"].(string)
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// MaxGap returns the longest interval between consecutive timestamped events,
+// the run's longest silence, for tuning idle timeouts. Events without a
+// Timestamp are skipped, and a timestamp earlier than its predecessor counts
+// as no gap. Runs with fewer than two timestamped events return zero.
+func MaxGap(events []Event) time.Duration {
+	var longest time.Duration
+	var prev time.Time
+	for _, e := range events {
+		t := e.Timestamp()
+		if t.IsZero() {
+			continue
+		}
+		if !prev.IsZero() {
+			longest = max(longest, t.Sub(prev))
+		}
+		prev = t
+	}
+	return longest
+}
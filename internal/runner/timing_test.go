@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func stamped(typ, ts string) Event {
+	return Event{Type: typ, Data: map[string]any{"type": typ, "timestamp": ts}}
+}
+
+func TestEvent_Timestamp(t *testing.T) {
+	require.Equal(t, time.Date(2025, 10, 10, 12, 0, 1, 500_000_000, time.UTC), stamped("assistant", "2025-10-10T12:00:01.5Z").Timestamp())
+	require.True(t, stamped("assistant", "yesterday").Timestamp().IsZero())
+	require.True(t, Event{Type: "assistant"}.Timestamp().IsZero())
+}
+
+func TestMaxGap(t *testing.T) {
+	events := []Event{
+		stamped("system", "2025-10-10T12:00:00Z"),
+		stamped("assistant", "2025-10-10T12:00:02Z"),
+		{Type: "stderr", Data: map[string]any{"line": "untimed"}},
+		stamped("user", "2025-10-10T12:00:09.25Z"),   // the longest silence: 7.25s
+		stamped("assistant", "2025-10-10T12:00:05Z"), // out of order: no gap
+		stamped("result", "2025-10-10T12:00:11Z"),
+	}
+	require.Equal(t, 7250*time.Millisecond, MaxGap(events))
+}
+
+func TestMaxGap_FewerThanTwoTimestamps(t *testing.T) {
+	require.Zero(t, MaxGap(nil))
+	require.Zero(t, MaxGap([]Event{stamped("result", "2025-10-10T12:00:00Z"), {Type: "assistant"}}))
+}
@@ -0,0 +1,219 @@
+package runner
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ToolError is returned as a run's terminal error when RunOptions.StopOnToolError
+// is set and a tool call fails.
+type ToolError struct {
+	Message string // the failing tool result's content
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("stopped on tool error: %s", e.Message)
+}
+
+// ToolNamesUsed returns the distinct names of every tool the agent invoked
+// across events, sorted.
+func ToolNamesUsed(events []Event) []string {
+	seen := map[string]bool{}
+	for _, e := range events {
+		for _, tool := range e.ToolUses() {
+			if name, _ := tool["name"].(string); name != "" {
+				seen[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fileTools are the tools whose input names a single file in "file_path".
+var fileTools = map[string]bool{"Read": true, "Edit": true, "Write": true}
+
+// FilesTouched returns the distinct file paths the agent read, edited or
+// wrote across events, sorted. Tool calls other than Read, Edit and Write,
+// and calls without a file_path, are ignored.
+func FilesTouched(events []Event) []string {
+	seen := map[string]bool{}
+	for _, e := range events {
+		for _, tool := range e.ToolUses() {
+			if name, _ := tool["name"].(string); !fileTools[name] {
+				continue
+			}
+			input, _ := tool["input"].(map[string]any)
+			if path, _ := input["file_path"].(string); path != "" {
+				seen[path] = true
+			}
+		}
+	}
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// toolResults returns the tool_result blocks of a user event, which is how the
+// agent reports the outcome of each tool call.
+func (e Event) toolResults() []map[string]any {
+	if e.Type != "user" {
+		return nil
+	}
+	var results []map[string]any
+	for _, block := range e.contentBlocks() {
+		if block["type"] == "tool_result" {
+			results = append(results, block)
+		}
+	}
+	return results
+}
+
+// HasToolError reports whether any tool result in a user event is flagged
+// with is_error.
+func (e Event) HasToolError() bool {
+	for _, r := range e.toolResults() {
+		if v, _ := r["is_error"].(bool); v {
+			return true
+		}
+	}
+	return false
+}
+
+// toolErrorText returns the content of the failing tool results in e.
+func (e Event) toolErrorText() string {
+	var texts []string
+	for _, r := range e.toolResults() {
+		if v, _ := r["is_error"].(bool); v {
+			texts = append(texts, toolResultText(r))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// toolResultText flattens a tool_result block's content, which is either a
+// string or a list of text blocks.
+func toolResultText(block map[string]any) string {
+	switch c := block["content"].(type) {
+	case string:
+		return c
+	case []any:
+		var texts []string
+		for _, item := range c {
+			part, _ := item.(map[string]any)
+			if t, ok := part["text"].(string); ok {
+				texts = append(texts, t)
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// CombineToolCalls returns events with each tool_use block and the
+// tool_result answering it, correlated by tool_use_id, replaced by a single
+// synthesized "tool_call" event. The tool_call sits where the tool_use was
+// and carries "id", "name", "input", "output" (the result's flattened text)
+// and "is_error". Other blocks of the assistant and user events involved
+// stay, in order, in events of their own; events left with no blocks are
+// dropped. Unmatched tool uses and results are left as they are. The input
+// is not modified.
+func CombineToolCalls(events []Event) []Event {
+	results := map[string]map[string]any{}
+	for _, e := range events {
+		for _, r := range e.toolResults() {
+			if id, _ := r["tool_use_id"].(string); id != "" {
+				results[id] = r
+			}
+		}
+	}
+	matched := map[string]bool{}
+	for _, e := range events {
+		for _, tool := range e.ToolUses() {
+			if id, _ := tool["id"].(string); results[id] != nil {
+				matched[id] = true
+			}
+		}
+	}
+
+	out := make([]Event, 0, len(events))
+	for _, e := range events {
+		out = append(out, combineEvent(e, results, matched)...)
+	}
+	return out
+}
+
+// combineEvent rewrites one event for CombineToolCalls. Matched tool_use
+// blocks become tool_call events and matched tool_result blocks are dropped;
+// runs of the remaining blocks are kept as copies of e. An event with
+// nothing matched is returned unchanged, keeping its Raw line.
+func combineEvent(e Event, results map[string]map[string]any, matched map[string]bool) []Event {
+	var key, blockType string
+	switch e.Type {
+	case "assistant":
+		key, blockType = "id", "tool_use"
+	case "user":
+		key, blockType = "tool_use_id", "tool_result"
+	default:
+		return []Event{e}
+	}
+	blocks := e.contentBlocks()
+	isMatched := func(b map[string]any) bool {
+		id, _ := b[key].(string)
+		return b["type"] == blockType && matched[id]
+	}
+	if !slices.ContainsFunc(blocks, isMatched) {
+		return []Event{e}
+	}
+
+	var out []Event
+	var pending []any
+	flush := func() {
+		if len(pending) > 0 {
+			out = append(out, e.withContent(pending))
+			pending = nil
+		}
+	}
+	for _, block := range blocks {
+		if !isMatched(block) {
+			pending = append(pending, block)
+			continue
+		}
+		if blockType != "tool_use" {
+			continue
+		}
+		flush()
+		id := block["id"].(string)
+		isError, _ := results[id]["is_error"].(bool)
+		out = append(out, Event{Type: "tool_call", Data: map[string]any{
+			"id":       id,
+			"name":     block["name"],
+			"input":    block["input"],
+			"output":   toolResultText(results[id]),
+			"is_error": isError,
+		}})
+	}
+	flush()
+	return out
+}
+
+// withContent returns a copy of e whose message content is blocks. The copy
+// has no Raw line, since it no longer matches what the agent emitted.
+func (e Event) withContent(blocks []any) Event {
+	data := maps.Clone(e.Data)
+	msg, _ := data["message"].(map[string]any)
+	msg = maps.Clone(msg)
+	msg["content"] = blocks
+	data["message"] = msg
+	return Event{Type: e.Type, Data: data}
+}
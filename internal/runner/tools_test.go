@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// assistantBlocks builds an assistant event whose message content is blocks.
+func assistantBlocks(blocks ...map[string]any) Event {
+	content := make([]any, len(blocks))
+	for i, b := range blocks {
+		content[i] = b
+	}
+	return Event{Type: "assistant", Data: map[string]any{"message": map[string]any{"content": content}}}
+}
+
+// toolUse builds a tool_use content block.
+func toolUse(id, name string, input map[string]any) map[string]any {
+	return map[string]any{"type": "tool_use", "id": id, "name": name, "input": input}
+}
+
+func TestToolNamesUsed_DedupedAndSorted(t *testing.T) {
+	events := []Event{
+		{Type: "system", Data: map[string]any{"tools": []any{"Bash", "Read", "Write"}}},
+		assistantBlocks(toolUse("1", "Read", nil), map[string]any{"type": "text", "text": "reading"}),
+		assistantBlocks(toolUse("2", "Bash", nil), toolUse("3", "Read", nil)),
+		{Type: "user", Data: map[string]any{}},
+		assistantBlocks(toolUse("4", "Edit", nil)),
+		{Type: "result", Data: map[string]any{"result": "done"}},
+	}
+
+	require.Equal(t, []string{"Bash", "Edit", "Read"}, ToolNamesUsed(events))
+}
+
+func TestToolNamesUsed_NoTools(t *testing.T) {
+	require.Empty(t, ToolNamesUsed([]Event{{Type: "result"}}))
+}
+
+func TestFilesTouched_SortedUniqueFilePaths(t *testing.T) {
+	events := []Event{
+		assistantBlocks(
+			toolUse("1", "Read", map[string]any{"file_path": "/repo/spec.md"}),
+			toolUse("2", "Grep", map[string]any{"pattern": "TODO", "path": "/repo"}),
+		),
+		assistantBlocks(toolUse("3", "Edit", map[string]any{"file_path": "/repo/main.go", "old_string": "a", "new_string": "b"})),
+		assistantBlocks(
+			toolUse("4", "Write", map[string]any{"file_path": "/repo/docs/plan.md", "content": "x"}),
+			toolUse("5", "Read", map[string]any{"file_path": "/repo/main.go"}),
+			toolUse("6", "Write", map[string]any{"content": "no path"}),
+			toolUse("7", "Bash", map[string]any{"command": "cat /repo/other.go"}),
+			toolUse("8", "Read", nil),
+		),
+	}
+
+	require.Equal(t, []string{"/repo/docs/plan.md", "/repo/main.go", "/repo/spec.md"}, FilesTouched(events))
+	require.Empty(t, FilesTouched([]Event{{Type: "result"}}))
+}
+
+// toolResult builds a user event carrying one tool_result block.
+func toolResult(id string, isError bool, content any) Event {
+	return Event{Type: "user", Data: map[string]any{"message": map[string]any{"content": []any{
+		map[string]any{"type": "tool_result", "tool_use_id": id, "is_error": isError, "content": content},
+	}}}}
+}
+
+func TestEvent_HasToolError(t *testing.T) {
+	require.True(t, toolResult("1", true, "exit status 1").HasToolError())
+	require.False(t, toolResult("1", false, "ok").HasToolError())
+	require.False(t, Event{Type: "user", Data: map[string]any{}}.HasToolError())
+	require.False(t, Event{Type: "result", Data: map[string]any{"is_error": true}}.HasToolError())
+}
+
+func TestEvent_ToolErrorText_FlattensContentBlocks(t *testing.T) {
+	e := toolResult("1", true, []any{map[string]any{"type": "text", "text": "old_string not found"}})
+	require.Equal(t, "old_string not found", e.toolErrorText())
+}
+
+func TestDecodeStream_StopOnToolError(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"1","content":"ok"}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"2","is_error":true,"content":"exit status 2"}]}}`,
+		`{"type":"result","result":"flailed on"}`,
+	}, "\n")
+
+	events := make(chan Event, 8)
+	err := decodeStream(strings.NewReader(input), RunOptions{StopOnToolError: true}, events, nil)
+	close(events)
+
+	var toolErr *ToolError
+	require.True(t, errors.As(err, &toolErr), "got %v", err)
+	require.Equal(t, "exit status 2", toolErr.Message)
+	require.Len(t, events, 2, "the failing tool result is delivered, later events are not")
+}
+
+func TestDecodeStream_ToolErrorIgnoredByDefault(t *testing.T) {
+	input := `{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"2","is_error":true,"content":"boom"}]}}` + "\n" + `{"type":"result","result":"recovered"}`
+
+	got := decodeAll(input, RunOptions{})
+	require.Len(t, got, 2)
+}
+
+func TestRunCommand_StopOnToolErrorKillsProcess(t *testing.T) {
+	cmd := fakeProcess(`echo '{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"1","is_error":true,"content":"boom"}]}}'; sleep 10; echo '{"type":"result"}'`)
+
+	got, err := collect(RunCommand(cmd, RunOptions{StopOnToolError: true}))
+	var toolErr *ToolError
+	require.True(t, errors.As(err, &toolErr), "got %v", err)
+	require.Len(t, got, 1)
+}
+
+func TestCombineToolCalls_MatchedPairs(t *testing.T) {
+	events := []Event{
+		{Type: "system", Data: map[string]any{"subtype": "init"}},
+		assistantBlocks(
+			map[string]any{"type": "text", "text": "reading both"},
+			toolUse("1", "Read", map[string]any{"file_path": "/repo/a.go"}),
+			toolUse("2", "Bash", map[string]any{"command": "false"}),
+		),
+		toolResult("1", false, []any{map[string]any{"type": "text", "text": "package a"}}),
+		toolResult("2", true, "exit status 1"),
+		{Type: "result", Data: map[string]any{"result": "done"}},
+	}
+
+	combined := CombineToolCalls(events)
+	require.Len(t, combined, 5)
+	require.Equal(t, events[0], combined[0])
+	require.Equal(t, "reading both", combined[1].TextContent())
+	require.Empty(t, combined[1].ToolUses())
+	require.Equal(t, Event{Type: "tool_call", Data: map[string]any{
+		"id": "1", "name": "Read", "input": map[string]any{"file_path": "/repo/a.go"}, "output": "package a", "is_error": false,
+	}}, combined[2])
+	require.Equal(t, "Bash", combined[3].Data["name"])
+	require.Equal(t, "exit status 1", combined[3].Data["output"])
+	require.Equal(t, true, combined[3].Data["is_error"])
+	require.True(t, combined[4].IsResult())
+
+	require.Len(t, events[1].ToolUses(), 2, "the input is not modified")
+}
+
+func TestCombineToolCalls_UnmatchedUse(t *testing.T) {
+	events := []Event{
+		assistantBlocks(toolUse("1", "Read", nil), toolUse("2", "Grep", nil)),
+		toolResult("1", false, "ok"),
+	}
+
+	combined := CombineToolCalls(events)
+	require.Len(t, combined, 2)
+	require.Equal(t, "tool_call", combined[0].Type)
+	require.Equal(t, "Read", combined[0].Data["name"])
+	require.Equal(t, []map[string]any{toolUse("2", "Grep", nil)}, combined[1].ToolUses())
+}
+
+func TestCombineToolCalls_UnmatchedResult(t *testing.T) {
+	events := []Event{
+		toolResult("orphan", false, "ok"),
+		{Type: "result", Data: map[string]any{"result": "done"}},
+	}
+
+	require.Equal(t, events, CombineToolCalls(events))
+}
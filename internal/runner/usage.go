@@ -0,0 +1,157 @@
+package runner
+
+// Usage is the token accounting reported by the agent for a message or run.
+type Usage struct {
+	InputTokens              int
+	OutputTokens             int
+	CacheCreationInputTokens int
+	CacheReadInputTokens     int
+}
+
+// add returns the field-wise sum of u and o.
+func (u Usage) add(o Usage) Usage {
+	return Usage{
+		InputTokens:              u.InputTokens + o.InputTokens,
+		OutputTokens:             u.OutputTokens + o.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens + o.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens + o.CacheReadInputTokens,
+	}
+}
+
+// UsageSummary is the token and cost rollup for a completed run.
+type UsageSummary struct {
+	Usage
+	CostUSD float64  // total_cost_usd of the final result event
+	Models  []string // distinct models seen, in first-seen order
+}
+
+// Usage returns the token usage carried by an assistant message or a result
+// event, and whether the event carried any.
+func (e Event) Usage() (Usage, bool) {
+	var raw map[string]any
+	switch e.Type {
+	case "assistant":
+		msg, _ := e.Data["message"].(map[string]any)
+		raw, _ = msg["usage"].(map[string]any)
+	case "result":
+		raw, _ = e.Data["usage"].(map[string]any)
+	}
+	if raw == nil {
+		return Usage{}, false
+	}
+	return Usage{
+		InputTokens:              intField(raw, "input_tokens"),
+		OutputTokens:             intField(raw, "output_tokens"),
+		CacheCreationInputTokens: intField(raw, "cache_creation_input_tokens"),
+		CacheReadInputTokens:     intField(raw, "cache_read_input_tokens"),
+	}, true
+}
+
+// CostUSD returns the total_cost_usd field of a result event, or zero.
+func (e Event) CostUSD() float64 {
+	if !e.IsResult() {
+		return 0
+	}
+	v, _ := e.Data["total_cost_usd"].(float64)
+	return v
+}
+
+// Model returns the model named by an assistant message or a system event,
+// or empty string.
+func (e Event) Model() string {
+	switch e.Type {
+	case "assistant":
+		msg, _ := e.Data["message"].(map[string]any)
+		v, _ := msg["model"].(string)
+		return v
+	case "system":
+		v, _ := e.Data["model"].(string)
+		return v
+	}
+	return ""
+}
+
+// SummarizeUsage rolls up token usage and cost across the events of a run.
+// Tokens are summed over assistant messages; a result event's usage is itself
+// a run total, so it is only used when no assistant message reported usage.
+// The CLI streams one assistant event per content block, each repeating its
+// message's usage, so a message is counted once per message.id, at the usage
+// of its last event. The cost is taken from the last result event. A run with
+// no usage data yields a zero summary.
+func SummarizeUsage(events []Event) UsageSummary {
+	var summary UsageSummary
+	var resultUsage Usage
+	var sawResultUsage bool
+	seenModels := map[string]bool{}
+
+	for _, e := range events {
+		if m := e.Model(); m != "" && !seenModels[m] {
+			seenModels[m] = true
+			summary.Models = append(summary.Models, m)
+		}
+		if u, ok := e.Usage(); ok && e.IsResult() {
+			resultUsage, sawResultUsage = u, true
+		}
+	}
+	messages := messageUsages(events)
+	for _, m := range messages {
+		summary.Usage = summary.Usage.add(m.usage)
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].IsResult() {
+			summary.CostUSD = events[i].CostUSD()
+			break
+		}
+	}
+
+	if len(messages) == 0 && sawResultUsage {
+		summary.Usage = resultUsage
+	}
+	return summary
+}
+
+// messageUsage is the usage one assistant message reported, and its model.
+type messageUsage struct {
+	model string
+	usage Usage
+}
+
+// messageUsages returns the usage of each assistant message in events, in
+// first-seen order. Events sharing a message.id are one message, which takes
+// the usage of its last event; events without an id each count on their own.
+func messageUsages(events []Event) []messageUsage {
+	var out []messageUsage
+	index := map[string]int{}
+	for _, e := range events {
+		u, ok := e.Usage()
+		if !ok || e.IsResult() {
+			continue
+		}
+		m := messageUsage{model: e.Model(), usage: u}
+		id := e.MessageID()
+		if i, seen := index[id]; seen && id != "" {
+			out[i] = m
+			continue
+		}
+		index[id] = len(out)
+		out = append(out, m)
+	}
+	return out
+}
+
+// MessageID returns the message.id of an assistant event, which every event
+// streamed for one model message shares, or empty string.
+func (e Event) MessageID() string {
+	if e.Type != "assistant" {
+		return ""
+	}
+	msg, _ := e.Data["message"].(map[string]any)
+	id, _ := msg["id"].(string)
+	return id
+}
+
+// intField reads a JSON number from m as an int, or zero.
+func intField(m map[string]any, key string) int {
+	v, _ := m[key].(float64)
+	return int(v)
+}
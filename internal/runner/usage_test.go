@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// assistantWithUsage builds an assistant event for model reporting the given
+// input and output token counts.
+func assistantWithUsage(model string, in, out, cacheCreate, cacheRead int) Event {
+	return Event{
+		Type: "assistant",
+		Data: map[string]any{
+			"message": map[string]any{
+				"model": model,
+				"usage": map[string]any{
+					"input_tokens":                float64(in),
+					"output_tokens":               float64(out),
+					"cache_creation_input_tokens": float64(cacheCreate),
+					"cache_read_input_tokens":     float64(cacheRead),
+				},
+			},
+		},
+	}
+}
+
+func TestSummarizeUsage_SumsMessagesAndTakesFinalCost(t *testing.T) {
+	events := []Event{
+		{Type: "system", Data: map[string]any{"subtype": "init", "model": "claude-sonnet"}},
+		assistantWithUsage("claude-sonnet", 100, 20, 50, 0),
+		assistantWithUsage("claude-sonnet", 10, 5, 0, 50),
+		{Type: "result", Data: map[string]any{"total_cost_usd": 0.01, "usage": map[string]any{"input_tokens": float64(110)}}},
+		assistantWithUsage("claude-haiku", 1, 2, 0, 0),
+		{Type: "result", Data: map[string]any{"total_cost_usd": 0.03}},
+	}
+
+	got := SummarizeUsage(events)
+	require.Equal(t, Usage{
+		InputTokens:              111,
+		OutputTokens:             27,
+		CacheCreationInputTokens: 50,
+		CacheReadInputTokens:     50,
+	}, got.Usage)
+	require.InDelta(t, 0.03, got.CostUSD, 1e-9)
+	require.Equal(t, []string{"claude-sonnet", "claude-haiku"}, got.Models)
+}
+
+func TestSummarizeUsage_CountsEachMessageOnce(t *testing.T) {
+	// One message streamed as a text block and two tool_use blocks, each event
+	// repeating the message's usage, then a second message.
+	block := func(id string, in, out int) Event {
+		e := assistantWithUsage("claude-sonnet", in, out, 0, 0)
+		e.Data["message"].(map[string]any)["id"] = id
+		return e
+	}
+	events := []Event{
+		block("msg_1", 100, 10),
+		block("msg_1", 100, 10),
+		block("msg_1", 100, 12),
+		block("msg_2", 5, 1),
+		{Type: "result", Data: map[string]any{"total_cost_usd": 0.02}},
+	}
+
+	got := SummarizeUsage(events)
+	require.Equal(t, Usage{InputTokens: 105, OutputTokens: 13}, got.Usage, "a message counts once, at its last reported usage")
+}
+
+func TestSummarizeUsage_FallsBackToResultUsage(t *testing.T) {
+	events := []Event{
+		{Type: "result", Data: map[string]any{
+			"total_cost_usd": 0.5,
+			"usage":          map[string]any{"input_tokens": float64(7), "output_tokens": float64(3)},
+		}},
+	}
+
+	got := SummarizeUsage(events)
+	require.Equal(t, Usage{InputTokens: 7, OutputTokens: 3}, got.Usage)
+	require.InDelta(t, 0.5, got.CostUSD, 1e-9)
+}
+
+func TestSummarizeUsage_NoUsageData(t *testing.T) {
+	events := []Event{
+		{Type: "system", Data: map[string]any{}},
+		{Type: "assistant", Data: map[string]any{"message": map[string]any{}}},
+		{Type: "result", Data: map[string]any{"result": "plan"}},
+	}
+
+	require.Equal(t, UsageSummary{}, SummarizeUsage(events))
+	require.Equal(t, UsageSummary{}, SummarizeUsage(nil))
+}
@@ -0,0 +1,95 @@
+package spec
+
+import "strings"
+
+// SpecSection is one top-level section of a Markdown spec. The preamble before
+// the first section heading has an empty Title.
+type SpecSection struct {
+	Title string
+	Body  string
+}
+
+// SplitSpec divides a Markdown spec into ordered sections on its top-level
+// headings, so a spec too large for one agent context can be planned section
+// by section. The top level is the shallowest heading level in the spec,
+// except that a lone title heading above deeper headings (as in the spec
+// scaffold's "# Feature:" line) is kept in the preamble and the spec is split
+// on the level below it. Headings inside fenced code blocks are ignored. A
+// spec without headings is returned as a single preamble section; a blank
+// spec yields no sections.
+func SplitSpec(spec string) []SpecSection {
+	lines := strings.Split(spec, "\n")
+	levels := headingLevels(lines)
+	level := splitLevel(levels)
+
+	var sections []SpecSection
+	current := SpecSection{}
+	var body []string
+	flush := func() {
+		current.Body = strings.TrimSpace(strings.Join(body, "\n"))
+		if current.Title != "" || current.Body != "" {
+			sections = append(sections, current)
+		}
+	}
+	for i, line := range lines {
+		if level > 0 && levels[i] == level {
+			flush()
+			current = SpecSection{Title: strings.TrimSpace(strings.TrimLeft(line, "#"))}
+			body = nil
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return sections
+}
+
+// headingLevels returns the ATX heading level of each line, or 0 for lines
+// that are not headings or sit inside a fenced code block.
+func headingLevels(lines []string) []int {
+	levels := make([]int, len(lines))
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, "#"))
+		if n >= 1 && n <= 6 && (len(line) == n || line[n] == ' ' || line[n] == '\t') {
+			levels[i] = n
+		}
+	}
+	return levels
+}
+
+// splitLevel picks the heading level SplitSpec splits on, or 0 if there are
+// no headings.
+func splitLevel(levels []int) int {
+	counts := map[int]int{}
+	top, next := 0, 0
+	for _, l := range levels {
+		if l == 0 {
+			continue
+		}
+		counts[l]++
+		if top == 0 || l < top {
+			top = l
+		}
+	}
+	for l := range counts {
+		if l > top && (next == 0 || l < next) {
+			next = l
+		}
+	}
+	if counts[top] == 1 && next != 0 {
+		return next
+	}
+	return top
+}
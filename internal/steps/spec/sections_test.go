@@ -0,0 +1,35 @@
+package spec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSpec_MultipleSections(t *testing.T) {
+	spec := "# Feature: auth\n\nIntro text.\n\n## Overview\nLogin flow.\n\n## Requirements\n- OAuth\n\n```md\n## Not a heading\n```\n\n### Detail\nNested.\n"
+
+	require.Equal(t, []SpecSection{
+		{Body: "# Feature: auth\n\nIntro text."},
+		{Title: "Overview", Body: "Login flow."},
+		{Title: "Requirements", Body: "- OAuth\n\n```md\n## Not a heading\n```\n\n### Detail\nNested."},
+	}, SplitSpec(spec))
+}
+
+func TestSplitSpec_SplitsOnShallowestRepeatedLevel(t *testing.T) {
+	spec := "# One\nfirst\n# Two\n## Sub\nsecond"
+
+	require.Equal(t, []SpecSection{
+		{Title: "One", Body: "first"},
+		{Title: "Two", Body: "## Sub\nsecond"},
+	}, SplitSpec(spec))
+}
+
+func TestSplitSpec_SingleSection(t *testing.T) {
+	require.Equal(t, []SpecSection{{Title: "Overview", Body: "Just one."}}, SplitSpec("## Overview\nJust one.\n"))
+}
+
+func TestSplitSpec_NoHeadings(t *testing.T) {
+	require.Equal(t, []SpecSection{{Body: "Plain text\nspec."}}, SplitSpec("Plain text\nspec.\n"))
+	require.Empty(t, SplitSpec("  \n"))
+}